@@ -0,0 +1,161 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+)
+
+var (
+	ErrNoPendingRequest = errors.New("no title is awaiting approval")
+	ErrInvalidDecision  = errors.New("invalid approval decision")
+	ErrRequestCleared   = errors.New("the title awaiting approval was cleared")
+)
+
+// Candidate titles awaiting a decision
+type RequestT struct {
+	Id                   string   `json:"id"`
+	Candidates           []string `json:"candidates"`
+	SuggestedCandidate   int      `json:"suggested_candidate"`
+	ExpiresUnixTimestamp int64    `json:"expires_unix_timestamp"`
+}
+
+type DecisionT struct {
+	Action    string `json:"action"`
+	Candidate int    `json:"candidate"`
+	Title     string `json:"title"` // the edited title - only used by config.ApprovalActionEdit
+	TimedOut  bool   `json:"-"`
+}
+
+// Holds the title awaiting approval, which can be decided from the GUI or the control server
+type QueueT struct {
+	mu           sync.Mutex
+	pending      *RequestT
+	decisionChan chan DecisionT
+	clearedChan  chan struct{}   // closed by Clear
+	onChange     func(*RequestT) // called with nil once the pending request is resolved
+}
+
+var Queue = &QueueT{}
+
+// Sets the function called whenever a request is submitted or resolved
+func (q *QueueT) SetOnChange(onChange func(*RequestT)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onChange = onChange
+}
+
+// Blocks until a decision is made for the candidates, or the timeout passes.
+// On timeout, the suggested candidate is approved or skipped according to onTimeoutAction.
+func (q *QueueT) Submit(ctx context.Context, candidates []string, suggestedCandidate int, timeout time.Duration, onTimeoutAction string) (DecisionT, error) {
+	request := RequestT{
+		Id:                   helpers.GenerateCsrfToken(16),
+		Candidates:           candidates,
+		SuggestedCandidate:   suggestedCandidate,
+		ExpiresUnixTimestamp: time.Now().Add(timeout).Unix(),
+	}
+	decisionChan := make(chan DecisionT, 1)
+	clearedChan := make(chan struct{})
+
+	q.mu.Lock()
+	if q.pending != nil {
+		q.mu.Unlock()
+		return DecisionT{}, errors.New("another title is already awaiting approval")
+	}
+	q.pending = &request
+	q.decisionChan = decisionChan
+	q.clearedChan = clearedChan
+	onChange := q.onChange
+	q.mu.Unlock()
+	if onChange != nil {
+		onChange(&request)
+	}
+
+	defer q.resolve(request.Id)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case decision := <-decisionChan:
+		return decision, nil
+	case <-timer.C:
+		return DecisionT{Action: onTimeoutAction, Candidate: suggestedCandidate, TimedOut: true}, nil
+	case <-ctx.Done():
+		return DecisionT{}, fmt.Errorf("stopped waiting for approval - err: %w", ctx.Err())
+	case <-clearedChan:
+		return DecisionT{}, ErrRequestCleared
+	}
+}
+
+// Abandons the pending request, if any, so that a new one can be submitted straight away
+func (q *QueueT) Clear() {
+	q.mu.Lock()
+	if q.pending == nil {
+		q.mu.Unlock()
+		return
+	}
+	requestId := q.pending.Id
+	close(q.clearedChan)
+	q.mu.Unlock()
+	q.resolve(requestId)
+}
+
+// Returns the request currently awaiting approval, if any
+func (q *QueueT) Pending() (RequestT, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		return RequestT{}, false
+	}
+	return *q.pending, true
+}
+
+// Decides the pending request with the given id
+func (q *QueueT) Decide(requestId string, decision DecisionT) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil || q.pending.Id != requestId {
+		return ErrNoPendingRequest
+	}
+	switch decision.Action {
+	case config.ApprovalActionApprove, config.ApprovalActionEdit:
+		if decision.Candidate < 0 || decision.Candidate >= len(q.pending.Candidates) {
+			return fmt.Errorf("%w - candidate %v does not exist", ErrInvalidDecision, decision.Candidate)
+		}
+		if decision.Action == config.ApprovalActionEdit && decision.Title == "" {
+			return fmt.Errorf("%w - edited title must not be empty", ErrInvalidDecision)
+		}
+	case config.ApprovalActionRegenerate, config.ApprovalActionSkip:
+		// nothing to validate
+	default:
+		return fmt.Errorf("%w - unknown action %q", ErrInvalidDecision, decision.Action)
+	}
+	select {
+	case q.decisionChan <- decision:
+		return nil
+	default:
+		return ErrNoPendingRequest // already decided
+	}
+}
+
+// Removes the request with this id, unless it has already been cleared
+func (q *QueueT) resolve(requestId string) {
+	q.mu.Lock()
+	if q.pending == nil || q.pending.Id != requestId {
+		q.mu.Unlock()
+		return
+	}
+	q.pending = nil
+	q.decisionChan = nil
+	q.clearedChan = nil
+	onChange := q.onChange
+	q.mu.Unlock()
+	if onChange != nil {
+		onChange(nil)
+	}
+}
@@ -0,0 +1,109 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+// Submits candidates in the background, returning channels for the decision and error
+func submitInBackground(q *QueueT, ctx context.Context, timeout time.Duration) (chan DecisionT, chan error) {
+	decisionChan := make(chan DecisionT, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		decision, err := q.Submit(ctx, []string{"first", "second"}, 1, timeout, config.ApprovalActionSkip)
+		decisionChan <- decision
+		errChan <- err
+	}()
+	return decisionChan, errChan
+}
+
+func waitForPending(t *testing.T, q *QueueT) RequestT {
+	t.Helper()
+	for range 100 {
+		if request, exists := q.Pending(); exists {
+			return request
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no request was submitted")
+	return RequestT{}
+}
+
+func TestDecide(t *testing.T) {
+	q := &QueueT{}
+	decisionChan, errChan := submitInBackground(q, context.Background(), time.Minute)
+	request := waitForPending(t, q)
+
+	if err := q.Decide(request.Id, DecisionT{Action: config.ApprovalActionApprove, Candidate: 2}); !errors.Is(err, ErrInvalidDecision) {
+		t.Errorf("expected a decision for a missing candidate to be invalid, got %v", err)
+	}
+	if err := q.Decide("unknown", DecisionT{Action: config.ApprovalActionSkip}); !errors.Is(err, ErrNoPendingRequest) {
+		t.Errorf("expected a decision for an unknown request to fail, got %v", err)
+	}
+	if err := q.Decide(request.Id, DecisionT{Action: config.ApprovalActionApprove, Candidate: 0}); err != nil {
+		t.Fatalf("unable to decide - err: %v", err)
+	}
+	if decision := <-decisionChan; decision.Action != config.ApprovalActionApprove || decision.Candidate != 0 {
+		t.Errorf("unexpected decision %+v", decision)
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, exists := q.Pending(); exists {
+		t.Error("expected the request to be resolved")
+	}
+}
+
+func TestSubmitTimesOut(t *testing.T) {
+	q := &QueueT{}
+	decision, err := q.Submit(context.Background(), []string{"first", "second"}, 1, 10*time.Millisecond, config.ApprovalActionApprove)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !decision.TimedOut || decision.Action != config.ApprovalActionApprove || decision.Candidate != 1 {
+		t.Errorf("expected the suggested candidate to be approved on timeout, got %+v", decision)
+	}
+}
+
+func TestSubmitIsCancelledWithItsContext(t *testing.T) {
+	q := &QueueT{}
+	ctx, cancel := context.WithCancel(context.Background())
+	_, errChan := submitInBackground(q, ctx, time.Minute)
+	waitForPending(t, q)
+
+	cancel()
+	if err := <-errChan; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the submission to be cancelled, got %v", err)
+	}
+}
+
+func TestClearAllowsANewSubmission(t *testing.T) {
+	q := &QueueT{}
+	_, errChan := submitInBackground(q, context.Background(), time.Minute)
+	cleared := waitForPending(t, q)
+
+	q.Clear()
+	if _, exists := q.Pending(); exists {
+		t.Fatal("expected no pending request after clearing")
+	}
+
+	// the cleared submission returning must not resolve the new one
+	_, newErrChan := submitInBackground(q, context.Background(), time.Minute)
+	if err := <-errChan; !errors.Is(err, ErrRequestCleared) {
+		t.Errorf("expected the cleared submission to fail, got %v", err)
+	}
+	request := waitForPending(t, q)
+	if request.Id == cleared.Id {
+		t.Fatal("expected a new request")
+	}
+	if err := q.Decide(request.Id, DecisionT{Action: config.ApprovalActionSkip}); err != nil {
+		t.Errorf("unable to decide the new request - err: %v", err)
+	}
+	if err := <-newErrChan; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
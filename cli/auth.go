@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+// Authenticates with Twitch using a device code, so no browser or redirect listener is needed on this machine
+func runAuth(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("auth", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	userName := flags.String("username", config.Preferences.TwitchConfig.UserName, "your Twitch username")
+	clientId := flags.String("client-id", config.Preferences.TwitchConfig.ClientId, "client ID of your Twitch application (client type Public)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *userName == "" || *clientId == "" {
+		fmt.Fprintln(stderr, "-username and -client-id are required, unless they are already saved in Tidal")
+		flags.Usage()
+		return 2
+	}
+
+	if *userName != config.Preferences.TwitchConfig.UserName || *clientId != config.Preferences.TwitchConfig.ClientId {
+		// credentials belong to a username and application, so they no longer apply
+		config.Preferences.TwitchConfig.UserName = *userName
+		config.Preferences.TwitchConfig.ClientId = *clientId
+		config.Preferences.TwitchConfig.ClientSecret = ""
+		config.Preferences.TwitchConfig.UserId = ""
+		config.Preferences.TwitchConfig.Credentials = config.CredentialsT{}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	deviceCode, err := twitch.RequestDeviceCode(ctx)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "On any device, go to %s and enter the code %s\n", deviceCode.VerificationUri, deviceCode.UserCode)
+	fmt.Fprintln(stderr, "waiting for you to authorise Tidal (press Ctrl+C to cancel)...")
+
+	userAccessTokenInfo, err := twitch.PollDeviceCodeToken(ctx, deviceCode)
+	if err != nil {
+		fmt.Fprintf(stderr, "authentication failed - err: %v\n", err)
+		return 1
+	}
+	if err := twitch.SaveAuthentication(userAccessTokenInfo); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Authenticated as %s\n", config.Preferences.TwitchConfig.UserName)
+	return 0
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// A subcommand run from the command line instead of opening the GUI
+type commandT struct {
+	name        string
+	description string
+	run         func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = []commandT{
+	{"eval", "Run an AI-generated variable's prompt several times and report on the results", runEval},
+	{"auth", "Authenticate with Twitch using a device code, without a browser on this machine", runAuth},
+}
+
+// Returns whether the arguments name a subcommand, rather than asking for the GUI
+func IsCommand(args []string) bool {
+	return len(args) > 0 && slices.ContainsFunc(commands, func(c commandT) bool { return c.name == args[0] })
+}
+
+// Runs the subcommand named by the first argument, returning the exit code
+func Run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return 2
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], os.Stdout, os.Stderr)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: tidal [command] [flags]")
+	fmt.Fprintln(w, "Run without a command to open Tidal. Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.description)
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/eval"
+)
+
+const defaultEvalTimeoutSeconds = 30
+
+func runEval(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	variableName := flags.String("variable", "", "name of the AI-generated variable to evaluate (required)")
+	runs := flags.Int("runs", eval.DefaultRuns, fmt.Sprintf("number of times to run the prompt (max %v)", eval.MaxRuns))
+	snapshotsPath := flags.String("snapshots", "", "JSON file of [{\"name\", \"values\"}] snapshots to substitute into the prompt (defaults to the most recently recorded values)")
+	timeoutSeconds := flags.Int("timeout", defaultEvalTimeoutSeconds, "seconds to wait for each response")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *variableName == "" {
+		fmt.Fprintln(stderr, "-variable is required")
+		flags.Usage()
+		return 2
+	}
+
+	_, aiGeneratedVariablesMap := config.GetAllAiGeneratedVariables()
+	variable, exists := aiGeneratedVariablesMap[*variableName]
+	if !exists {
+		fmt.Fprintf(stderr, "AI-generated variable %q does not exist\n", *variableName)
+		flags.Usage()
+		return 2
+	}
+
+	req, err := eval.NewRequest(config.Preferences, variable, *runs, *snapshotsPath, time.Duration(*timeoutSeconds)*time.Second)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := eval.Run(ctx, req, func(done int, total int) {
+		fmt.Fprintf(stderr, "\rrun %v of %v", done, total)
+	})
+	fmt.Fprintln(stderr)
+	fmt.Fprint(stdout, report.String())
+	if err != nil {
+		fmt.Fprintf(stderr, "evaluation stopped early - err: %v\n", err)
+		return 1
+	}
+	return 0
+}
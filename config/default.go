@@ -1,55 +1,132 @@
-package config
-
-var defaultPreferences PreferencesFormat = PreferencesFormat{
-	TwitchConfig: TwitchConfigT{
-		UserName:          "",
-		UserId:            "",
-		ClientId:          "",
-		ClientSecret:      "",
-		ClientRedirectUri: "",
-		Credentials: CredentialsT{
-			UserAccessToken:        "",
-			UserAccessRefreshToken: "",
-			UserAccessScope:        []string{},
-			ExpiryUnixTimestamp:    0,
-		},
-	},
-	TwitchVariables: TwitchVariablesT{
-		StreamCategory: TwitchVariableT{
-			Value:       "",
-			Description: "Game or category currently being streamed",
-		},
-		StreamUptime: TwitchVariableT{
-			Value:       "",
-			Description: "Current stream duration, in seconds",
-		},
-		NumViewers: TwitchVariableT{
-			Value:       "",
-			Description: "Current number of viewers of the stream",
-		},
-		NumSubscribers: TwitchVariableT{
-			Value:       "",
-			Description: "Current number of subscribers to the channel",
-		},
-		NumFollowers: TwitchVariableT{
-			Value:       "",
-			Description: "Current number of followers of the channel",
-		},
-	},
-	LlmConfig: LlmConfigT{
-		DefaultPromptSuffix: "Ensure that your response only contains text relevant to the above information. " +
-			"Do not exceed 100 characters. " +
-			"Ensure your response does not contain profanities and cannot be construed as political or divisive.",
-	},
-	AiGeneratedVariables: []LlmVariableT{},
-	Title: TitleT{
-		Value:                           "",
-		TitleTemplate:                   "",
-		TitleUpdateIntervalMinutes:      1,
-		SendChatMessagePerTitleUpdate:   true,
-		UpdateImmediatelyOnStart:        true,
-		ThrowErrorIfEmptyVariable:       true,
-		ThrowErrorIfNonExistentVariable: true,
-		ThrowErrorIfTooLong:             true,
-	},
-}
+package config
+
+var defaultPreferences PreferencesFormat = PreferencesFormat{
+	TwitchConfig: TwitchConfigT{
+		UserName:          "",
+		UserId:            "",
+		ClientId:          "",
+		ClientSecret:      "",
+		ClientRedirectUri: "",
+		Credentials: CredentialsT{
+			UserAccessToken:        "",
+			UserAccessRefreshToken: "",
+			UserAccessScope:        []string{},
+			ExpiryUnixTimestamp:    0,
+		},
+		ApiBaseUrl:  "",
+		AuthBaseUrl: "",
+	},
+	TwitchVariables: TwitchVariablesT{
+		StreamCategory: TwitchVariableT{
+			Value:       "",
+			Description: "Game or category currently being streamed",
+		},
+		StreamUptime: TwitchVariableT{
+			Value:       "",
+			Description: "Current stream duration, in seconds",
+		},
+		NumViewers: TwitchVariableT{
+			Value:       "",
+			Description: "Current number of viewers of the stream",
+		},
+		NumSubscribers: TwitchVariableT{
+			Value:       "",
+			Description: "Current number of subscribers to the channel",
+		},
+		NumFollowers: TwitchVariableT{
+			Value:       "",
+			Description: "Current number of followers of the channel",
+		},
+	},
+	LlmConfig: LlmConfigT{
+		DefaultPromptSuffix: "Ensure that your response only contains text relevant to the above information. " +
+			"Do not exceed 100 characters. " +
+			"Ensure your response does not contain profanities and cannot be construed as political or divisive.",
+		ProviderProfiles: []LlmProviderProfileT{},
+		Prices:           DefaultLlmPrices,
+		Budget:           LlmBudgetT{},
+	},
+	AiGeneratedVariables: []LlmVariableT{},
+	Title: TitleT{
+		Value:                           "",
+		TitleTemplate:                   "",
+		TitleUpdateIntervalMinutes:      1,
+		SendChatMessagePerTitleUpdate:   true,
+		CreateMarkerPerTitleUpdate:      false,
+		UpdateImmediatelyOnStart:        true,
+		ThrowErrorIfEmptyVariable:       true,
+		ThrowErrorIfNonExistentVariable: true,
+		ThrowErrorIfTooLong:             true,
+		Candidates: TitleCandidatesConfigT{
+			NumCandidates:      1,
+			Scorers:            []string{TitleScorerLength, TitleScorerModeration, TitleScorerNovelty},
+			JudgePrompt:        DefaultTitleJudgePrompt,
+			JudgeProviderChain: []string{},
+		},
+		Approval: TitleApprovalConfigT{
+			Enabled:         false,
+			TimeoutSeconds:  DefaultApprovalTimeoutSeconds,
+			OnTimeoutAction: ApprovalActionSkip,
+		},
+	},
+	TitleHistory: []TitleHistoryEntryT{},
+	ControlServer: ControlServerConfigT{
+		Enabled: false,
+		Port:    DefaultControlServerPort,
+		Token:   "",
+	},
+	Moderation: ModerationConfigT{
+		Enabled:            true,
+		SevereBlocklist:    []string{},
+		MildBlocklist:      []string{},
+		Allowlist:          []string{},
+		NormaliseLeetspeak: true,
+		SevereAction:       ModerationActionRegenerate,
+		MildAction:         ModerationActionMask,
+		UseTwitchAutoMod:   false,
+	},
+	ChannelProfiles: []ChannelProfileT{},
+	Tags: TagsConfigT{
+		Enabled:               false,
+		VariableName:          "",
+		AlwaysOnTags:          []string{},
+		UpdateIntervalMinutes: 30,
+	},
+}
+
+// Failure policy given to newly created AI-generated variables
+var DefaultLlmFailurePolicy LlmFailurePolicyT = LlmFailurePolicyT{
+	MaxRetries:          1,
+	RetryBackoffSeconds: 2,
+	OnFailure:           FailureActionReuseLastValue,
+	FallbackValue:       "",
+	BackupPool:          []string{},
+}
+
+// Output processing given to newly created AI-generated variables
+var DefaultLlmOutputConfig LlmOutputConfigT = LlmOutputConfigT{
+	StripWrappingQuotes: true,
+	StripMarkdown:       true,
+	StripPreamble:       true,
+	CollapseNewlines:    true,
+	MaxCharacters:       0,
+	ValidationRegex:     "",
+	MaxAttempts:         2,
+}
+
+// History settings given to newly created AI-generated variables
+var DefaultLlmHistoryConfig LlmHistoryConfigT = LlmHistoryConfigT{
+	Size:                5,
+	IncludeInPrompt:     true,
+	SimilarityThreshold: 0.8,
+	SimilarityMethod:    SimilarityMethodNgramOverlap,
+}
+
+// Published prices of the default Gemini models, per million tokens
+var DefaultLlmPrices []LlmPriceT = []LlmPriceT{
+	{Model: "gemini-2.0-flash", InputPerMillionTokens: 0.10, OutputPerMillionTokens: 0.40},
+	{Model: "gemini-2.0-flash-lite", InputPerMillionTokens: 0.075, OutputPerMillionTokens: 0.30},
+}
+
+const DefaultTitleJudgePrompt = "Rate each of these Twitch stream titles from 0 to 10, " +
+	"based on how funny, engaging and appropriate for the stream they are."
@@ -1,84 +1,460 @@
-package config
-
-import "github.com/finahdinner/tidal/helpers"
-
-type PreferencesFormat struct {
-	TwitchConfig    TwitchConfigT    `json:"twitch_config"`
-	TwitchVariables TwitchVariablesT `json:"twitch_variables"`
-	// TwitchVariableUpdateIntervalSeconds int              `json:"twitch_variable_update_interval_seconds"`
-	LlmConfig            LlmConfigT     `json:"llm_config"`
-	AiGeneratedVariables []LlmVariableT `json:"ai_generated_variables"`
-	Title                TitleT         `json:"title_config"`
-}
-
-type TwitchConfigT struct {
-	UserName          string       `json:"user_name"`
-	UserId            string       `json:"user_id"`
-	ClientId          string       `json:"client_id"`
-	ClientSecret      string       `json:"client_secret"`
-	ClientRedirectUri string       `json:"client_redirect_uri"`
-	Credentials       CredentialsT `json:"credentials"`
-}
-
-type CredentialsT struct {
-	UserAccessToken        string   `json:"user_access_token"`
-	UserAccessRefreshToken string   `json:"user_refresh_token"`
-	UserAccessScope        []string `json:"user_access_scope"`
-	ExpiryUnixTimestamp    int64    `json:"expiry_unix_timestamp"`
-}
-
-type TwitchVariablesT struct {
-	StreamCategory TwitchVariableT `json:"stream_category"`
-	StreamUptime   TwitchVariableT `json:"stream_uptime"`
-	NumViewers     TwitchVariableT `json:"num_viewers"`
-	NumSubscribers TwitchVariableT `json:"num_subscribers"`
-	NumFollowers   TwitchVariableT `json:"num_followers"`
-}
-
-type TwitchVariableT struct {
-	Value       string `json:"value"`
-	Description string `json:"description"`
-}
-
-type LlmConfigT struct {
-	Provider            string `json:"provider"`
-	ApiKey              string `json:"api_key"`
-	DefaultPromptSuffix string `json:"default_prompt_suffix"`
-}
-
-type LlmVariableT struct {
-	Name         string `json:"name"`
-	Value        string `json:"value"`
-	PromptMain   string `json:"prompt_main"`
-	PromptSuffix string `json:"prompt_suffix"`
-}
-
-type TitleT struct {
-	Value                           string `json:"value"`
-	TitleTemplate                   string `json:"title_template"`
-	TitleUpdateIntervalMinutes      int    `json:"title_update_interval_minutes"`
-	SendChatMessagePerTitleUpdate   bool   `json:"send_chat_message_per_title_update"`
-	UpdateImmediatelyOnStart        bool   `json:"update_immediately_on_start"`
-	ThrowErrorIfEmptyVariable       bool   `json:"throw_error_if_empty_variable"`
-	ThrowErrorIfNonExistentVariable bool   `json:"throw_error_if_non_existent_variable"`
-	ThrowErrorIfTooLong             bool   `json:"throw_error_if_too_long"`
-}
-
-// Ensure fields are populated enough to make requests to update twitch variables
-func (pf *PreferencesFormat) HasPopulatedTwitchCredentials() bool {
-	return pf.TwitchConfig.UserName != "" &&
-		pf.TwitchConfig.UserId != "" &&
-		pf.TwitchConfig.ClientId != "" &&
-		pf.TwitchConfig.ClientSecret != "" &&
-		pf.TwitchConfig.ClientRedirectUri != "" &&
-		pf.TwitchConfig.Credentials.UserAccessToken != "" &&
-		pf.TwitchConfig.Credentials.UserAccessRefreshToken != "" &&
-		len(pf.TwitchConfig.Credentials.UserAccessScope) > 0
-}
-
-// Ensure Title config fields are populated enough start Tidal
-func (pf *PreferencesFormat) HasPopulatedTitleConfig() bool {
-	return pf.Title.TitleTemplate != "" &&
-		pf.Title.TitleUpdateIntervalMinutes >= helpers.MinTitleUpdateIntervalMinutes &&
-		pf.Title.TitleUpdateIntervalMinutes <= helpers.MaxTitleUpdateIntervalMinutes
-}
+package config
+
+import (
+	"slices"
+	"time"
+
+	"github.com/finahdinner/tidal/helpers"
+)
+
+const (
+	DefaultProviderProfileName    = "Default"
+	DefaultControlServerPort      = 4319
+	DefaultApprovalTimeoutSeconds = 120
+	DefaultKnowledgeMaxTokens     = 300
+	GroupFieldSeparator           = "."
+)
+
+type PreferencesFormat struct {
+	TwitchConfig    TwitchConfigT    `json:"twitch_config"`
+	TwitchVariables TwitchVariablesT `json:"twitch_variables"`
+	// TwitchVariableUpdateIntervalSeconds int              `json:"twitch_variable_update_interval_seconds"`
+	LlmConfig            LlmConfigT           `json:"llm_config"`
+	AiGeneratedVariables []LlmVariableT       `json:"ai_generated_variables"`
+	Title                TitleT               `json:"title_config"`
+	TitleHistory         []TitleHistoryEntryT `json:"title_history"` // most recent last
+	Moderation           ModerationConfigT    `json:"moderation"`
+	ChannelProfiles      []ChannelProfileT    `json:"channel_profiles"` // the first active profile is applied with each title update
+	Tags                 TagsConfigT          `json:"tags_config"`
+	ControlServer        ControlServerConfigT `json:"control_server"`
+}
+
+type TwitchConfigT struct {
+	UserName          string       `json:"user_name"`
+	UserId            string       `json:"user_id"`
+	ClientId          string       `json:"client_id"`
+	ClientSecret      string       `json:"client_secret"`
+	ClientRedirectUri string       `json:"client_redirect_uri"`
+	Credentials       CredentialsT `json:"credentials"`
+	ApiBaseUrl        string       `json:"api_base_url"`  // empty uses the real Helix API, e.g. http://localhost:8080/mock for the Twitch CLI mock-api
+	AuthBaseUrl       string       `json:"auth_base_url"` // empty uses the real Twitch OAuth server, e.g. http://localhost:8080/auth for the Twitch CLI mock-api
+}
+
+type CredentialsT struct {
+	UserAccessToken        string   `json:"user_access_token"`
+	UserAccessRefreshToken string   `json:"user_refresh_token"`
+	UserAccessScope        []string `json:"user_access_scope"`
+	ExpiryUnixTimestamp    int64    `json:"expiry_unix_timestamp"`
+}
+
+type TwitchVariablesT struct {
+	StreamCategory TwitchVariableT `json:"stream_category"`
+	StreamUptime   TwitchVariableT `json:"stream_uptime"`
+	NumViewers     TwitchVariableT `json:"num_viewers"`
+	NumSubscribers TwitchVariableT `json:"num_subscribers"`
+	NumFollowers   TwitchVariableT `json:"num_followers"`
+}
+
+type TwitchVariableT struct {
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+type LlmConfigT struct {
+	Provider            string                `json:"provider"`
+	ApiKey              string                `json:"api_key"`
+	DefaultPromptSuffix string                `json:"default_prompt_suffix"`
+	ProviderProfiles    []LlmProviderProfileT `json:"provider_profiles"`
+	Prices              []LlmPriceT           `json:"prices"`
+	Budget              LlmBudgetT            `json:"budget"`
+}
+
+// Cost of a model in USD, used to estimate spend from token usage
+type LlmPriceT struct {
+	Model                  string  `json:"model"`
+	InputPerMillionTokens  float64 `json:"input_per_million_tokens"`
+	OutputPerMillionTokens float64 `json:"output_per_million_tokens"`
+}
+
+// Spending caps in USD - a limit of 0 means no limit
+type LlmBudgetT struct {
+	DailyLimitUsd   float64 `json:"daily_limit_usd"`
+	MonthlyLimitUsd float64 `json:"monthly_limit_usd"`
+}
+
+// Returns the price of a model, if it is in the price table
+func (lc LlmConfigT) GetPrice(model string) (LlmPriceT, bool) {
+	prices := lc.Prices
+	if prices == nil {
+		prices = DefaultLlmPrices // preferences saved before prices existed
+	}
+	for _, p := range prices {
+		if p.Model == model {
+			return p, true
+		}
+	}
+	return LlmPriceT{}, false
+}
+
+// A named LLM provider configuration which AI-generated variables can select
+type LlmProviderProfileT struct {
+	Name       string `json:"name"`
+	Provider   string `json:"provider"`
+	ApiKey     string `json:"api_key"`
+	Model      string `json:"model"`
+	BaseUrl    string `json:"base_url"`
+	ScriptPath string `json:"script_path"` // only used by the mock provider
+}
+
+type LlmVariableT struct {
+	Name          string            `json:"name"`
+	Value         string            `json:"value"`
+	PromptMain    string            `json:"prompt_main"`
+	PromptSuffix  string            `json:"prompt_suffix"`
+	ProviderChain []string          `json:"provider_chain"` // profile names, tried in order
+	FailurePolicy LlmFailurePolicyT `json:"failure_policy"`
+	Output        LlmOutputConfigT  `json:"output"`
+	History       LlmHistoryConfigT `json:"history"`
+	Refresh       LlmRefreshPolicyT `json:"refresh"`
+	Image         LlmImageConfigT   `json:"image"`
+	Knowledge     LlmKnowledgeT     `json:"knowledge"`
+	Mode          string            `json:"mode"`          // empty behaves as VariableModeText
+	Fields        []string          `json:"fields"`        // if set, this is a group whose value is a JSON object with these fields
+	RecentValues  []string          `json:"recent_values"` // most recent last
+}
+
+const (
+	VariableModeText = "Text"
+	VariableModeTags = "Stream Tags" // a comma-separated list of tags which follow twitch's tag rules
+)
+
+var VariableModes = []string{VariableModeText, VariableModeTags}
+
+// Post-processing and validation applied to each LLM response before it is used
+type LlmOutputConfigT struct {
+	StripWrappingQuotes bool   `json:"strip_wrapping_quotes"`
+	StripMarkdown       bool   `json:"strip_markdown"`
+	StripPreamble       bool   `json:"strip_preamble"` // e.g. "Here's a joke:"
+	CollapseNewlines    bool   `json:"collapse_newlines"`
+	MaxCharacters       int    `json:"max_characters"` // 0 means no limit
+	ValidationRegex     string `json:"validation_regex"`
+	MaxAttempts         int    `json:"max_attempts"` // re-prompts with the violation explained, up to this many attempts
+}
+
+const (
+	FailureActionStopTidal      = "Stop Tidal"
+	FailureActionReuseLastValue = "Reuse Last Value"
+	FailureActionFallbackValue  = "Use Fallback Value"
+	FailureActionBackupPool     = "Pick From Backup Pool"
+	FailureActionDropSection    = "Drop Template Section"
+)
+
+var FailureActions = []string{
+	FailureActionStopTidal,
+	FailureActionReuseLastValue,
+	FailureActionFallbackValue,
+	FailureActionBackupPool,
+	FailureActionDropSection,
+}
+
+// What to do when an AI-generated variable cannot be generated.
+// An empty OnFailure behaves as FailureActionStopTidal.
+type LlmFailurePolicyT struct {
+	MaxRetries          int      `json:"max_retries"`
+	RetryBackoffSeconds int      `json:"retry_backoff_seconds"` // doubles after each retry
+	OnFailure           string   `json:"on_failure"`
+	FallbackValue       string   `json:"fallback_value"`
+	BackupPool          []string `json:"backup_pool"`
+}
+
+const (
+	SimilarityMethodEditDistance = "Edit Distance"
+	SimilarityMethodNgramOverlap = "N-gram Overlap"
+)
+
+var SimilarityMethods = []string{SimilarityMethodEditDistance, SimilarityMethodNgramOverlap}
+
+// How an AI-generated variable avoids repeating its recent values
+type LlmHistoryConfigT struct {
+	Size                int     `json:"size"` // number of recent values to remember
+	IncludeInPrompt     bool    `json:"include_in_prompt"`
+	SimilarityThreshold float64 `json:"similarity_threshold"` // between 0 and 1 - 0 disables the check
+	SimilarityMethod    string  `json:"similarity_method"`
+}
+
+const (
+	RefreshModeEveryCycle     = "Every Cycle"
+	RefreshModeInterval       = "Every N Minutes"
+	RefreshModeCategoryChange = "On Category Change"
+	RefreshModeInputChange    = "When Inputs Change"
+)
+
+var RefreshModes = []string{RefreshModeEveryCycle, RefreshModeInterval, RefreshModeCategoryChange, RefreshModeInputChange}
+
+// When an AI-generated variable is regenerated, rather than reusing its cached value
+type LlmRefreshPolicyT struct {
+	Mode            string `json:"mode"`
+	IntervalMinutes int    `json:"interval_minutes"` // only used by RefreshModeInterval
+}
+
+const (
+	ImageSourceNone            = "None"
+	ImageSourceStreamThumbnail = "Stream Thumbnail"
+	ImageSourceScreenshotFile  = "Screenshot File"
+)
+
+var ImageSources = []string{ImageSourceNone, ImageSourceStreamThumbnail, ImageSourceScreenshotFile}
+
+// An image attached to an AI-generated variable's prompt, so the LLM can see what is on screen
+type LlmImageConfigT struct {
+	Source   string `json:"source"`
+	FilePath string `json:"file_path"` // only used by ImageSourceScreenshotFile
+}
+
+func (ic LlmImageConfigT) Enabled() bool {
+	return ic.Source != "" && ic.Source != ImageSourceNone
+}
+
+// Knowledge base documents whose most relevant snippets are prepended to an AI-generated variable's prompt
+type LlmKnowledgeT struct {
+	Documents     []string `json:"documents"`      // file names within the knowledge base folder
+	AlwaysInclude []string `json:"always_include"` // documents added whatever the prompt, such as general lore or banned topics
+	MaxTokens     int      `json:"max_tokens"`     // approximate budget for the snippets
+}
+
+type TitleT struct {
+	Value                           string                 `json:"value"`
+	TitleTemplate                   string                 `json:"title_template"`
+	TitleUpdateIntervalMinutes      int                    `json:"title_update_interval_minutes"`
+	SendChatMessagePerTitleUpdate   bool                   `json:"send_chat_message_per_title_update"`
+	CreateMarkerPerTitleUpdate      bool                   `json:"create_marker_per_title_update"`
+	UpdateImmediatelyOnStart        bool                   `json:"update_immediately_on_start"`
+	ThrowErrorIfEmptyVariable       bool                   `json:"throw_error_if_empty_variable"`
+	ThrowErrorIfNonExistentVariable bool                   `json:"throw_error_if_non_existent_variable"`
+	ThrowErrorIfTooLong             bool                   `json:"throw_error_if_too_long"`
+	Candidates                      TitleCandidatesConfigT `json:"candidates"`
+	Approval                        TitleApprovalConfigT   `json:"approval"`
+}
+
+const (
+	ApprovalActionApprove    = "approve"
+	ApprovalActionEdit       = "edit"
+	ApprovalActionRegenerate = "regenerate"
+	ApprovalActionSkip       = "skip"
+)
+
+// Whether titles must be approved by the streamer before they are published
+type TitleApprovalConfigT struct {
+	Enabled         bool   `json:"enabled"`
+	TimeoutSeconds  int    `json:"timeout_seconds"`
+	OnTimeoutAction string `json:"on_timeout_action"` // ApprovalActionApprove or ApprovalActionSkip
+}
+
+// Local http server which accepts title approvals from other tools
+type ControlServerConfigT struct {
+	Enabled bool   `json:"enabled"`
+	Port    int    `json:"port"`
+	Token   string `json:"token"`
+}
+
+const (
+	TitleScorerLength     = "Fits Length Limit" // favours titles short enough to be shown in full
+	TitleScorerModeration = "Passes Moderation"
+	TitleScorerNovelty    = "Least Similar To Recent Titles"
+	TitleScorerLlmJudge   = "LLM Judge"
+)
+
+var TitleScorers = []string{TitleScorerLength, TitleScorerModeration, TitleScorerNovelty, TitleScorerLlmJudge}
+
+// How several candidate titles are generated per cycle, and the best one chosen
+type TitleCandidatesConfigT struct {
+	NumCandidates      int      `json:"num_candidates"` // 1 disables candidate selection
+	Scorers            []string `json:"scorers"`        // each scores between 0 and 1 - the highest total wins
+	JudgePrompt        string   `json:"judge_prompt"`
+	JudgeProviderChain []string `json:"judge_provider_chain"`
+}
+
+type TitleHistoryEntryT struct {
+	Title                 string `json:"title"`
+	UpdatedUnixTimestamp  int64  `json:"updated_unix_timestamp"`
+	MarkerId              string `json:"marker_id"`               // empty if no stream marker was created
+	MarkerPositionSeconds int    `json:"marker_position_seconds"` // how far into the stream the marker is
+}
+
+const (
+	ContentLabelPolitics  = "Politics and Sensitive Social Issues"
+	ContentLabelDrugs     = "Drugs, Intoxication, or Excessive Tobacco Use"
+	ContentLabelGambling  = "Gambling"
+	ContentLabelProfanity = "Significant Profanity or Vulgarity"
+	ContentLabelSexual    = "Sexual Themes"
+	ContentLabelViolence  = "Violent and Graphic Depictions"
+)
+
+var ContentLabels = []string{
+	ContentLabelPolitics,
+	ContentLabelDrugs,
+	ContentLabelGambling,
+	ContentLabelProfanity,
+	ContentLabelSexual,
+	ContentLabelViolence,
+}
+
+// Channel settings applied alongside the title while the profile's schedule is active.
+// Every field except the name is optional - empty fields leave that setting unchanged on Twitch.
+type ChannelProfileT struct {
+	Name             string                  `json:"name"`
+	Enabled          bool                    `json:"enabled"`
+	TitleTemplate    string                  `json:"title_template"` // replaces the main title template while active
+	GameName         string                  `json:"game_name"`      // templatable, resolved to a Twitch category by name
+	Tags             []string                `json:"tags"`           // templatable
+	Language         string                  `json:"language"`       // ISO 639-1 code, e.g. en
+	SetContentLabels bool                    `json:"set_content_labels"`
+	ContentLabels    []string                `json:"content_labels"` // only used with SetContentLabels - all other labels are removed
+	Schedule         ChannelProfileScheduleT `json:"schedule"`
+}
+
+// AI-generated stream tags, applied on their own schedule rather than with each title update
+type TagsConfigT struct {
+	Enabled               bool     `json:"enabled"`
+	VariableName          string   `json:"variable_name"`  // an AI-generated variable in VariableModeTags
+	AlwaysOnTags          []string `json:"always_on_tags"` // applied before the generated tags
+	UpdateIntervalMinutes int      `json:"update_interval_minutes"`
+}
+
+const ScheduleTimeLayout = "15:04"
+
+// When a channel profile is active, in local time.
+// No days means every day, and no start and end time means all day.
+type ChannelProfileScheduleT struct {
+	Days      []string `json:"days"`       // e.g. Monday
+	StartTime string   `json:"start_time"` // HH:MM
+	EndTime   string   `json:"end_time"`   // HH:MM - before the start time for windows which run past midnight
+}
+
+const (
+	ModerationActionMask       = "Mask"
+	ModerationActionRegenerate = "Regenerate"
+	ModerationActionReject     = "Reject"
+)
+
+var ModerationActions = []string{ModerationActionMask, ModerationActionRegenerate, ModerationActionReject}
+
+// Checks applied to AI-generated values and to the final title before it is published
+type ModerationConfigT struct {
+	Enabled            bool     `json:"enabled"`
+	SevereBlocklist    []string `json:"severe_blocklist"`
+	MildBlocklist      []string `json:"mild_blocklist"`
+	Allowlist          []string `json:"allowlist"` // words which are never blocked, even if they contain a blocked term
+	NormaliseLeetspeak bool     `json:"normalise_leetspeak"`
+	SevereAction       string   `json:"severe_action"`
+	MildAction         string   `json:"mild_action"`
+	UseTwitchAutoMod   bool     `json:"use_twitch_automod"`
+}
+
+// Ensure fields are populated enough to make requests to update twitch variables.
+// The client secret and redirect URI are only needed to authenticate in the browser, not with a device code.
+func (pf *PreferencesFormat) HasPopulatedTwitchCredentials() bool {
+	return pf.TwitchConfig.UserName != "" &&
+		pf.TwitchConfig.UserId != "" &&
+		pf.TwitchConfig.ClientId != "" &&
+		pf.TwitchConfig.Credentials.UserAccessToken != "" &&
+		pf.TwitchConfig.Credentials.UserAccessRefreshToken != "" &&
+		len(pf.TwitchConfig.Credentials.UserAccessScope) > 0
+}
+
+// Profile representing the top-level provider and api key, used when a variable has no provider chain
+func (lc *LlmConfigT) DefaultProviderProfile() LlmProviderProfileT {
+	return LlmProviderProfileT{
+		Name:     DefaultProviderProfileName,
+		Provider: lc.Provider,
+		ApiKey:   lc.ApiKey,
+	}
+}
+
+func (lc *LlmConfigT) GetProviderProfile(name string) (LlmProviderProfileT, bool) {
+	for _, profile := range lc.ProviderProfiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return LlmProviderProfileT{}, false
+}
+
+// Ensure Title config fields are populated enough start Tidal
+func (pf *PreferencesFormat) HasPopulatedTitleConfig() bool {
+	return pf.Title.TitleTemplate != "" &&
+		pf.Title.TitleUpdateIntervalMinutes >= helpers.MinTitleUpdateIntervalMinutes &&
+		pf.Title.TitleUpdateIntervalMinutes <= helpers.MaxTitleUpdateIntervalMinutes
+}
+
+func (v LlmVariableT) IsTags() bool {
+	return v.Mode == VariableModeTags
+}
+
+func (v LlmVariableT) IsGroup() bool {
+	return len(v.Fields) > 0
+}
+
+// Names the variable is referenced by - one per field for groups, e.g. Group.emoji
+func (v LlmVariableT) VariableNames() []string {
+	if !v.IsGroup() {
+		return []string{v.Name}
+	}
+	names := make([]string, 0, len(v.Fields))
+	for _, field := range v.Fields {
+		names = append(names, v.Name+GroupFieldSeparator+field)
+	}
+	return names
+}
+
+// Returns the names of the variables which are groups, whose fields are referenced as Group.field
+func GetGroupNames(variables []LlmVariableT) []string {
+	names := []string{}
+	for _, v := range variables {
+		if v.IsGroup() {
+			names = append(names, v.Name)
+		}
+	}
+	return names
+}
+
+// Returns whether the schedule covers t. Windows which run past midnight belong to the day they start on.
+func (s ChannelProfileScheduleT) IsActive(t time.Time) bool {
+	if s.StartTime == "" && s.EndTime == "" {
+		return s.coversDay(t.Weekday())
+	}
+	start, err := time.Parse(ScheduleTimeLayout, s.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(ScheduleTimeLayout, s.EndTime)
+	if err != nil {
+		return false
+	}
+	minuteOfDay := t.Hour()*60 + t.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute <= endMinute {
+		return minuteOfDay >= startMinute && minuteOfDay < endMinute && s.coversDay(t.Weekday())
+	}
+	if minuteOfDay >= startMinute {
+		return s.coversDay(t.Weekday())
+	}
+	// early morning, so the window started the day before
+	return minuteOfDay < endMinute && s.coversDay((t.Weekday()+6)%7)
+}
+
+func (s ChannelProfileScheduleT) coversDay(day time.Weekday) bool {
+	return len(s.Days) == 0 || slices.Contains(s.Days, day.String())
+}
+
+// Returns the first enabled channel profile whose schedule covers t
+func (pf *PreferencesFormat) ActiveChannelProfile(t time.Time) (ChannelProfileT, bool) {
+	for _, profile := range pf.ChannelProfiles {
+		if profile.Enabled && profile.Schedule.IsActive(t) {
+			return profile, true
+		}
+	}
+	return ChannelProfileT{}, false
+}
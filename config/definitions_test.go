@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestHasPopulatedTwitchCredentials(t *testing.T) {
+	// device code logins have no client secret or redirect URI
+	prefs := PreferencesFormat{TwitchConfig: TwitchConfigT{
+		UserName: "tidaltester",
+		UserId:   "1234",
+		ClientId: "public-client-id",
+		Credentials: CredentialsT{
+			UserAccessToken:        "access",
+			UserAccessRefreshToken: "refresh",
+			UserAccessScope:        []string{"channel:manage:broadcast"},
+		},
+	}}
+	if !prefs.HasPopulatedTwitchCredentials() {
+		t.Error("expected device code credentials to be populated")
+	}
+
+	prefs.TwitchConfig.Credentials.UserAccessToken = ""
+	if prefs.HasPopulatedTwitchCredentials() {
+		t.Error("expected credentials without an access token not to be populated")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"sync"
 
 	"github.com/finahdinner/tidal/helpers"
 )
@@ -13,13 +15,54 @@ const preferencesFileName = "preferences.json"
 var appPreferencesPath string
 var Preferences PreferencesFormat = defaultPreferences
 
+// Guards Preferences against the background updaters and token refreshes, which change it concurrently
+var preferencesMu sync.Mutex
+
 func SavePreferences() error {
+	preferencesMu.Lock()
+	defer preferencesMu.Unlock()
+	return savePreferences()
+}
+
+func savePreferences() error {
 	if err := writeJsonIfSuccessful(appPreferencesPath, Preferences); err != nil {
 		return err
 	}
 	return nil
 }
 
+// Returns a copy of the preferences which is safe to read while they change.
+// The AI-generated variables are cloned, so changing the copy's variables does not change the preferences.
+func SnapshotPreferences() PreferencesFormat {
+	preferencesMu.Lock()
+	defer preferencesMu.Unlock()
+	prefs := Preferences
+	prefs.AiGeneratedVariables = slices.Clone(Preferences.AiGeneratedVariables)
+	return prefs
+}
+
+// Applies update to the preferences and saves them, undoing the update if they cannot be saved.
+// Only the fields update changes are written, so changes made elsewhere in the meantime are kept.
+func UpdatePreferences(update func(prefs *PreferencesFormat)) error {
+	preferencesMu.Lock()
+	defer preferencesMu.Unlock()
+	prevPrefs := Preferences
+	prevPrefs.AiGeneratedVariables = slices.Clone(Preferences.AiGeneratedVariables)
+	update(&Preferences)
+	if err := savePreferences(); err != nil {
+		Preferences = prevPrefs
+		return err
+	}
+	return nil
+}
+
+// Returns the saved twitch credentials, which are refreshed in the background
+func GetCredentials() CredentialsT {
+	preferencesMu.Lock()
+	defer preferencesMu.Unlock()
+	return Preferences.TwitchConfig.Credentials
+}
+
 func GetPreferences() (PreferencesFormat, error) {
 	prefs := PreferencesFormat{}
 	data, err := os.ReadFile(appPreferencesPath)
@@ -72,7 +115,7 @@ func GetAllAiGeneratedVariables() ([]string, map[string]LlmVariableT) {
 	varSlice := make([]string, 0, len(Preferences.AiGeneratedVariables))
 	varMap := make(map[string]LlmVariableT)
 	for _, v := range Preferences.AiGeneratedVariables {
-		varSlice = append(varSlice, v.Name)
+		varSlice = append(varSlice, v.VariableNames()...)
 		varMap[v.Name] = v
 	}
 	return varSlice, varMap
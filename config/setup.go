@@ -1,66 +1,115 @@
-package config
-
-import (
-	"log"
-	"os"
-	"path"
-)
-
-const AppName = "tidal"
-
-var AppConfigDir string
-var AppLogFilePath string
-
-func init() {
-	var err error
-	AppConfigDir, err = getAppConfigDir()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// config file
-	appPreferencesPath = path.Join(AppConfigDir, preferencesFileName)
-	if fileExists(appPreferencesPath) {
-		Preferences, err = GetPreferences()
-		if err != nil {
-			log.Fatalf("unable to load preferences from disk: %v", err)
-		}
-	} else {
-		err = SavePreferences()
-		if err != nil {
-			log.Fatalf("unable to save/load default preferences: %v", err)
-		}
-	}
-
-	// create general logger
-	AppLogFilePath = path.Join(AppConfigDir, logFileName)
-	Logger, err = newTidalLogger(AppLogFilePath)
-	if err != nil {
-		log.Fatalf("unable to create logger: %v", err)
-	}
-}
-
-func dirExists(path string) bool {
-	fileInfo, err := os.Stat(path)
-	if err != nil {
-		return false
-	}
-	return fileInfo.IsDir()
-}
-
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
-}
-
-func getAppConfigDir() (string, error) {
-	globalConfigDir, err := os.UserConfigDir()
-	if err != nil {
-		return "", err
-	}
-	appConfigDir := path.Join(globalConfigDir, AppName+"-config")
-	if !dirExists(appConfigDir) {
-		os.Mkdir(appConfigDir, 0755) // 0755 - owner can rwx, others can r-x
-	}
-	return appConfigDir, nil
-}
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"testing"
+)
+
+const AppName = "tidal"
+const knowledgeBaseDirName = "knowledge_base"
+const configDirEnvVar = "TIDAL_CONFIG_DIR" // overrides the user's config directory
+
+var AppConfigDir string
+var AppLogFilePath string
+var KnowledgeBaseDir string // markdown and text documents which AI-generated variables can draw from
+
+var testAppConfigDir string // created by init for tests, and removed once they use a directory of their own
+
+func init() {
+	appConfigDir, err := getAppConfigDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := UseAppConfigDir(appConfigDir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Loads (or creates) the preferences, knowledge base and log file in dir, instead of the user's config directory.
+// Tests use this to start from fresh preferences - init never loads the user's config directory during tests.
+func UseAppConfigDir(dir string) error {
+	var err error
+	prevAppConfigDir := AppConfigDir
+	AppConfigDir = dir
+
+	// config file
+	appPreferencesPath = path.Join(AppConfigDir, preferencesFileName)
+	if fileExists(appPreferencesPath) {
+		Preferences, err = GetPreferences()
+		if err != nil {
+			return fmt.Errorf("unable to load preferences from disk: %v", err)
+		}
+	} else {
+		Preferences = defaultPreferences
+		err = SavePreferences()
+		if err != nil {
+			return fmt.Errorf("unable to save/load default preferences: %v", err)
+		}
+	}
+
+	KnowledgeBaseDir = path.Join(AppConfigDir, knowledgeBaseDirName)
+	if !dirExists(KnowledgeBaseDir) {
+		os.Mkdir(KnowledgeBaseDir, 0755)
+	}
+
+	// create general logger
+	AppLogFilePath = path.Join(AppConfigDir, logFileName)
+	prevLogger := Logger
+	Logger, err = newTidalLogger(AppLogFilePath)
+	if err != nil {
+		return fmt.Errorf("unable to create logger: %v", err)
+	}
+	if prevLogger != nil {
+		if err := prevLogger.Close(); err != nil {
+			Logger.LogErrorf("unable to close the previous log file - err: %v", err)
+		}
+	}
+	if prevAppConfigDir != "" && prevAppConfigDir == testAppConfigDir && prevAppConfigDir != dir {
+		os.RemoveAll(prevAppConfigDir)
+		testAppConfigDir = ""
+	}
+	return nil
+}
+
+func dirExists(path string) bool {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return fileInfo.IsDir()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func getAppConfigDir() (string, error) {
+	// keep tests away from the user's own preferences and logs
+	if testing.Testing() {
+		dir, err := os.MkdirTemp("", AppName+"-test-*")
+		if err != nil {
+			return "", fmt.Errorf("unable to create a config directory for tests - err: %w", err)
+		}
+		testAppConfigDir = dir
+		return dir, nil
+	}
+	if dir := os.Getenv(configDirEnvVar); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("unable to create %v %v - err: %w", configDirEnvVar, dir, err)
+		}
+		return dir, nil
+	}
+	globalConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	appConfigDir := path.Join(globalConfigDir, AppName+"-config")
+	if !dirExists(appConfigDir) {
+		os.Mkdir(appConfigDir, 0755) // 0755 - owner can rwx, others can r-x
+	}
+	return appConfigDir, nil
+}
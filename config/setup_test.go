@@ -0,0 +1,31 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUseAppConfigDir(t *testing.T) {
+	// init never loads the user's own config directory during tests
+	initDir := AppConfigDir
+	if testAppConfigDir == "" || initDir != testAppConfigDir || !strings.HasPrefix(initDir, os.TempDir()) {
+		t.Fatalf("expected init to use a temporary config directory, got %q", initDir)
+	}
+
+	prevLogger := Logger
+	dir := t.TempDir()
+	if err := UseAppConfigDir(dir); err != nil {
+		t.Fatalf("unable to use config directory - err: %v", err)
+	}
+	if AppConfigDir != dir || !fileExists(appPreferencesPath) || !dirExists(KnowledgeBaseDir) {
+		t.Errorf("expected preferences and a knowledge base in %q", dir)
+	}
+	if err := prevLogger.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected the previous log file to have been closed, got %v", err)
+	}
+	if dirExists(initDir) {
+		t.Errorf("expected the temporary config directory from init to be removed")
+	}
+}
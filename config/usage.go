@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+const usageFileName = "usage.json"
+
+var ErrUsageHistoryMovedAside = errors.New("usage history could not be decoded, so it was moved aside")
+
+// Token usage and estimated cost, summed over some period
+type UsageTotalsT struct {
+	Calls        int     `json:"calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUsd      float64 `json:"cost_usd"`
+}
+
+// LLM usage per day, keyed by date (YYYY-MM-DD)
+type UsageHistoryT struct {
+	Days map[string]UsageTotalsT `json:"days"`
+}
+
+func (t *UsageTotalsT) Add(other UsageTotalsT) {
+	t.Calls += other.Calls
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.CostUsd += other.CostUsd
+}
+
+// A usage file which cannot be decoded is moved aside, rather than being overwritten by the next save,
+// and an empty history is returned along with an error wrapping ErrUsageHistoryMovedAside
+func GetUsageHistory() (UsageHistoryT, error) {
+	history := UsageHistoryT{Days: map[string]UsageTotalsT{}}
+	usagePath := path.Join(AppConfigDir, usageFileName)
+	data, err := os.ReadFile(usagePath)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return history, err
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		movedPath := fmt.Sprintf("%s.%s.corrupt", usagePath, time.Now().Format("20060102150405"))
+		if renameErr := os.Rename(usagePath, movedPath); renameErr != nil {
+			return history, fmt.Errorf("unable to decode %v or move it aside - err: %w", usagePath, errors.Join(err, renameErr))
+		}
+		return UsageHistoryT{Days: map[string]UsageTotalsT{}}, fmt.Errorf("%w to %v - err: %v", ErrUsageHistoryMovedAside, movedPath, err)
+	}
+	if history.Days == nil {
+		history.Days = map[string]UsageTotalsT{}
+	}
+	return history, nil
+}
+
+func SaveUsageHistory(history UsageHistoryT) error {
+	return writeJsonIfSuccessful(path.Join(AppConfigDir, usageFileName), history)
+}
@@ -0,0 +1,125 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/finahdinner/tidal/approval"
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	approvalPath    = "/approval"
+	shutdownTimeout = 5 * time.Second
+	tokenBytes      = 24
+)
+
+// A minimal http server on localhost, used to control Tidal from other tools (e.g. stream deck plugins)
+type ServerT struct {
+	httpServer *http.Server
+}
+
+type decideRequestT struct {
+	Id string `json:"id"`
+	approval.DecisionT
+}
+
+type errorResponseT struct {
+	Error string `json:"error"`
+}
+
+func GenerateToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate control server token - err: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Starts listening on localhost only. Every request must send the token as a bearer token.
+func Start(serverConfig config.ControlServerConfigT, queue *approval.QueueT) (*ServerT, error) {
+	if serverConfig.Token == "" {
+		return nil, errors.New("control server token must not be empty")
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%v", serverConfig.Port))
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on port %v - err: %w", serverConfig.Port, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+approvalPath, func(w http.ResponseWriter, r *http.Request) {
+		request, exists := queue.Pending()
+		if !exists {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJson(w, http.StatusOK, request)
+	})
+	mux.HandleFunc("POST "+approvalPath, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody decideRequestT
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			writeJson(w, http.StatusBadRequest, errorResponseT{Error: fmt.Sprintf("invalid request body - %v", err)})
+			return
+		}
+		err := queue.Decide(reqBody.Id, reqBody.DecisionT)
+		switch {
+		case errors.Is(err, approval.ErrNoPendingRequest):
+			writeJson(w, http.StatusNotFound, errorResponseT{Error: err.Error()})
+		case err != nil:
+			writeJson(w, http.StatusBadRequest, errorResponseT{Error: err.Error()})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	server := &ServerT{
+		httpServer: &http.Server{
+			Handler:           requireToken(serverConfig.Token, mux),
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+	go func() {
+		if err := server.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			config.Logger.LogErrorf("control server stopped - err: %v", err)
+		}
+	}()
+	config.Logger.LogInfof("control server listening on %v", listener.Addr())
+	return server, nil
+}
+
+func (s *ServerT) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("unable to shut down control server - err: %w", err)
+	}
+	return nil
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		providedToken, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(providedToken), []byte(token)) != 1 {
+			writeJson(w, http.StatusUnauthorized, errorResponseT{Error: "missing or invalid token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		config.Logger.LogErrorf("unable to write control server response - err: %v", err)
+	}
+}
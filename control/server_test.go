@@ -0,0 +1,113 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/finahdinner/tidal/approval"
+	"github.com/finahdinner/tidal/config"
+)
+
+const testToken = "test-token"
+
+func startTestServer(t *testing.T, queue *approval.QueueT) string {
+	t.Helper()
+	// find a free port for the server to listen on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server, err := Start(config.ControlServerConfigT{Enabled: true, Port: port, Token: testToken}, queue)
+	if err != nil {
+		t.Fatalf("unable to start control server - err: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	return fmt.Sprintf("http://127.0.0.1:%v%s", port, approvalPath)
+}
+
+func sendRequest(t *testing.T, method string, url string, token string, body any) *http.Response {
+	t.Helper()
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request to %v failed - err: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestStartRequiresToken(t *testing.T) {
+	if _, err := Start(config.ControlServerConfigT{Enabled: true}, &approval.QueueT{}); err == nil {
+		t.Error("expected a server without a token to fail to start")
+	}
+}
+
+func TestApproval(t *testing.T) {
+	queue := &approval.QueueT{}
+	url := startTestServer(t, queue)
+
+	if resp := sendRequest(t, http.MethodGet, url, "wrong-token", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an invalid token to be unauthorised, got %v", resp.Status)
+	}
+	if resp := sendRequest(t, http.MethodGet, url, testToken, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected no pending request, got %v", resp.Status)
+	}
+
+	decisionChan := make(chan approval.DecisionT, 1)
+	go func() {
+		decision, _ := queue.Submit(context.Background(), []string{"first", "second"}, 0, time.Minute, config.ApprovalActionSkip)
+		decisionChan <- decision
+	}()
+
+	var request approval.RequestT
+	for range 100 {
+		resp := sendRequest(t, http.MethodGet, url, testToken, nil)
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&request); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if request.Id == "" || len(request.Candidates) != 2 {
+		t.Fatalf("expected the pending request, got %+v", request)
+	}
+
+	invalid := decideRequestT{Id: request.Id, DecisionT: approval.DecisionT{Action: "Publish"}}
+	if resp := sendRequest(t, http.MethodPost, url, testToken, invalid); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an unknown action to be rejected, got %v", resp.Status)
+	}
+	unknown := decideRequestT{Id: "unknown", DecisionT: approval.DecisionT{Action: config.ApprovalActionSkip}}
+	if resp := sendRequest(t, http.MethodPost, url, testToken, unknown); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected an unknown request to be not found, got %v", resp.Status)
+	}
+
+	approve := decideRequestT{Id: request.Id, DecisionT: approval.DecisionT{Action: config.ApprovalActionApprove, Candidate: 1}}
+	if resp := sendRequest(t, http.MethodPost, url, testToken, approve); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the decision to be accepted, got %v", resp.Status)
+	}
+	if decision := <-decisionChan; decision.Action != config.ApprovalActionApprove || decision.Candidate != 1 {
+		t.Errorf("unexpected decision %+v", decision)
+	}
+}
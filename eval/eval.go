@@ -0,0 +1,283 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/llm"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+const (
+	MaxRuns     = 100
+	DefaultRuns = 10
+
+	currentSnapshotName = "current values"
+	usageSourceSuffix   = " (eval)"
+)
+
+// Values of stream variables and AI-generated variables to substitute into a prompt
+type SnapshotT struct {
+	Name   string            `json:"name"`
+	Values map[string]string `json:"values"` // variable name (e.g. StreamCategory, or Group.field) -> value
+}
+
+type RequestT struct {
+	Variable   config.LlmVariableT
+	Profiles   []config.LlmProviderProfileT
+	Snapshots  []SnapshotT // runs cycle through the snapshots
+	Runs       int
+	Timeout    time.Duration
+	Moderation config.ModerationConfigT
+}
+
+// The outcome of a single run
+type ResultT struct {
+	Snapshot      string
+	Value         string
+	Latency       time.Duration
+	ModerationHit bool
+	Violation     error // why the value would have been regenerated on stream
+	Err           error // the request itself failed
+}
+
+// Builds a request to evaluate a variable with its own provider chain and the current moderation settings.
+// Without a snapshots file, the most recently recorded values are used.
+func NewRequest(prefs config.PreferencesFormat, variable config.LlmVariableT, runs int, snapshotsPath string, timeout time.Duration) (RequestT, error) {
+	profiles, err := llm.GetProviderChain(prefs.LlmConfig, variable)
+	if err != nil {
+		return RequestT{}, fmt.Errorf("unable to get provider chain for %v - err: %w", variable.Name, err)
+	}
+	snapshots := []SnapshotT{CurrentSnapshot(prefs)}
+	if snapshotsPath != "" {
+		snapshots, err = LoadSnapshots(snapshotsPath)
+		if err != nil {
+			return RequestT{}, err
+		}
+	}
+	return RequestT{
+		Variable:   variable,
+		Profiles:   profiles,
+		Snapshots:  snapshots,
+		Runs:       runs,
+		Timeout:    timeout,
+		Moderation: prefs.Moderation,
+	}, nil
+}
+
+// Loads snapshots from a JSON file containing a list of {"name", "values"} objects
+func LoadSnapshots(path string) ([]SnapshotT, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read snapshots file %v - err: %w", path, err)
+	}
+	snapshots := []SnapshotT{}
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("unable to parse snapshots file %v - err: %w", path, err)
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("snapshots file %v contains no snapshots", path)
+	}
+	for idx := range snapshots {
+		if snapshots[idx].Name == "" {
+			snapshots[idx].Name = fmt.Sprintf("snapshot %v", idx+1)
+		}
+	}
+	return snapshots, nil
+}
+
+// A snapshot of the values most recently recorded while Tidal was running
+func CurrentSnapshot(prefs config.PreferencesFormat) SnapshotT {
+	values := map[string]string{}
+	twitchVariablesMap := helpers.GenerateMapFromHomogenousStruct[config.TwitchVariablesT, config.TwitchVariableT](prefs.TwitchVariables)
+	for name, v := range twitchVariablesMap {
+		values[name] = v.Value
+	}
+	for _, v := range prefs.AiGeneratedVariables {
+		maps.Copy(values, getNamedValues(v))
+	}
+	return SnapshotT{Name: currentSnapshotName, Values: values}
+}
+
+// Runs the variable's prompt the requested number of times, one run at a time so latencies are comparable.
+// onProgress (if set) is called after each run.
+func Run(ctx context.Context, req RequestT, onProgress func(done int, total int)) (ReportT, error) {
+	if req.Runs < 1 || req.Runs > MaxRuns {
+		return ReportT{}, fmt.Errorf("runs must be between 1 and %v", MaxRuns)
+	}
+	if len(req.Snapshots) == 0 {
+		return ReportT{}, errors.New("at least one snapshot is required")
+	}
+
+	knowledgeDocuments := []llm.KnowledgeDocumentT{}
+	if len(req.Variable.Knowledge.Documents) > 0 {
+		var err error
+		knowledgeDocuments, err = llm.LoadKnowledgeDocuments(config.KnowledgeBaseDir, req.Variable.Knowledge)
+		if err != nil {
+			return ReportT{}, err
+		}
+	}
+
+	report := ReportT{Variable: req.Variable.Name}
+	// as on stream, the prompt is sent without its image if the image cannot be loaded
+	images, err := loadImages(ctx, req.Variable)
+	if err != nil {
+		config.Logger.LogErrorf("unable to load image for %v - err: %v", req.Variable.Name, err)
+		report.ImageErr = err
+	}
+	for _, snapshot := range req.Snapshots {
+		for _, name := range getMissingVariableNames(req.Variable, snapshot) {
+			if !slices.Contains(report.MissingVariables, name) {
+				report.MissingVariables = append(report.MissingVariables, name)
+			}
+		}
+	}
+
+	for run := range req.Runs {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		snapshot := req.Snapshots[run%len(req.Snapshots)]
+		prompt := RenderPrompt(req.Variable, snapshot)
+		if len(knowledgeDocuments) > 0 {
+			prompt = llm.AddKnowledgeToPrompt(prompt, knowledgeDocuments, req.Variable.Knowledge.MaxTokens)
+		}
+		generationReq := llm.GenerationRequestT{
+			Variable:   req.Variable,
+			Profiles:   req.Profiles,
+			Prompt:     prompt,
+			Images:     images,
+			Timeout:    req.Timeout,
+			Moderation: req.Moderation,
+		}
+
+		result := ResultT{Snapshot: snapshot.Name}
+		startedAt := time.Now()
+		response, _, err := llm.GetResponseTextFromChain(
+			req.Profiles,
+			llm.PromptT{
+				Text:           llm.BuildPrompt(prompt, req.Variable),
+				ResponseFields: req.Variable.Fields,
+				Source:         req.Variable.Name + usageSourceSuffix,
+				Images:         images,
+			},
+			req.Timeout,
+		)
+		result.Latency = time.Since(startedAt)
+		if err != nil {
+			result.Err = err
+		} else {
+			// checked as on stream, but without being regenerated
+			result.Value, result.ModerationHit, result.Violation = llm.CheckResponse(response, generationReq)
+		}
+		report.Results = append(report.Results, result)
+
+		if onProgress != nil {
+			onProgress(run+1, req.Runs)
+		}
+		if errors.Is(err, llm.ErrBudgetExceeded) {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// Substitutes the snapshot's values into the variable's prompt, leaving unknown placeholders as they are
+func RenderPrompt(variable config.LlmVariableT, snapshot SnapshotT) string {
+	prompt := variable.PromptMain
+	if variable.PromptSuffix != "" {
+		prompt += "\n" + variable.PromptSuffix
+	}
+	return getPlaceholderRegex().ReplaceAllStringFunc(prompt, func(placeholder string) string {
+		name := helpers.GetVarNameFromPlaceholderString(placeholder)
+		if value, exists := snapshot.Values[name]; exists {
+			return value
+		}
+		// only groups have fields, so anything else is followed by plain text
+		if name, text, hasText := strings.Cut(name, config.GroupFieldSeparator); hasText {
+			if value, exists := snapshot.Values[name]; exists {
+				return value + config.GroupFieldSeparator + text
+			}
+		}
+		return placeholder
+	})
+}
+
+func getMissingVariableNames(variable config.LlmVariableT, snapshot SnapshotT) []string {
+	missing := []string{}
+	for _, name := range helpers.ExtractVariableNamesFromText(variable.PromptMain+"\n"+variable.PromptSuffix, getGroupNames(snapshot)) {
+		if _, exists := snapshot.Values[name]; !exists {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Snapshots store each field of a group as Group.field
+func getGroupNames(snapshot SnapshotT) []string {
+	names := []string{}
+	for name := range snapshot.Values {
+		if group, _, isField := strings.Cut(name, config.GroupFieldSeparator); isField && !slices.Contains(names, group) {
+			names = append(names, group)
+		}
+	}
+	return names
+}
+
+func getPlaceholderRegex() *regexp.Regexp {
+	return regexp.MustCompile(regexp.QuoteMeta(helpers.VarNamePlaceholderPrefix) + `\w+(?:\.\w+)?`)
+}
+
+// The value of a variable, keyed by each name it can be referenced by
+func getNamedValues(variable config.LlmVariableT) map[string]string {
+	values := map[string]string{}
+	placeholderValues, err := llm.GetPlaceholderValues(variable, variable.Value)
+	if err != nil {
+		return values // not generated yet
+	}
+	for placeholderStr, value := range placeholderValues {
+		values[helpers.GetVarNameFromPlaceholderString(placeholderStr)] = value
+	}
+	return values
+}
+
+// Loads the image attached to the variable's prompt, as it would be on stream
+func loadImages(ctx context.Context, variable config.LlmVariableT) ([]llm.ImageT, error) {
+	if !variable.Image.Enabled() {
+		return nil, nil
+	}
+	var image llm.ImageT
+	switch variable.Image.Source {
+	case config.ImageSourceStreamThumbnail:
+		data, err := twitch.GetStreamThumbnail(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if image, err = llm.NewImage(data); err != nil {
+			return nil, err
+		}
+	case config.ImageSourceScreenshotFile:
+		var err error
+		if image, err = llm.LoadImageFile(variable.Image.FilePath); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%q is not a valid image source", variable.Image.Source)
+	}
+	return []llm.ImageT{image}, nil
+}
+
+func valueLength(value string) int {
+	return utf8.RuneCountInString(value)
+}
@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/llm"
+)
+
+func TestMain(m *testing.M) {
+	// start from fresh preferences and usage, removed once the tests finish
+	dir, err := os.MkdirTemp("", "tidal-eval-test-*")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := config.UseAppConfigDir(dir); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestRun(t *testing.T) {
+	llm.ResetMockProviders()
+	scriptPath := filepath.Join(t.TempDir(), "mock.json")
+	script := `{
+		"mode": "regex",
+		"rules": [{"pattern": "about (\\w+)", "response": "$1 is great"}],
+		"responses": ["unused"],
+		"errors": {"every_nth_call": 4}
+	}`
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := RequestT{
+		Variable: config.LlmVariableT{
+			Name:       "Praise",
+			PromptMain: "Say something nice about $$StreamCategory for $$Viewer",
+			Output:     config.LlmOutputConfigT{MaxCharacters: 14},
+		},
+		Profiles: []config.LlmProviderProfileT{{Name: "mock", Provider: llm.ProviderMock, ScriptPath: scriptPath}},
+		Snapshots: []SnapshotT{
+			{Name: "chess", Values: map[string]string{"StreamCategory": "Chess", "Viewer": "everyone"}},
+			{Name: "poker", Values: map[string]string{"StreamCategory": "Poker"}},
+		},
+		Runs:    5,
+		Timeout: time.Second,
+		Moderation: config.ModerationConfigT{
+			Enabled:       true,
+			MildBlocklist: []string{"poker"},
+			MildAction:    config.ModerationActionMask,
+		},
+	}
+
+	progress := []int{}
+	report, err := Run(context.Background(), req, func(done int, total int) {
+		progress = append(progress, done)
+	})
+	if err != nil {
+		t.Fatalf("unable to run eval - err: %v", err)
+	}
+
+	values := []string{}
+	for _, result := range report.Results {
+		values = append(values, result.Value)
+	}
+	// the 4th call fails, and poker is masked
+	if !slices.Equal(values, []string{"Chess is great", "***** is great", "Chess is great", "", "Chess is great"}) {
+		t.Errorf("unexpected values %q", values)
+	}
+	if report.NumFailedRequests() != 1 || report.NumModerationHits() != 1 || report.NumDuplicates() != 2 {
+		t.Errorf(
+			"unexpected counts - %v failed, %v moderation hits, %v duplicates",
+			report.NumFailedRequests(), report.NumModerationHits(), report.NumDuplicates(),
+		)
+	}
+	if !slices.Equal(report.MissingVariables, []string{"Viewer"}) {
+		t.Errorf("expected Viewer to be missing from a snapshot, got %q", report.MissingVariables)
+	}
+	if !slices.Equal(progress, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("unexpected progress %v", progress)
+	}
+}
+
+func TestRunValidatesResponses(t *testing.T) {
+	llm.ResetMockProviders()
+	scriptPath := filepath.Join(t.TempDir(), "mock.json")
+	if err := os.WriteFile(scriptPath, []byte(`{"mode": "round_robin", "responses": ["short", "much too long"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Run(context.Background(), RequestT{
+		Variable:  config.LlmVariableT{Name: "Word", PromptMain: "Say a word", Output: config.LlmOutputConfigT{MaxCharacters: 5}},
+		Profiles:  []config.LlmProviderProfileT{{Name: "mock", Provider: llm.ProviderMock, ScriptPath: scriptPath}},
+		Snapshots: []SnapshotT{{Name: "empty"}},
+		Runs:      2,
+		Timeout:   time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to run eval - err: %v", err)
+	}
+	if report.NumValidationFailures() != 1 || report.Results[1].Violation == nil {
+		t.Errorf("expected the long response to fail validation, got %+v", report.Results)
+	}
+}
+
+// Responses are checked as they would be on stream, including tags mode and repetition checks
+func TestRunChecksResponsesAsOnStream(t *testing.T) {
+	llm.ResetMockProviders()
+	scriptPath := filepath.Join(t.TempDir(), "mock.json")
+	if err := os.WriteFile(scriptPath, []byte(`{"mode": "round_robin", "responses": ["Chess Night", "#Chess #Speed Runs"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profiles := []config.LlmProviderProfileT{{Name: "mock", Provider: llm.ProviderMock, ScriptPath: scriptPath}}
+
+	report, err := Run(context.Background(), RequestT{
+		Variable: config.LlmVariableT{
+			Name:         "Topic",
+			PromptMain:   "Suggest a topic",
+			History:      config.LlmHistoryConfigT{Size: 5, SimilarityThreshold: 0.8},
+			RecentValues: []string{"chess night"},
+		},
+		Profiles:  profiles,
+		Snapshots: []SnapshotT{{Name: "empty"}},
+		Runs:      1,
+		Timeout:   time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to run eval - err: %v", err)
+	}
+	if report.NumValidationFailures() != 1 {
+		t.Errorf("expected a value repeating the history to be rejected, got %+v", report.Results)
+	}
+
+	report, err = Run(context.Background(), RequestT{
+		Variable:  config.LlmVariableT{Name: "Tags", PromptMain: "Suggest tags", Mode: config.VariableModeTags},
+		Profiles:  profiles,
+		Snapshots: []SnapshotT{{Name: "empty"}},
+		Runs:      1,
+		Timeout:   time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to run eval - err: %v", err)
+	}
+	if len(report.Results) != 1 || report.Results[0].Value != "Chess, SpeedRuns" {
+		t.Errorf("expected the response to be parsed as tags, got %+v", report.Results)
+	}
+}
+
+func TestRenderPrompt(t *testing.T) {
+	variable := config.LlmVariableT{PromptMain: "Joke about $$StreamCategory.Live", PromptSuffix: "Set up: $$Joke.setup, $$Unknown"}
+	snapshot := SnapshotT{Values: map[string]string{"StreamCategory": "Chess", "Joke.setup": "Why?"}}
+	if prompt := RenderPrompt(variable, snapshot); prompt != "Joke about Chess.Live\nSet up: Why?, $$Unknown" {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+}
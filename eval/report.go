@@ -0,0 +1,148 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The results of every run of an evaluation, with summary statistics
+type ReportT struct {
+	Variable         string
+	Results          []ResultT
+	MissingVariables []string // referenced by the prompt, but absent from a snapshot
+	ImageErr         error    // why the variable's image could not be attached to the prompts
+}
+
+func (r ReportT) successfulResults() []ResultT {
+	successful := []ResultT{}
+	for _, result := range r.Results {
+		if result.Err == nil {
+			successful = append(successful, result)
+		}
+	}
+	return successful
+}
+
+func (r ReportT) NumFailedRequests() int {
+	return len(r.Results) - len(r.successfulResults())
+}
+
+func (r ReportT) NumModerationHits() int {
+	count := 0
+	for _, result := range r.successfulResults() {
+		if result.ModerationHit {
+			count++
+		}
+	}
+	return count
+}
+
+func (r ReportT) NumValidationFailures() int {
+	count := 0
+	for _, result := range r.successfulResults() {
+		if result.Violation != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Number of values identical (ignoring case and surrounding whitespace) to an earlier run's value
+func (r ReportT) NumDuplicates() int {
+	seen := map[string]struct{}{}
+	count := 0
+	for _, result := range r.successfulResults() {
+		normalised := strings.ToLower(strings.TrimSpace(result.Value))
+		if _, exists := seen[normalised]; exists {
+			count++
+			continue
+		}
+		seen[normalised] = struct{}{}
+	}
+	return count
+}
+
+// Character lengths of the successful values, shortest first
+func (r ReportT) Lengths() []int {
+	lengths := []int{}
+	for _, result := range r.successfulResults() {
+		lengths = append(lengths, valueLength(result.Value))
+	}
+	slices.Sort(lengths)
+	return lengths
+}
+
+// Latencies of the successful requests, quickest first
+func (r ReportT) Latencies() []time.Duration {
+	latencies := []time.Duration{}
+	for _, result := range r.successfulResults() {
+		latencies = append(latencies, result.Latency)
+	}
+	slices.Sort(latencies)
+	return latencies
+}
+
+// Human-readable summary, followed by every value generated
+func (r ReportT) String() string {
+	var sb strings.Builder
+	numRuns := len(r.Results)
+	numSuccessful := len(r.successfulResults())
+
+	fmt.Fprintf(&sb, "Evaluation of %s - %v runs\n", r.Variable, numRuns)
+	if len(r.MissingVariables) > 0 {
+		fmt.Fprintf(&sb, "Warning: snapshots are missing values for %s\n", strings.Join(r.MissingVariables, ", "))
+	}
+	if r.ImageErr != nil {
+		fmt.Fprintf(&sb, "Warning: prompts were sent without their image - %v\n", r.ImageErr)
+	}
+	fmt.Fprintf(&sb, "Failed requests:     %v (%s)\n", r.NumFailedRequests(), formatRate(r.NumFailedRequests(), numRuns))
+	if numSuccessful > 0 {
+		lengths := r.Lengths()
+		fmt.Fprintf(&sb, "Moderation hits:     %v (%s)\n", r.NumModerationHits(), formatRate(r.NumModerationHits(), numSuccessful))
+		fmt.Fprintf(&sb, "Validation failures: %v (%s)\n", r.NumValidationFailures(), formatRate(r.NumValidationFailures(), numSuccessful))
+		fmt.Fprintf(&sb, "Duplicates:          %v (%s)\n", r.NumDuplicates(), formatRate(r.NumDuplicates(), numSuccessful))
+		fmt.Fprintf(
+			&sb, "Length (chars):      min %v, p50 %v, p90 %v, max %v\n",
+			lengths[0], percentile(lengths, 50), percentile(lengths, 90), lengths[len(lengths)-1],
+		)
+		latencies := r.Latencies()
+		fmt.Fprintf(
+			&sb, "Latency:             p50 %v, p90 %v, p99 %v\n",
+			percentile(latencies, 50).Round(time.Millisecond),
+			percentile(latencies, 90).Round(time.Millisecond),
+			percentile(latencies, 99).Round(time.Millisecond),
+		)
+	}
+
+	sb.WriteString("\nResults:\n")
+	for idx, result := range r.Results {
+		switch {
+		case result.Err != nil:
+			fmt.Fprintf(&sb, "%v. [%s] request failed - %v\n", idx+1, result.Snapshot, result.Err)
+		case result.Violation != nil:
+			fmt.Fprintf(&sb, "%v. [%s] %q - invalid: %v\n", idx+1, result.Snapshot, result.Value, result.Violation)
+		default:
+			fmt.Fprintf(&sb, "%v. [%s] %q\n", idx+1, result.Snapshot, result.Value)
+		}
+	}
+	return sb.String()
+}
+
+func formatRate(count int, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(count)/float64(total)*100)
+}
+
+// Nearest-rank percentile of sorted values
+func percentile[T int | time.Duration](sorted []T, p float64) T {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
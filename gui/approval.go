@@ -0,0 +1,187 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/approval"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/control"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+const (
+	maxApprovalRegenerations = 3
+
+	minApprovalTimeoutSeconds = 10
+	maxApprovalTimeoutSeconds = 3600
+)
+
+var (
+	approvalWindow     fyne.Window
+	approvalWindowSize = fyne.NewSize(600, 1)
+
+	controlServer *control.ServerT
+)
+
+// Puts the candidates in the approval queue, and waits for a decision from the GUI or control server
+func requestTitleApproval(ctx context.Context, candidates []titleCandidateT, suggestedIdx int) (approval.DecisionT, error) {
+	titles := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		titles = append(titles, c.title)
+	}
+	approvalConfig := config.Preferences.Title.Approval
+
+	if err := ActivityConsole.pushToConsole(
+		config.Logger.LogToBufferf("Waiting up to %v seconds for a title to be approved", approvalConfig.TimeoutSeconds),
+	); err != nil {
+		config.Logger.LogErrorf("unable to push approval info to console - err: %v", err)
+	}
+	Gui.App.SendNotification(fyne.NewNotification("Title awaiting approval", titles[suggestedIdx]))
+
+	decision, err := approval.Queue.Submit(
+		ctx, titles, suggestedIdx, time.Duration(approvalConfig.TimeoutSeconds)*time.Second, approvalConfig.OnTimeoutAction,
+	)
+	if err != nil {
+		return approval.DecisionT{}, err
+	}
+
+	decisionDescription := decision.Action
+	if decision.TimedOut {
+		decisionDescription += " (timed out)"
+	}
+	if err := ActivityConsole.pushToConsole(
+		config.Logger.LogToBufferf("Approval decision: %s", decisionDescription),
+	); err != nil {
+		config.Logger.LogErrorf("unable to push approval decision to console - err: %v", err)
+	}
+	return decision, nil
+}
+
+// Opens the approval window when a title is submitted, and closes it once it has been decided
+func onApprovalRequestChanged(request *approval.RequestT) {
+	fyne.Do(func() {
+		if approvalWindow != nil {
+			approvalWindow.Close()
+			approvalWindow = nil
+		}
+		if request == nil {
+			return
+		}
+		approvalWindow = Gui.App.NewWindow("Title Approval")
+		approvalWindow.Resize(approvalWindowSize)
+		approvalWindow.SetContent(getApprovalContent(*request))
+		approvalWindow.SetOnClosed(func() {
+			approvalWindow = nil
+		})
+		approvalWindow.Show()
+		approvalWindow.RequestFocus()
+	})
+}
+
+func getApprovalContent(request approval.RequestT) fyne.CanvasObject {
+	titleEntry := widget.NewEntry()
+	selectedIdx := request.SuggestedCandidate
+
+	candidatesRadio := widget.NewRadioGroup(request.Candidates, func(selected string) {
+		for idx, candidate := range request.Candidates {
+			if candidate == selected {
+				selectedIdx = idx
+				titleEntry.SetText(candidate)
+				return
+			}
+		}
+	})
+	candidatesRadio.Required = true
+	candidatesRadio.SetSelected(request.Candidates[request.SuggestedCandidate])
+	titleEntry.SetText(request.Candidates[request.SuggestedCandidate])
+
+	expiresAt := time.Unix(request.ExpiresUnixTimestamp, 0)
+	onTimeoutAction := config.Preferences.Title.Approval.OnTimeoutAction
+	countdownLabel := widget.NewLabel("")
+	updateCountdown := func() {
+		countdownLabel.SetText(fmt.Sprintf(
+			"Automatically %s in %v seconds", onTimeoutAction, max(int(time.Until(expiresAt).Seconds()), 0),
+		))
+	}
+	updateCountdown()
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if time.Now().After(expiresAt) {
+				return
+			}
+			fyne.Do(updateCountdown)
+		}
+	}()
+
+	decide := func(decision approval.DecisionT) {
+		if err := approval.Queue.Decide(request.Id, decision); err != nil {
+			showErrorDialog(err, fmt.Sprintf("Unable to %s title - %v", decision.Action, err), approvalWindow)
+		}
+	}
+
+	approveBtn := widget.NewButton("Approve", func() {
+		decide(approval.DecisionT{Action: config.ApprovalActionApprove, Candidate: selectedIdx})
+	})
+	approveBtn.Importance = widget.HighImportance
+	editBtn := widget.NewButton("Publish Edit", func() {
+		editedTitle := strings.TrimSpace(titleEntry.Text)
+		if len(editedTitle) > twitch.MaxTitleLength {
+			showInfoDialog(
+				"Title Too Long",
+				fmt.Sprintf("The edited title is %v characters long - titles cannot be longer than %v.", len(editedTitle), twitch.MaxTitleLength),
+				approvalWindow,
+			)
+			return
+		}
+		decide(approval.DecisionT{Action: config.ApprovalActionEdit, Candidate: selectedIdx, Title: editedTitle})
+	})
+	regenerateBtn := widget.NewButton("Regenerate", func() {
+		decide(approval.DecisionT{Action: config.ApprovalActionRegenerate})
+	})
+	skipBtn := widget.NewButton("Skip", func() {
+		decide(approval.DecisionT{Action: config.ApprovalActionSkip})
+	})
+
+	return container.NewPadded(
+		container.New(
+			layout.NewFormLayout(),
+			widget.NewLabel("Candidates"), candidatesRadio,
+			widget.NewLabel("Title"), titleEntry,
+			layout.NewSpacer(), countdownLabel,
+			layout.NewSpacer(), container.New(layout.NewGridLayoutWithColumns(4), approveBtn, editBtn, regenerateBtn, skipBtn),
+		),
+	)
+}
+
+// Starts the control server if it is enabled, so titles can be approved from other tools
+func startControlServer() error {
+	serverConfig := config.Preferences.ControlServer
+	if !serverConfig.Enabled || controlServer != nil {
+		return nil
+	}
+	server, err := control.Start(serverConfig, approval.Queue)
+	if err != nil {
+		return fmt.Errorf("unable to start control server - err: %w", err)
+	}
+	controlServer = server
+	return nil
+}
+
+func stopControlServer() {
+	if controlServer == nil {
+		return
+	}
+	if err := controlServer.Stop(); err != nil {
+		config.Logger.LogErrorf("unable to stop control server - err: %v", err)
+	}
+	controlServer = nil
+}
@@ -0,0 +1,99 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+// Renders the channel profile's templates and resolves its game, adding them to the update.
+// Settings which cannot be rendered or resolved are left unchanged, so the title is still published.
+func addChannelProfileToUpdate(
+	ctx context.Context,
+	profile config.ChannelProfileT,
+	prefs config.PreferencesFormat,
+	variableValues map[string]string,
+	update *twitch.ChannelUpdateT,
+) {
+	replacer, err := getChannelProfileReplacer(prefs.TwitchVariables, variableValues)
+	if err != nil {
+		config.Logger.LogErrorf("unable to get channel profile replacer - err: %v", err)
+		pushChannelProfileNote(profile, "could not be rendered, so only the title was updated - %v", err)
+		return
+	}
+
+	if profile.GameName != "" {
+		gameName, rendered := renderChannelProfileTemplate(replacer, profile.GameName)
+		if !rendered {
+			pushChannelProfileNote(profile, "category %q has a variable with no value, so the category was not changed", gameName)
+		} else if game, err := twitch.ResolveGame(ctx, prefs, gameName); err != nil {
+			config.Logger.LogErrorf("unable to resolve game %q - err: %v", gameName, err)
+			pushChannelProfileNote(profile, "category %q could not be found, so the category was not changed", gameName)
+		} else {
+			update.GameId = game.Id
+		}
+	}
+
+	// ai-generated tags replace the profile's tags, and are applied on their own schedule
+	if len(profile.Tags) > 0 && !prefs.Tags.Enabled {
+		tags := []string{}
+		for _, tag := range profile.Tags {
+			renderedTag, rendered := renderChannelProfileTemplate(replacer, tag)
+			if !rendered {
+				pushChannelProfileNote(profile, "tag %q has a variable with no value, so it was left out", renderedTag)
+				continue
+			}
+			tags = append(tags, renderedTag)
+		}
+		if tags = helpers.NormaliseTags(tags); len(tags) > 0 {
+			update.Tags = tags
+		}
+	}
+
+	update.Language = strings.ToLower(strings.TrimSpace(profile.Language))
+	if profile.SetContentLabels {
+		update.ContentLabels = append([]string{}, profile.ContentLabels...)
+	}
+}
+
+// Returns the rendered template, and false if any of its variables had no value
+func renderChannelProfileTemplate(replacer *strings.Replacer, template string) (string, bool) {
+	rendered := strings.TrimSpace(replacer.Replace(template))
+	if strings.Contains(rendered, emptyVariablePlaceholder) || len(helpers.ExtractVariableNamesFromText(rendered, nil)) > 0 {
+		return rendered, false
+	}
+	return rendered, true
+}
+
+func getChannelProfileReplacer(twitchVariables config.TwitchVariablesT, variableValues map[string]string) (*strings.Replacer, error) {
+	replacementMap := maps.Clone(variableValues)
+	if replacementMap == nil {
+		replacementMap = map[string]string{}
+	}
+	twitchVariablesMap := helpers.GenerateMapFromHomogenousStruct[config.TwitchVariablesT, config.TwitchVariableT](twitchVariables)
+	for varName, v := range twitchVariablesMap {
+		placeholderStr := helpers.GenerateVarPlaceholderString(varName)
+		if _, exists := replacementMap[placeholderStr]; exists {
+			return nil, fmt.Errorf("conflicting variable name: %q", placeholderStr)
+		}
+		val := v.Value
+		if val == "" {
+			val = emptyVariablePlaceholder
+		}
+		replacementMap[placeholderStr] = val
+	}
+	return helpers.GetStringReplacerFromMap(replacementMap, true, false)
+}
+
+func pushChannelProfileNote(profile config.ChannelProfileT, format string, args ...any) {
+	if err := ActivityConsole.pushToConsole(
+		config.Logger.LogToBufferf("Channel profile %q %s", profile.Name, fmt.Sprintf(format, args...)),
+	); err != nil {
+		config.Logger.LogErrorf("unable to push channel profile note to console - err: %v", err)
+	}
+}
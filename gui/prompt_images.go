@@ -0,0 +1,55 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/llm"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+// Loads the images attached to prompts, fetching the stream thumbnail at most once per title
+type promptImagesT struct {
+	thumbnailOnce sync.Once
+	thumbnail     llm.ImageT
+	thumbnailErr  error
+}
+
+// Returns the images to send with a variable's prompt.
+// If an image cannot be loaded, the prompt is sent without it.
+func (p *promptImagesT) getImages(ctx context.Context, variable config.LlmVariableT) []llm.ImageT {
+	if !variable.Image.Enabled() {
+		return nil
+	}
+	var image llm.ImageT
+	var err error
+	switch variable.Image.Source {
+	case config.ImageSourceStreamThumbnail:
+		p.thumbnailOnce.Do(func() {
+			var data []byte
+			data, p.thumbnailErr = twitch.GetStreamThumbnail(ctx)
+			if p.thumbnailErr == nil {
+				p.thumbnail, p.thumbnailErr = llm.NewImage(data)
+			}
+		})
+		image, err = p.thumbnail, p.thumbnailErr
+	case config.ImageSourceScreenshotFile:
+		image, err = llm.LoadImageFile(variable.Image.FilePath)
+	default:
+		err = fmt.Errorf("%q is not a valid image source", variable.Image.Source)
+	}
+	if err != nil {
+		placeholderStr := helpers.GenerateVarPlaceholderString(variable.Name)
+		config.Logger.LogErrorf("unable to load image for %v - err: %v", placeholderStr, err)
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf("%s is being generated without its image - %v", placeholderStr, err),
+		); err != nil {
+			config.Logger.LogErrorf("unable to push image failure to console - err: %v", err)
+		}
+		return nil
+	}
+	return []llm.ImageT{image}
+}
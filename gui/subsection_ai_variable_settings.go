@@ -0,0 +1,489 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/llm"
+	"github.com/skratchdot/open-golang/open"
+)
+
+// A group of advanced settings shown when adding or editing an AI-generated variable
+type aiVariableSettingsT struct {
+	item  *widget.AccordionItem
+	apply func(*config.LlmVariableT) error // validates the widgets and writes their values to the variable
+}
+
+func getProviderChainSettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	providerChainEntry := widget.NewEntry()
+	providerChainEntry.SetText(strings.Join(variable.ProviderChain, ", "))
+	providerChainEntry.SetPlaceHolder("e.g. Local Ollama, Gemini")
+	providerChainEntry.OnChanged = func(_ string) { onChanged() }
+
+	availableProfileNames := []string{config.DefaultProviderProfileName}
+	for _, profile := range config.Preferences.LlmConfig.ProviderProfiles {
+		availableProfileNames = append(availableProfileNames, profile.Name)
+	}
+	providerChainTipLabel := widget.NewLabel(
+		fmt.Sprintf("Comma-separated profiles, tried in order. Leave empty to use the default provider.\nAvailable: %s", strings.Join(availableProfileNames, ", ")),
+	)
+	providerChainTipLabel.Wrapping = fyne.TextWrapWord
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"Provider Chain",
+			container.New(
+				layout.NewFormLayout(),
+				widget.NewLabel("Profiles"), providerChainEntry,
+				layout.NewSpacer(), providerChainTipLabel,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			v.ProviderChain = helpers.SplitCommaSeparated(providerChainEntry.Text)
+			_, err := llm.GetProviderChain(config.Preferences.LlmConfig, *v)
+			return err
+		},
+	}
+}
+
+func getFailurePolicySettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	policy := variable.FailurePolicy
+
+	maxRetriesEntry := widget.NewEntry()
+	maxRetriesEntry.SetText(strconv.Itoa(policy.MaxRetries))
+	retryBackoffEntry := widget.NewEntry()
+	retryBackoffEntry.SetText(strconv.Itoa(policy.RetryBackoffSeconds))
+
+	onFailureSelect := widget.NewSelect(config.FailureActions, nil)
+	if policy.OnFailure == "" {
+		onFailureSelect.SetSelected(config.FailureActionStopTidal)
+	} else {
+		onFailureSelect.SetSelected(policy.OnFailure)
+	}
+	onFailureSelect.OnChanged = func(_ string) { onChanged() }
+
+	fallbackValueEntry := widget.NewEntry()
+	fallbackValueEntry.SetText(policy.FallbackValue)
+	backupPoolEntry := getMultilineEntry(strings.Join(policy.BackupPool, "\n"), nil, 3, fyne.ScrollVerticalOnly, fyne.TextWrapOff)
+	backupPoolEntry.SetPlaceHolder("One value per line")
+
+	for _, entry := range []*widget.Entry{maxRetriesEntry, retryBackoffEntry, fallbackValueEntry, backupPoolEntry} {
+		entry.OnChanged = func(_ string) { onChanged() }
+	}
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"On Failure",
+			container.New(
+				layout.NewFormLayout(),
+				widget.NewLabel("Retries"), maxRetriesEntry,
+				widget.NewLabel("Retry Backoff (seconds)"), retryBackoffEntry,
+				widget.NewLabel("Then"), onFailureSelect,
+				widget.NewLabel("Fallback Value"), fallbackValueEntry,
+				widget.NewLabel("Backup Pool"), backupPoolEntry,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			maxRetries, err := strconv.Atoi(strings.TrimSpace(maxRetriesEntry.Text))
+			if err != nil || maxRetries < 0 || maxRetries > llm.MaxRetries {
+				return fmt.Errorf("retries must be a number between 0 and %v", llm.MaxRetries)
+			}
+			retryBackoffSeconds, err := strconv.Atoi(strings.TrimSpace(retryBackoffEntry.Text))
+			if err != nil || retryBackoffSeconds < 0 {
+				return errors.New("retry backoff must be a positive number of seconds")
+			}
+			newPolicy := config.LlmFailurePolicyT{
+				MaxRetries:          maxRetries,
+				RetryBackoffSeconds: retryBackoffSeconds,
+				OnFailure:           onFailureSelect.Selected,
+				FallbackValue:       strings.TrimSpace(fallbackValueEntry.Text),
+				BackupPool:          splitLines(backupPoolEntry.Text),
+			}
+			if newPolicy.OnFailure == config.FailureActionFallbackValue && newPolicy.FallbackValue == "" {
+				return errors.New("a fallback value must be provided")
+			}
+			if newPolicy.OnFailure == config.FailureActionBackupPool && len(newPolicy.BackupPool) == 0 {
+				return errors.New("the backup pool must contain at least one value")
+			}
+			v.FailurePolicy = newPolicy
+			return nil
+		},
+	}
+}
+
+// Splits multiline entry text into its trimmed, non-empty lines
+func splitLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func getOutputSettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	outputConfig := variable.Output
+
+	stripWrappingQuotesCheck := widget.NewCheck("Strip wrapping quotes", nil)
+	stripWrappingQuotesCheck.SetChecked(outputConfig.StripWrappingQuotes)
+	stripMarkdownCheck := widget.NewCheck("Strip markdown", nil)
+	stripMarkdownCheck.SetChecked(outputConfig.StripMarkdown)
+	stripPreambleCheck := widget.NewCheck("Strip preambles (e.g. \"Here's a joke:\")", nil)
+	stripPreambleCheck.SetChecked(outputConfig.StripPreamble)
+	collapseNewlinesCheck := widget.NewCheck("Collapse newlines", nil)
+	collapseNewlinesCheck.SetChecked(outputConfig.CollapseNewlines)
+	for _, check := range []*widget.Check{stripWrappingQuotesCheck, stripMarkdownCheck, stripPreambleCheck, collapseNewlinesCheck} {
+		check.OnChanged = func(_ bool) { onChanged() }
+	}
+
+	maxCharactersEntry := widget.NewEntry()
+	maxCharactersEntry.SetText(strconv.Itoa(outputConfig.MaxCharacters))
+	validationRegexEntry := widget.NewEntry()
+	validationRegexEntry.SetText(outputConfig.ValidationRegex)
+	validationRegexEntry.SetPlaceHolder("Optional regular expression")
+	maxAttemptsEntry := widget.NewEntry()
+	maxAttemptsEntry.SetText(strconv.Itoa(max(outputConfig.MaxAttempts, 1)))
+	for _, entry := range []*widget.Entry{maxCharactersEntry, validationRegexEntry, maxAttemptsEntry} {
+		entry.OnChanged = func(_ string) { onChanged() }
+	}
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"Output Processing",
+			container.New(
+				layout.NewFormLayout(),
+				layout.NewSpacer(), stripWrappingQuotesCheck,
+				layout.NewSpacer(), stripMarkdownCheck,
+				layout.NewSpacer(), stripPreambleCheck,
+				layout.NewSpacer(), collapseNewlinesCheck,
+				widget.NewLabel("Max Characters (0 = no limit)"), maxCharactersEntry,
+				widget.NewLabel("Must Match"), validationRegexEntry,
+				widget.NewLabel("Max Attempts"), maxAttemptsEntry,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			maxCharacters, err := strconv.Atoi(strings.TrimSpace(maxCharactersEntry.Text))
+			if err != nil || maxCharacters < 0 {
+				return errors.New("max characters must be a positive number, or 0")
+			}
+			maxAttempts, err := strconv.Atoi(strings.TrimSpace(maxAttemptsEntry.Text))
+			if err != nil || maxAttempts < 1 || maxAttempts > llm.MaxOutputAttempts {
+				return fmt.Errorf("max attempts must be a number between 1 and %v", llm.MaxOutputAttempts)
+			}
+			validationRegex := strings.TrimSpace(validationRegexEntry.Text)
+			if _, err := regexp.Compile(validationRegex); err != nil {
+				return fmt.Errorf("validation pattern is not a valid regular expression - err: %w", err)
+			}
+			v.Output = config.LlmOutputConfigT{
+				StripWrappingQuotes: stripWrappingQuotesCheck.Checked,
+				StripMarkdown:       stripMarkdownCheck.Checked,
+				StripPreamble:       stripPreambleCheck.Checked,
+				CollapseNewlines:    collapseNewlinesCheck.Checked,
+				MaxCharacters:       maxCharacters,
+				ValidationRegex:     validationRegex,
+				MaxAttempts:         maxAttempts,
+			}
+			return nil
+		},
+	}
+}
+
+func getHistorySettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	historyConfig := variable.History
+
+	sizeEntry := widget.NewEntry()
+	sizeEntry.SetText(strconv.Itoa(historyConfig.Size))
+	includeInPromptCheck := widget.NewCheck("Ask the LLM not to repeat recent values", nil)
+	includeInPromptCheck.SetChecked(historyConfig.IncludeInPrompt)
+	includeInPromptCheck.OnChanged = func(_ bool) { onChanged() }
+	similarityThresholdEntry := widget.NewEntry()
+	similarityThresholdEntry.SetText(strconv.FormatFloat(historyConfig.SimilarityThreshold, 'f', -1, 64))
+	similarityMethodSelect := widget.NewSelect(config.SimilarityMethods, nil)
+	if historyConfig.SimilarityMethod == "" {
+		similarityMethodSelect.SetSelected(config.SimilarityMethodNgramOverlap)
+	} else {
+		similarityMethodSelect.SetSelected(historyConfig.SimilarityMethod)
+	}
+	similarityMethodSelect.OnChanged = func(_ string) { onChanged() }
+	for _, entry := range []*widget.Entry{sizeEntry, similarityThresholdEntry} {
+		entry.OnChanged = func(_ string) { onChanged() }
+	}
+
+	recentValuesLabel := widget.NewLabel(fmt.Sprintf("%v recent values remembered", len(variable.RecentValues)))
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"Repetition Avoidance",
+			container.New(
+				layout.NewFormLayout(),
+				widget.NewLabel("Remember Last"), sizeEntry,
+				layout.NewSpacer(), includeInPromptCheck,
+				widget.NewLabel("Max Similarity (0-1, 0 = off)"), similarityThresholdEntry,
+				widget.NewLabel("Similarity Method"), similarityMethodSelect,
+				layout.NewSpacer(), recentValuesLabel,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			size, err := strconv.Atoi(strings.TrimSpace(sizeEntry.Text))
+			if err != nil || size < 0 || size > llm.MaxHistorySize {
+				return fmt.Errorf("remember last must be a number between 0 and %v", llm.MaxHistorySize)
+			}
+			similarityThreshold, err := strconv.ParseFloat(strings.TrimSpace(similarityThresholdEntry.Text), 64)
+			if err != nil || similarityThreshold < 0 || similarityThreshold > 1 {
+				return errors.New("max similarity must be a number between 0 and 1")
+			}
+			v.History = config.LlmHistoryConfigT{
+				Size:                size,
+				IncludeInPrompt:     includeInPromptCheck.Checked,
+				SimilarityThreshold: similarityThreshold,
+				SimilarityMethod:    similarityMethodSelect.Selected,
+			}
+			if len(v.RecentValues) > size {
+				v.RecentValues = v.RecentValues[len(v.RecentValues)-size:]
+			}
+			return nil
+		},
+	}
+}
+
+func getRefreshPolicySettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	refreshPolicy := variable.Refresh
+
+	intervalEntry := widget.NewEntry()
+	intervalEntry.SetText(strconv.Itoa(max(refreshPolicy.IntervalMinutes, helpers.MinTitleUpdateIntervalMinutes)))
+	intervalEntry.OnChanged = func(_ string) { onChanged() }
+
+	modeSelect := widget.NewSelect(config.RefreshModes, nil)
+	modeSelect.OnChanged = func(mode string) {
+		if mode == config.RefreshModeInterval {
+			intervalEntry.Enable()
+		} else {
+			intervalEntry.Disable()
+		}
+		onChanged()
+	}
+	if refreshPolicy.Mode == "" {
+		modeSelect.SetSelected(config.RefreshModeEveryCycle)
+	} else {
+		modeSelect.SetSelected(refreshPolicy.Mode)
+	}
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"Refresh",
+			container.New(
+				layout.NewFormLayout(),
+				widget.NewLabel("Regenerate"), modeSelect,
+				widget.NewLabel("Interval (minutes)"), intervalEntry,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			intervalMinutes, err := strconv.Atoi(strings.TrimSpace(intervalEntry.Text))
+			if err != nil || intervalMinutes < helpers.MinTitleUpdateIntervalMinutes || intervalMinutes > helpers.MaxTitleUpdateIntervalMinutes {
+				return fmt.Errorf(
+					"interval must be a number between %v and %v",
+					helpers.MinTitleUpdateIntervalMinutes, helpers.MaxTitleUpdateIntervalMinutes,
+				)
+			}
+			v.Refresh = config.LlmRefreshPolicyT{
+				Mode:            modeSelect.Selected,
+				IntervalMinutes: intervalMinutes,
+			}
+			return nil
+		},
+	}
+}
+
+func getStructuredOutputSettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	fieldsEntry := widget.NewEntry()
+	fieldsEntry.SetText(strings.Join(variable.Fields, ", "))
+	fieldsEntry.SetPlaceHolder("e.g. emoji, pun, hashtag")
+	fieldsEntry.OnChanged = func(_ string) { onChanged() }
+
+	fieldsTipLabel := widget.NewLabel(
+		fmt.Sprintf(
+			"Comma-separated fields, all generated by a single request. Leave empty for a regular variable.\nEach field is used as %sName%sfield.",
+			helpers.VarNamePlaceholderPrefix, config.GroupFieldSeparator,
+		),
+	)
+	fieldsTipLabel.Wrapping = fyne.TextWrapWord
+
+	modeSelect := widget.NewSelect(config.VariableModes, nil)
+	if variable.Mode == "" {
+		modeSelect.SetSelected(config.VariableModeText)
+	} else {
+		modeSelect.SetSelected(variable.Mode)
+	}
+	modeSelect.OnChanged = func(_ string) { onChanged() }
+	modeTipLabel := widget.NewLabel(
+		fmt.Sprintf(
+			"%s generates up to %v tags from the stream's category, title and knowledge base, for AI-Generated Tags in Channel Profiles.",
+			config.VariableModeTags, helpers.MaxTags,
+		),
+	)
+	modeTipLabel.Wrapping = fyne.TextWrapWord
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"Structured Output",
+			container.New(
+				layout.NewFormLayout(),
+				widget.NewLabel("Mode"), modeSelect,
+				layout.NewSpacer(), modeTipLabel,
+				widget.NewLabel("Fields"), fieldsEntry,
+				layout.NewSpacer(), fieldsTipLabel,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			fields := helpers.SplitCommaSeparated(fieldsEntry.Text)
+			if err := llm.ValidateGroupFields(fields); err != nil {
+				return err
+			}
+			if modeSelect.Selected == config.VariableModeTags && len(fields) > 0 {
+				return fmt.Errorf("%s variables cannot have fields", config.VariableModeTags)
+			}
+			if !slices.Equal(fields, v.Fields) || (modeSelect.Selected == config.VariableModeTags) != v.IsTags() {
+				// the history no longer matches the fields or mode
+				v.RecentValues = []string{}
+			}
+			v.Fields = fields
+			v.Mode = modeSelect.Selected
+			return nil
+		},
+	}
+}
+
+func getImageSettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	filePathEntry := widget.NewEntry()
+	filePathEntry.SetText(variable.Image.FilePath)
+	filePathEntry.SetPlaceHolder("e.g. C:\\Users\\me\\Pictures\\screenshot.png")
+	filePathEntry.OnChanged = func(_ string) { onChanged() }
+
+	sourceSelect := widget.NewSelect(config.ImageSources, nil)
+	sourceSelect.OnChanged = func(source string) {
+		if source == config.ImageSourceScreenshotFile {
+			filePathEntry.Enable()
+		} else {
+			filePathEntry.Disable()
+		}
+		onChanged()
+	}
+	if variable.Image.Enabled() {
+		sourceSelect.SetSelected(variable.Image.Source)
+	} else {
+		sourceSelect.SetSelected(config.ImageSourceNone)
+	}
+
+	imageTipLabel := widget.NewLabel(
+		"The image is sent along with the prompt, so the LLM can describe what is on screen. The selected model must accept images.",
+	)
+	imageTipLabel.Wrapping = fyne.TextWrapWord
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"Image",
+			container.New(
+				layout.NewFormLayout(),
+				widget.NewLabel("Attach"), sourceSelect,
+				widget.NewLabel("Screenshot File"), filePathEntry,
+				layout.NewSpacer(), imageTipLabel,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			filePath := strings.TrimSpace(filePathEntry.Text)
+			if sourceSelect.Selected == config.ImageSourceScreenshotFile && filePath == "" {
+				return errors.New("a screenshot file must be specified")
+			}
+			v.Image = config.LlmImageConfigT{
+				Source:   sourceSelect.Selected,
+				FilePath: filePath,
+			}
+			return nil
+		},
+	}
+}
+
+func getKnowledgeSettings(variable config.LlmVariableT, onChanged func()) aiVariableSettingsT {
+	documentNames, err := llm.ListKnowledgeDocuments(config.KnowledgeBaseDir)
+	if err != nil {
+		config.Logger.LogErrorf("unable to list knowledge base documents - err: %v", err)
+	}
+	// keep documents which have since been removed, so they are not silently deselected
+	for _, name := range variable.Knowledge.Documents {
+		if !slices.Contains(documentNames, name) {
+			documentNames = append(documentNames, name)
+		}
+	}
+
+	documentsCheckGroup := widget.NewCheckGroup(documentNames, nil)
+	documentsCheckGroup.SetSelected(variable.Knowledge.Documents)
+	documentsCheckGroup.OnChanged = func(_ []string) { onChanged() }
+
+	alwaysIncludeCheckGroup := widget.NewCheckGroup(documentNames, nil)
+	alwaysIncludeCheckGroup.SetSelected(variable.Knowledge.AlwaysInclude)
+	alwaysIncludeCheckGroup.OnChanged = func(_ []string) { onChanged() }
+
+	maxTokens := variable.Knowledge.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = config.DefaultKnowledgeMaxTokens
+	}
+	maxTokensEntry := widget.NewEntry()
+	maxTokensEntry.SetText(strconv.Itoa(maxTokens))
+	maxTokensEntry.OnChanged = func(_ string) { onChanged() }
+
+	openFolderButton := widget.NewButtonWithIcon("Open Knowledge Base Folder", theme.FolderIcon(), func() {
+		open.Run(config.KnowledgeBaseDir)
+	})
+
+	knowledgeTipLabel := widget.NewLabel(
+		"Add .md or .txt files to the knowledge base folder, then reopen this window to select them. " +
+			"The snippets most relevant to the prompt are added to it, up to the token budget. " +
+			"Always included documents, such as general lore or banned topics, are added first whatever the prompt.",
+	)
+	knowledgeTipLabel.Wrapping = fyne.TextWrapWord
+
+	return aiVariableSettingsT{
+		item: widget.NewAccordionItem(
+			"Knowledge Base",
+			container.New(
+				layout.NewFormLayout(),
+				widget.NewLabel("Documents"), documentsCheckGroup,
+				widget.NewLabel("Always Include"), alwaysIncludeCheckGroup,
+				widget.NewLabel("Max Tokens"), maxTokensEntry,
+				layout.NewSpacer(), openFolderButton,
+				layout.NewSpacer(), knowledgeTipLabel,
+			),
+		),
+		apply: func(v *config.LlmVariableT) error {
+			maxTokens, err := strconv.Atoi(strings.TrimSpace(maxTokensEntry.Text))
+			if err != nil || maxTokens < 1 || maxTokens > llm.MaxKnowledgeTokens {
+				return fmt.Errorf("max tokens must be a number between 1 and %v", llm.MaxKnowledgeTokens)
+			}
+			// always included documents are used even if they were not also selected above
+			documents := slices.Clone(documentsCheckGroup.Selected)
+			for _, name := range alwaysIncludeCheckGroup.Selected {
+				if !slices.Contains(documents, name) {
+					documents = append(documents, name)
+				}
+			}
+			v.Knowledge = config.LlmKnowledgeT{
+				Documents:     documents,
+				AlwaysInclude: alwaysIncludeCheckGroup.Selected,
+				MaxTokens:     maxTokens,
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,315 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+)
+
+var channelProfilesWindowSize fyne.Size = fyne.NewSize(700, 1) // height 1 lets the layout determine the height
+
+var languageCodeRegex = regexp.MustCompile(`^([a-z]{2}|other)$`)
+
+func (g *GuiWrapper) getChannelProfilesSubsection() *fyne.Container {
+
+	saveButton := widget.NewButton("Save", nil)
+	saveButton.Disable()
+
+	channelProfiles := append([]config.ChannelProfileT{}, config.Preferences.ChannelProfiles...)
+	tagsConfig := config.Preferences.Tags
+
+	tagsVariableNames := []string{}
+	for _, v := range config.Preferences.AiGeneratedVariables {
+		if v.IsTags() {
+			tagsVariableNames = append(tagsVariableNames, v.Name)
+		}
+	}
+	tagsEnabledCheck := widget.NewCheck("Generate tags with an AI-generated variable", nil)
+	tagsEnabledCheck.SetChecked(tagsConfig.Enabled)
+	tagsVariableSelect := widget.NewSelect(tagsVariableNames, nil)
+	tagsVariableSelect.PlaceHolder = fmt.Sprintf("Select a variable in %q mode", config.VariableModeTags)
+	tagsVariableSelect.SetSelected(tagsConfig.VariableName)
+	alwaysOnTagsEntry := widget.NewEntry()
+	alwaysOnTagsEntry.SetText(strings.Join(tagsConfig.AlwaysOnTags, ", "))
+	alwaysOnTagsEntry.SetPlaceHolder("Comma-separated, e.g. English, Cozy")
+	tagsIntervalEntry := widget.NewEntry()
+	tagsIntervalEntry.SetText(strconv.Itoa(tagsConfig.UpdateIntervalMinutes))
+
+	tagsEnabledCheck.OnChanged = func(_ bool) { saveButton.Enable() }
+	tagsVariableSelect.OnChanged = func(_ string) { saveButton.Enable() }
+	for _, entry := range []*widget.Entry{alwaysOnTagsEntry, tagsIntervalEntry} {
+		entry.OnChanged = func(_ string) { saveButton.Enable() }
+	}
+
+	weekdays := make([]string, 0, 7)
+	for day := time.Monday; day <= time.Saturday; day++ {
+		weekdays = append(weekdays, day.String())
+	}
+	weekdays = append(weekdays, time.Sunday.String())
+
+	profileNameEntry := widget.NewEntry()
+	profileEnabledCheck := widget.NewCheck("Enabled", nil)
+	profileEnabledCheck.SetChecked(true)
+	profileTitleTemplateEntry := getMultilineEntry("", nil, 2, fyne.ScrollVerticalOnly, fyne.TextWrapWord)
+	profileTitleTemplateEntry.SetPlaceHolder("Uses the main title template")
+	profileGameEntry := widget.NewEntry()
+	profileGameEntry.SetPlaceHolder("Unchanged - e.g. Just Chatting")
+	profileTagsEntry := widget.NewEntry()
+	profileTagsEntry.SetPlaceHolder("Unchanged - comma-separated, e.g. English, Cozy")
+	profileLanguageEntry := widget.NewEntry()
+	profileLanguageEntry.SetPlaceHolder("Unchanged - e.g. en")
+	profileContentLabelsGroup := widget.NewCheckGroup(config.ContentLabels, nil)
+	profileSetContentLabelsCheck := widget.NewCheck("Set content classification labels", func(checked bool) {
+		if checked {
+			profileContentLabelsGroup.Enable()
+		} else {
+			profileContentLabelsGroup.Disable()
+		}
+	})
+	profileContentLabelsGroup.Disable()
+	profileDaysGroup := widget.NewCheckGroup(weekdays, nil)
+	profileDaysGroup.Horizontal = true
+	profileStartTimeEntry := widget.NewEntry()
+	profileStartTimeEntry.SetPlaceHolder("HH:MM")
+	profileEndTimeEntry := widget.NewEntry()
+	profileEndTimeEntry.SetPlaceHolder("HH:MM")
+
+	clearProfileEditor := func() {
+		for _, entry := range []*widget.Entry{
+			profileNameEntry, profileTitleTemplateEntry, profileGameEntry, profileTagsEntry,
+			profileLanguageEntry, profileStartTimeEntry, profileEndTimeEntry,
+		} {
+			entry.SetText("")
+		}
+		profileEnabledCheck.SetChecked(true)
+		profileSetContentLabelsCheck.SetChecked(false)
+		profileContentLabelsGroup.SetSelected([]string{})
+		profileDaysGroup.SetSelected([]string{})
+	}
+
+	channelProfilesList := container.New(layout.NewVBoxLayout())
+
+	var refreshChannelProfilesList func()
+	refreshChannelProfilesList = func() {
+		channelProfilesList.Objects = []fyne.CanvasObject{}
+		if len(channelProfiles) == 0 {
+			channelProfilesList.Objects = append(channelProfilesList.Objects, widget.NewLabel("-"))
+		}
+		for idx, profile := range channelProfiles {
+			upBtn := widget.NewButton("Up", func() {
+				channelProfiles[idx-1], channelProfiles[idx] = channelProfiles[idx], channelProfiles[idx-1]
+				refreshChannelProfilesList()
+				saveButton.Enable()
+			})
+			if idx == 0 {
+				upBtn.Disable()
+			}
+			editBtn := widget.NewButton("Edit", func() {
+				profileNameEntry.SetText(profile.Name)
+				profileEnabledCheck.SetChecked(profile.Enabled)
+				profileTitleTemplateEntry.SetText(profile.TitleTemplate)
+				profileGameEntry.SetText(profile.GameName)
+				profileTagsEntry.SetText(strings.Join(profile.Tags, ", "))
+				profileLanguageEntry.SetText(profile.Language)
+				profileSetContentLabelsCheck.SetChecked(profile.SetContentLabels)
+				profileContentLabelsGroup.SetSelected(profile.ContentLabels)
+				profileDaysGroup.SetSelected(profile.Schedule.Days)
+				profileStartTimeEntry.SetText(profile.Schedule.StartTime)
+				profileEndTimeEntry.SetText(profile.Schedule.EndTime)
+			})
+			removeBtn := widget.NewButton("Remove", func() {
+				channelProfiles = append(channelProfiles[:idx], channelProfiles[idx+1:]...)
+				refreshChannelProfilesList()
+				saveButton.Enable()
+			})
+			btns := container.New(layout.NewHBoxLayout(), upBtn, editBtn, removeBtn)
+			label := widget.NewLabel(fmt.Sprintf("%s (%s)", profile.Name, describeChannelProfileSchedule(profile)))
+			channelProfilesList.Objects = append(
+				channelProfilesList.Objects,
+				container.New(layout.NewBorderLayout(nil, nil, nil, btns), btns, label),
+			)
+		}
+		channelProfilesList.Refresh()
+	}
+	refreshChannelProfilesList()
+
+	addProfileButton := widget.NewButton("Add / Update Profile", func() {
+		profile := config.ChannelProfileT{
+			Name:             strings.TrimSpace(profileNameEntry.Text),
+			Enabled:          profileEnabledCheck.Checked,
+			TitleTemplate:    strings.TrimSpace(profileTitleTemplateEntry.Text),
+			GameName:         strings.TrimSpace(profileGameEntry.Text),
+			Tags:             helpers.SplitCommaSeparated(profileTagsEntry.Text),
+			Language:         strings.ToLower(strings.TrimSpace(profileLanguageEntry.Text)),
+			SetContentLabels: profileSetContentLabelsCheck.Checked,
+			ContentLabels:    append([]string{}, profileContentLabelsGroup.Selected...),
+			Schedule: config.ChannelProfileScheduleT{
+				Days:      append([]string{}, profileDaysGroup.Selected...),
+				StartTime: strings.TrimSpace(profileStartTimeEntry.Text),
+				EndTime:   strings.TrimSpace(profileEndTimeEntry.Text),
+			},
+		}
+		if err := validateChannelProfile(profile); err != nil {
+			showErrorDialog(err, fmt.Sprintf("Unable to add profile - %v", err), g.SecondaryWindow)
+			return
+		}
+		replaced := false
+		for idx, existing := range channelProfiles {
+			if existing.Name == profile.Name {
+				channelProfiles[idx] = profile
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			channelProfiles = append(channelProfiles, profile)
+		}
+		clearProfileEditor()
+		refreshChannelProfilesList()
+		saveButton.Enable()
+	})
+
+	scheduleTimes := container.New(
+		layout.NewHBoxLayout(),
+		widget.NewLabel("From"), container.NewGridWrap(fyne.NewSize(80, profileStartTimeEntry.MinSize().Height), profileStartTimeEntry),
+		widget.NewLabel("Until"), container.NewGridWrap(fyne.NewSize(80, profileEndTimeEntry.MinSize().Height), profileEndTimeEntry),
+	)
+
+	profileEditor := container.New(
+		layout.NewFormLayout(),
+		widget.NewLabel("Name"), profileNameEntry,
+		layout.NewSpacer(), profileEnabledCheck,
+		widget.NewLabel("Title Template"), profileTitleTemplateEntry,
+		widget.NewLabel("Category"), profileGameEntry,
+		widget.NewLabel("Tags"), profileTagsEntry,
+		widget.NewLabel("Language"), profileLanguageEntry,
+		layout.NewSpacer(), profileSetContentLabelsCheck,
+		layout.NewSpacer(), profileContentLabelsGroup,
+		widget.NewLabel("Days"), profileDaysGroup,
+		widget.NewLabel("Times"), scheduleTimes,
+		layout.NewSpacer(), container.New(layout.NewBorderLayout(nil, nil, nil, addProfileButton), addProfileButton),
+	)
+
+	saveButton.OnTapped = func() {
+		newTagsConfig, err := getTagsConfig(
+			tagsEnabledCheck.Checked, tagsVariableSelect.Selected, alwaysOnTagsEntry.Text, tagsIntervalEntry.Text,
+		)
+		if err != nil {
+			showErrorDialog(err, fmt.Sprintf("Unable to save - %v", err), g.SecondaryWindow)
+			return
+		}
+		config.Preferences.Tags = newTagsConfig
+		config.Preferences.ChannelProfiles = channelProfiles
+		if err := config.SavePreferences(); err != nil {
+			showErrorDialog(
+				fmt.Errorf("unable to save channel profiles - err: %w", err),
+				"Unable to save channel profiles.",
+				g.SecondaryWindow,
+			)
+			return
+		}
+		saveButton.Disable()
+		g.closeSecondaryWindow()
+	}
+
+	return container.New(
+		layout.NewFormLayout(),
+		widget.NewLabel("AI-Generated Tags"), tagsEnabledCheck,
+		widget.NewLabel("Tags Variable"), tagsVariableSelect,
+		widget.NewLabel("Always-On Tags"), alwaysOnTagsEntry,
+		widget.NewLabel("Update Tags Every (mins)"), tagsIntervalEntry,
+		widget.NewLabel("Profiles"), channelProfilesList,
+		layout.NewSpacer(), profileEditor,
+		layout.NewSpacer(), saveButton,
+	)
+}
+
+func getTagsConfig(enabled bool, variableName string, alwaysOnTagsText string, intervalText string) (config.TagsConfigT, error) {
+	tagsConfig := config.TagsConfigT{
+		Enabled:      enabled,
+		VariableName: variableName,
+		AlwaysOnTags: helpers.SplitCommaSeparated(alwaysOnTagsText),
+	}
+	interval, err := strconv.Atoi(strings.TrimSpace(intervalText))
+	if err != nil || interval < helpers.MinTitleUpdateIntervalMinutes || interval > helpers.MaxTitleUpdateIntervalMinutes {
+		return config.TagsConfigT{}, fmt.Errorf(
+			"the tags update interval must be between %v and %v minutes",
+			helpers.MinTitleUpdateIntervalMinutes, helpers.MaxTitleUpdateIntervalMinutes,
+		)
+	}
+	tagsConfig.UpdateIntervalMinutes = interval
+	if len(tagsConfig.AlwaysOnTags) > helpers.MaxTags {
+		return config.TagsConfigT{}, fmt.Errorf("twitch allows at most %v tags", helpers.MaxTags)
+	}
+	if enabled && variableName == "" {
+		return config.TagsConfigT{}, errors.New("a tags variable must be selected")
+	}
+	return tagsConfig, nil
+}
+
+func validateChannelProfile(profile config.ChannelProfileT) error {
+	if profile.Name == "" {
+		return errors.New("profile name must not be empty")
+	}
+	if len(profile.Tags) > helpers.MaxTags {
+		return fmt.Errorf("twitch allows at most %v tags", helpers.MaxTags)
+	}
+	if profile.Language != "" && !languageCodeRegex.MatchString(profile.Language) {
+		return errors.New("language must be a two-letter ISO 639-1 code, such as en, or other")
+	}
+	schedule := profile.Schedule
+	if (schedule.StartTime == "") != (schedule.EndTime == "") {
+		return errors.New("both a start and end time are needed, or neither for all day")
+	}
+	for _, t := range []string{schedule.StartTime, schedule.EndTime} {
+		if _, err := time.Parse(config.ScheduleTimeLayout, t); t != "" && err != nil {
+			return fmt.Errorf("time %q must be in 24-hour HH:MM format", t)
+		}
+	}
+	if schedule.StartTime != "" && schedule.StartTime == schedule.EndTime {
+		return errors.New("start and end times must be different")
+	}
+	return nil
+}
+
+func describeChannelProfileSchedule(profile config.ChannelProfileT) string {
+	if !profile.Enabled {
+		return "disabled"
+	}
+	days := "every day"
+	if len(profile.Schedule.Days) > 0 {
+		days = strings.Join(profile.Schedule.Days, ", ")
+	}
+	if profile.Schedule.StartTime == "" {
+		return days
+	}
+	return fmt.Sprintf("%s, %s-%s", days, profile.Schedule.StartTime, profile.Schedule.EndTime)
+}
+
+func getChannelProfilesHelpSection() fyne.CanvasObject {
+	markdownLines := []string{
+		"- **Channel Profiles** change your category, tags, language and content classification labels in the same request as each title update.",
+		"- With each title update, the **first** enabled profile whose schedule covers the current time is applied - use **Up** to prioritise a profile. If no profile is active, only the title is updated.",
+		"- A profile's **Title Template** replaces the main title template while it is active. Leave it empty to keep using the main template.",
+		"- **Category** and **Tags** can use variables, just like the title, e.g. **$$StreamCategory**. The category is matched by name, falling back to the closest Twitch search result.",
+		"- Tags may only contain letters and numbers, so spaces and special characters are removed, and each tag is cut to 25 characters. Twitch allows at most 10 tags.",
+		"- Empty fields leave that setting unchanged. When **Set content classification labels** is ticked, any label which is not selected is removed from your stream.",
+		"- The schedule uses your computer's local time. No days means every day, and no times means all day. A window such as **22:00** until **02:00** runs past midnight, and belongs to the day it starts on.",
+		"- If a category cannot be found or a variable has no value, that setting is left unchanged and the title is still updated.",
+		fmt.Sprintf("- **AI-Generated Tags** are generated by an AI-Generated Variable whose **Structured Output** mode is **%s**. Its prompt is given the stream's category and current title, along with its knowledge base documents.", config.VariableModeTags),
+		"- Generated tags are cleaned up to follow Twitch's tag rules, then added after the **Always-On Tags**. If there are more than 10, the always-on tags are kept first.",
+		"- Tags are updated on their own schedule, separately from the title. While AI-Generated Tags are enabled, they replace the tags of any channel profile.",
+		"- If the tags cannot be generated, the variable's **On Failure** settings decide what is used instead - if there is nothing to use, the current tags are kept and Tidal carries on.",
+	}
+	return helpSectionWrapper("", markdownLines)
+}
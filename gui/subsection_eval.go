@@ -0,0 +1,141 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/eval"
+)
+
+var evalWindowSize fyne.Size = fyne.NewSize(800, 1) // height 1 lets the layout determine the height
+
+const reportRowsVisible = 16
+
+func (g *GuiWrapper) getEvalSubsection() *fyne.Container {
+
+	_, aiGeneratedVariablesMap := config.GetAllAiGeneratedVariables()
+	variableNames := make([]string, 0, len(config.Preferences.AiGeneratedVariables))
+	for _, v := range config.Preferences.AiGeneratedVariables {
+		variableNames = append(variableNames, v.Name)
+	}
+
+	variableSelect := widget.NewSelect(variableNames, nil)
+	if len(variableNames) > 0 {
+		variableSelect.SetSelected(variableNames[0])
+	}
+
+	runsEntry := widget.NewEntry()
+	runsEntry.SetText(strconv.Itoa(eval.DefaultRuns))
+
+	snapshotsEntry := widget.NewEntry()
+	snapshotsEntry.SetPlaceHolder("Optional - defaults to the most recently recorded values")
+	snapshotsBrowseButton := widget.NewButtonWithIcon("Browse", theme.FolderOpenIcon(), func() {
+		fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				showErrorDialog(err, fmt.Sprintf("Unable to open the snapshots file - %v", err), g.SecondaryWindow)
+				return
+			}
+			if reader == nil {
+				return // cancelled
+			}
+			defer reader.Close()
+			snapshotsEntry.SetText(reader.URI().Path())
+		}, g.SecondaryWindow)
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+		fileDialog.Show()
+	})
+
+	progressBar := widget.NewProgressBar()
+	progressBar.Hide()
+
+	reportEntry := widget.NewMultiLineEntry()
+	reportEntry.Wrapping = fyne.TextWrapWord
+	reportEntry.TextStyle = fyne.TextStyle{Monospace: true}
+	reportEntry.SetPlaceHolder("The report will appear here")
+	reportEntry.SetMinRowsVisible(reportRowsVisible)
+
+	var cancelEval context.CancelFunc
+	runButton := widget.NewButton("Run", nil)
+	cancelButton := widget.NewButton("Cancel", func() {
+		if cancelEval != nil {
+			cancelEval()
+		}
+	})
+	cancelButton.Disable()
+
+	runButton.OnTapped = func() {
+		variable, exists := aiGeneratedVariablesMap[variableSelect.Selected]
+		if !exists {
+			showErrorDialog(fmt.Errorf("variable %q does not exist", variableSelect.Selected), "Select a variable to evaluate.", g.SecondaryWindow)
+			return
+		}
+		runs, err := strconv.Atoi(strings.TrimSpace(runsEntry.Text))
+		if err != nil || runs < 1 || runs > eval.MaxRuns {
+			showErrorDialog(fmt.Errorf("invalid runs %q", runsEntry.Text), fmt.Sprintf("Runs must be a number between 1 and %v.", eval.MaxRuns), g.SecondaryWindow)
+			return
+		}
+		req, err := eval.NewRequest(config.Preferences, variable, runs, strings.TrimSpace(snapshotsEntry.Text), llmResponseTimeout)
+		if err != nil {
+			showErrorDialog(err, fmt.Sprintf("Unable to start the evaluation - %v", err), g.SecondaryWindow)
+			return
+		}
+
+		var ctx context.Context
+		ctx, cancelEval = context.WithCancel(context.Background())
+		runButton.Disable()
+		cancelButton.Enable()
+		progressBar.SetValue(0)
+		progressBar.Show()
+		reportEntry.SetText("")
+
+		go func() {
+			defer cancelEval()
+			report, err := eval.Run(ctx, req, func(done int, total int) {
+				fyne.Do(func() { progressBar.SetValue(float64(done) / float64(total)) })
+			})
+			reportText := report.String()
+			if err != nil {
+				config.Logger.LogErrorf("evaluation of %v stopped early - err: %v", variable.Name, err)
+				reportText = fmt.Sprintf("Evaluation stopped early - %v\n\n%s", err, reportText)
+			}
+			fyne.Do(func() {
+				reportEntry.SetText(reportText)
+				progressBar.Hide()
+				cancelButton.Disable()
+				runButton.Enable()
+			})
+		}()
+	}
+
+	form := container.New(
+		layout.NewFormLayout(),
+		widget.NewLabel("Variable"), variableSelect,
+		widget.NewLabel("Runs"), runsEntry,
+		widget.NewLabel("Snapshots File"), container.NewBorder(nil, nil, nil, snapshotsBrowseButton, snapshotsEntry),
+		layout.NewSpacer(), container.New(layout.NewGridLayoutWithColumns(2), runButton, cancelButton),
+		layout.NewSpacer(), progressBar,
+	)
+
+	return container.New(layout.NewVBoxLayout(), form, reportEntry)
+}
+
+func getEvalHelpSection() fyne.CanvasObject {
+	markdownLines := []string{
+		"- **Evaluate** runs an AI-Generated Variable's prompt several times without publishing anything, so you can check a new prompt before using it on stream.",
+		"- By default, the prompt uses the values most recently recorded while Tidal was running. To test other situations, enter or **Browse** to a **Snapshots File** - a JSON list of **{\"name\", \"values\"}** objects, where **values** maps variable names (e.g. **StreamCategory**) to the values to substitute.",
+		"- The report shows the length of the values generated, how often they hit the moderation blocklists or failed validation, how many were duplicates, and how long each request took. Each value is checked once, without being regenerated.",
+		"- The same evaluation can be run from a terminal with **tidal eval -variable Name -runs 10 -snapshots file.json**.",
+		"- Evaluation requests count towards the LLM Usage budget.",
+	}
+	return helpSectionWrapper("", markdownLines)
+}
@@ -1,60 +1,182 @@
-package gui
-
-import (
-	"fmt"
-
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/layout"
-	"fyne.io/fyne/v2/widget"
-	"github.com/finahdinner/tidal/config"
-	"github.com/finahdinner/tidal/llm"
-)
-
-var llmConfigWindowSize fyne.Size = fyne.NewSize(600, 1) // height 1 lets the layout determine the height
-
-func (g *GuiWrapper) getLlmConfigSubsection() *fyne.Container {
-
-	saveButton := widget.NewButton("Save", nil)
-
-	llmProviderSelect := widget.NewSelect(llm.LlmProviders, nil)
-	llmProviderSelect.SetSelected(config.Preferences.LlmConfig.Provider)
-
-	llmApiKeyEntry := widget.NewPasswordEntry()
-	llmApiKeyEntry.SetText(config.Preferences.LlmConfig.ApiKey)
-
-	defaultPromptSuffixEntry := getMultilineEntry(
-		config.Preferences.LlmConfig.DefaultPromptSuffix,
-		saveButton, tallerMultilineEntryHeight, fyne.ScrollVerticalOnly, fyne.TextWrapWord,
-	)
-
-	saveButton.OnTapped = func() {
-		config.Preferences.LlmConfig = config.LlmConfigT{
-			Provider:            llmProviderSelect.Selected,
-			ApiKey:              llmApiKeyEntry.Text,
-			DefaultPromptSuffix: defaultPromptSuffixEntry.Text,
-		}
-		if err := config.SavePreferences(); err != nil {
-			showErrorDialog(
-				fmt.Errorf("unable to save LLM configuration - err: %w", err),
-				"Unable to save LLM configuration.",
-				g.SecondaryWindow,
-			)
-			return
-		}
-		saveButton.Disable()
-		g.closeSecondaryWindow()
-	}
-
-	return container.New(
-		layout.NewFormLayout(),
-		widget.NewLabel("Provider"),
-		llmProviderSelect,
-		widget.NewLabel("API Key"),
-		llmApiKeyEntry,
-		widget.NewLabel("Default Prompt Suffix"),
-		defaultPromptSuffixEntry,
-		layout.NewSpacer(),
-		saveButton,
-	)
-}
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/llm"
+)
+
+var llmConfigWindowSize fyne.Size = fyne.NewSize(600, 1) // height 1 lets the layout determine the height
+
+func (g *GuiWrapper) getLlmConfigSubsection() *fyne.Container {
+
+	saveButton := widget.NewButton("Save", nil)
+
+	llmProviderSelect := widget.NewSelect(llm.LlmProviders, nil)
+	llmProviderSelect.SetSelected(config.Preferences.LlmConfig.Provider)
+
+	llmApiKeyEntry := widget.NewPasswordEntry()
+	llmApiKeyEntry.SetText(config.Preferences.LlmConfig.ApiKey)
+
+	defaultPromptSuffixEntry := getMultilineEntry(
+		config.Preferences.LlmConfig.DefaultPromptSuffix,
+		saveButton, tallerMultilineEntryHeight, fyne.ScrollVerticalOnly, fyne.TextWrapWord,
+	)
+
+	providerProfiles := append([]config.LlmProviderProfileT{}, config.Preferences.LlmConfig.ProviderProfiles...)
+
+	profileNameEntry := widget.NewEntry()
+	profileProviderSelect := widget.NewSelect(llm.LlmProviders, nil)
+	profileApiKeyEntry := widget.NewPasswordEntry()
+	profileModelEntry := widget.NewEntry()
+	profileModelEntry.SetPlaceHolder("Provider default")
+	profileBaseUrlEntry := widget.NewEntry()
+	profileBaseUrlEntry.SetPlaceHolder("Provider default")
+	profileScriptPathEntry := widget.NewEntry()
+	profileScriptPathEntry.SetPlaceHolder("Mock provider only - defaults to echoing the variable name")
+
+	providerProfilesList := container.New(layout.NewVBoxLayout())
+
+	var refreshProviderProfilesList func()
+	refreshProviderProfilesList = func() {
+		providerProfilesList.Objects = []fyne.CanvasObject{}
+		if len(providerProfiles) == 0 {
+			providerProfilesList.Objects = append(providerProfilesList.Objects, widget.NewLabel("-"))
+		}
+		for idx, profile := range providerProfiles {
+			editBtn := widget.NewButton("Edit", func() {
+				profileNameEntry.SetText(profile.Name)
+				profileProviderSelect.SetSelected(profile.Provider)
+				profileApiKeyEntry.SetText(profile.ApiKey)
+				profileModelEntry.SetText(profile.Model)
+				profileBaseUrlEntry.SetText(profile.BaseUrl)
+				profileScriptPathEntry.SetText(profile.ScriptPath)
+			})
+			removeBtn := widget.NewButton("Remove", func() {
+				providerProfiles = append(providerProfiles[:idx], providerProfiles[idx+1:]...)
+				refreshProviderProfilesList()
+				saveButton.Enable()
+			})
+			btns := container.New(layout.NewHBoxLayout(), editBtn, removeBtn)
+			label := widget.NewLabel(fmt.Sprintf("%s (%s)", profile.Name, profile.Provider))
+			providerProfilesList.Objects = append(
+				providerProfilesList.Objects,
+				container.New(layout.NewBorderLayout(nil, nil, nil, btns), btns, label),
+			)
+		}
+		providerProfilesList.Refresh()
+	}
+	refreshProviderProfilesList()
+
+	addProfileButton := widget.NewButton("Add / Update Profile", func() {
+		profile := config.LlmProviderProfileT{
+			Name:       strings.TrimSpace(profileNameEntry.Text),
+			Provider:   profileProviderSelect.Selected,
+			ApiKey:     profileApiKeyEntry.Text,
+			Model:      strings.TrimSpace(profileModelEntry.Text),
+			BaseUrl:    strings.TrimSpace(profileBaseUrlEntry.Text),
+			ScriptPath: strings.TrimSpace(profileScriptPathEntry.Text),
+		}
+		if err := validateProviderProfile(profile); err != nil {
+			showErrorDialog(err, fmt.Sprintf("Unable to add profile - %v", err), g.SecondaryWindow)
+			return
+		}
+		replaced := false
+		for idx, existing := range providerProfiles {
+			if existing.Name == profile.Name {
+				providerProfiles[idx] = profile
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			providerProfiles = append(providerProfiles, profile)
+		}
+		for _, entry := range []*widget.Entry{profileNameEntry, profileApiKeyEntry, profileModelEntry, profileBaseUrlEntry, profileScriptPathEntry} {
+			entry.SetText("")
+		}
+		profileProviderSelect.ClearSelected()
+		refreshProviderProfilesList()
+		saveButton.Enable()
+	})
+
+	profileEditor := container.New(
+		layout.NewFormLayout(),
+		widget.NewLabel("Name"), profileNameEntry,
+		widget.NewLabel("Provider"), profileProviderSelect,
+		widget.NewLabel("API Key"), profileApiKeyEntry,
+		widget.NewLabel("Model"), profileModelEntry,
+		widget.NewLabel("Base URL"), profileBaseUrlEntry,
+		widget.NewLabel("Script File"), profileScriptPathEntry,
+		layout.NewSpacer(), container.New(layout.NewBorderLayout(nil, nil, nil, addProfileButton), addProfileButton),
+	)
+
+	saveButton.OnTapped = func() {
+		newLlmConfig := config.Preferences.LlmConfig // keeps the prices and budget
+		newLlmConfig.Provider = llmProviderSelect.Selected
+		newLlmConfig.ApiKey = llmApiKeyEntry.Text
+		newLlmConfig.DefaultPromptSuffix = defaultPromptSuffixEntry.Text
+		newLlmConfig.ProviderProfiles = providerProfiles
+		// ensure no variable is left referencing a removed profile
+		for _, v := range config.Preferences.AiGeneratedVariables {
+			if _, err := llm.GetProviderChain(newLlmConfig, v); err != nil {
+				showErrorDialog(
+					fmt.Errorf("variable %q has an invalid provider chain - err: %w", v.Name, err),
+					fmt.Sprintf("Unable to save - AI-generated variable %q uses a provider profile that no longer exists.", v.Name),
+					g.SecondaryWindow,
+				)
+				return
+			}
+		}
+		config.Preferences.LlmConfig = newLlmConfig
+		if err := config.SavePreferences(); err != nil {
+			showErrorDialog(
+				fmt.Errorf("unable to save LLM configuration - err: %w", err),
+				"Unable to save LLM configuration.",
+				g.SecondaryWindow,
+			)
+			return
+		}
+		saveButton.Disable()
+		g.closeSecondaryWindow()
+	}
+
+	return container.New(
+		layout.NewFormLayout(),
+		widget.NewLabel("Provider"),
+		llmProviderSelect,
+		widget.NewLabel("API Key"),
+		llmApiKeyEntry,
+		widget.NewLabel("Default Prompt Suffix"),
+		defaultPromptSuffixEntry,
+		widget.NewLabel("Provider Profiles"),
+		providerProfilesList,
+		layout.NewSpacer(),
+		profileEditor,
+		layout.NewSpacer(),
+		saveButton,
+	)
+}
+
+func validateProviderProfile(profile config.LlmProviderProfileT) error {
+	if profile.Name == "" {
+		return errors.New("profile name must not be empty")
+	}
+	if profile.Name == config.DefaultProviderProfileName {
+		return fmt.Errorf("profile name %q is reserved", config.DefaultProviderProfileName)
+	}
+	if strings.Contains(profile.Name, ",") {
+		return errors.New("profile name must not contain commas")
+	}
+	if profile.Provider == "" {
+		return errors.New("a provider must be selected")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/config"
+)
+
+var moderationWindowSize fyne.Size = fyne.NewSize(600, 1) // height 1 lets the layout determine the height
+
+func (g *GuiWrapper) getModerationSubsection() *fyne.Container {
+
+	moderationConfig := config.Preferences.Moderation
+
+	saveButton := widget.NewButton("Save", nil)
+	saveButton.Disable()
+
+	enabledCheck := widget.NewCheck("Moderate AI-generated variables and titles", nil)
+	enabledCheck.SetChecked(moderationConfig.Enabled)
+
+	severeBlocklistEntry := getMultilineEntry(strings.Join(moderationConfig.SevereBlocklist, "\n"), saveButton, standardMultilineEntryHeight, fyne.ScrollVerticalOnly, fyne.TextWrapOff)
+	severeBlocklistEntry.SetPlaceHolder("One word or phrase per line")
+	mildBlocklistEntry := getMultilineEntry(strings.Join(moderationConfig.MildBlocklist, "\n"), saveButton, standardMultilineEntryHeight, fyne.ScrollVerticalOnly, fyne.TextWrapOff)
+	mildBlocklistEntry.SetPlaceHolder("One word or phrase per line")
+	allowlistEntry := getMultilineEntry(strings.Join(moderationConfig.Allowlist, "\n"), saveButton, 3, fyne.ScrollVerticalOnly, fyne.TextWrapOff)
+	allowlistEntry.SetPlaceHolder("Words which must never be blocked")
+
+	normaliseLeetspeakCheck := widget.NewCheck("Normalise leetspeak (e.g. h3ll0 -> hello)", nil)
+	normaliseLeetspeakCheck.SetChecked(moderationConfig.NormaliseLeetspeak)
+
+	severeActionSelect := widget.NewSelect(config.ModerationActions, nil)
+	severeActionSelect.SetSelected(moderationConfig.SevereAction)
+	mildActionSelect := widget.NewSelect(config.ModerationActions, nil)
+	mildActionSelect.SetSelected(moderationConfig.MildAction)
+
+	useTwitchAutoModCheck := widget.NewCheck("Check the final title with Twitch AutoMod", nil)
+	useTwitchAutoModCheck.SetChecked(moderationConfig.UseTwitchAutoMod)
+
+	for _, check := range []*widget.Check{enabledCheck, normaliseLeetspeakCheck, useTwitchAutoModCheck} {
+		check.OnChanged = func(_ bool) { saveButton.Enable() }
+	}
+	for _, sel := range []*widget.Select{severeActionSelect, mildActionSelect} {
+		sel.OnChanged = func(_ string) { saveButton.Enable() }
+	}
+
+	saveButton.OnTapped = func() {
+		config.Preferences.Moderation = config.ModerationConfigT{
+			Enabled:            enabledCheck.Checked,
+			SevereBlocklist:    splitLines(severeBlocklistEntry.Text),
+			MildBlocklist:      splitLines(mildBlocklistEntry.Text),
+			Allowlist:          splitLines(allowlistEntry.Text),
+			NormaliseLeetspeak: normaliseLeetspeakCheck.Checked,
+			SevereAction:       severeActionSelect.Selected,
+			MildAction:         mildActionSelect.Selected,
+			UseTwitchAutoMod:   useTwitchAutoModCheck.Checked,
+		}
+		if err := config.SavePreferences(); err != nil {
+			showErrorDialog(
+				fmt.Errorf("unable to save moderation configuration - err: %w", err),
+				"Unable to save moderation configuration.",
+				g.SecondaryWindow,
+			)
+			return
+		}
+		saveButton.Disable()
+		g.closeSecondaryWindow()
+	}
+
+	return container.New(
+		layout.NewFormLayout(),
+		layout.NewSpacer(), enabledCheck,
+		widget.NewLabel("Severe Blocklist"), severeBlocklistEntry,
+		widget.NewLabel("When Severe"), severeActionSelect,
+		widget.NewLabel("Mild Blocklist"), mildBlocklistEntry,
+		widget.NewLabel("When Mild"), mildActionSelect,
+		widget.NewLabel("Allowlist"), allowlistEntry,
+		layout.NewSpacer(), normaliseLeetspeakCheck,
+		layout.NewSpacer(), useTwitchAutoModCheck,
+		layout.NewSpacer(), saveButton,
+	)
+}
+
+func getModerationHelpSection() fyne.CanvasObject {
+	markdownLines := []string{
+		"- **Moderation** checks every AI-Generated Variable value, then the final title, before anything is published to Twitch.",
+		"- Words and phrases in the **Blocklists** are matched case-insensitively as whole words, so **ass** does not match **class**.",
+		"- Start or end a term with an asterisk to also match it inside longer words - scam\\* matches **scammer**, and \\*scam\\* matches **antiscamming**. Add words to the **Allowlist** to stop them being matched by accident.",
+		"- **Normalise leetspeak** also catches blocked words written with numbers or symbols, such as **h3ll0**.",
+		"- **Mask** replaces matched words with asterisks, **Regenerate** asks the LLM for a new response explaining what was wrong, and **Reject** treats the response as a failure (see each variable's **On Failure** settings).",
+		"- For the final title, **Regenerate** and **Reject** both skip the update and keep the current title.",
+		"- **Twitch AutoMod** sends the final title to Twitch's AutoMod check before publishing. If the check fails or the title is not permitted, the title is not published. This requires re-authenticating with Twitch to grant the **moderation:read** scope.",
+	}
+	return helpSectionWrapper("", markdownLines)
+}
@@ -1,250 +1,467 @@
-package gui
-
-import (
-	"errors"
-	"fmt"
-	"regexp"
-	"strings"
-	"time"
-
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
-	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/layout"
-	"fyne.io/fyne/v2/theme"
-	"fyne.io/fyne/v2/widget"
-	"github.com/finahdinner/tidal/config"
-	"github.com/finahdinner/tidal/helpers"
-	"github.com/finahdinner/tidal/twitch"
-)
-
-var twitchConfigWindowSize fyne.Size = fyne.NewSize(800, 1) // height 1 lets the layout determine the height
-
-func (g *GuiWrapper) getTwitchConfigSubsection() *fyne.Container {
-
-	channelUsernameEntry := widget.NewEntry()
-	channelUserIdEntry := widget.NewPasswordEntry()
-	channelUserIdEntry.Disable()
-	appClientIdEntry := widget.NewPasswordEntry()
-	appClientSecretEntry := widget.NewPasswordEntry()
-	appClientRedirectUri := widget.NewEntry()
-	channelAccessTokenEntry := widget.NewPasswordEntry()
-	channelAccessTokenEntry.Disable()
-
-	twitchConfig := config.Preferences.TwitchConfig
-
-	channelUsernameEntry.SetText(twitchConfig.UserName)
-	channelUserIdEntry.SetText(twitchConfig.UserId)
-	appClientIdEntry.SetText(twitchConfig.ClientId)
-	appClientSecretEntry.SetText(twitchConfig.ClientSecret)
-	appClientRedirectUri.SetText(twitchConfig.ClientRedirectUri)
-	channelAccessTokenEntry.SetText(twitchConfig.Credentials.UserAccessToken)
-
-	configForm := container.New(
-		layout.NewGridLayoutWithColumns(2),
-		widget.NewLabel("Twitch Username"), channelUsernameEntry,
-		widget.NewLabel("Client ID"), appClientIdEntry,
-		widget.NewLabel("Client Secret"), appClientSecretEntry,
-		widget.NewLabel("Redirect URI"), appClientRedirectUri,
-		horizontalSpacer(10), layout.NewSpacer(),
-		widget.NewLabel("Twitch User ID"), channelUserIdEntry,
-		widget.NewLabel("Access Token"), channelAccessTokenEntry,
-	)
-
-	channelHeader := canvas.NewText("Twitch Channel", theme.Color(theme.ColorNameForeground))
-	channelHeader.TextSize = headerSize
-
-	innerContainer := container.New(
-		layout.NewVBoxLayout(),
-		horizontalSpacer(10),
-		configForm,
-		horizontalSpacer(4),
-	)
-
-	saveConfigButton := widget.NewButton("Save", nil)
-	saveConfigButton.Disable()
-
-	authenticateButton := widget.NewButton("Authenticate", nil)
-
-	buttonContainer := container.New(layout.NewHBoxLayout(), saveConfigButton, authenticateButton)
-
-	bottomButtonRow := container.New(
-		layout.NewBorderLayout(nil, nil, nil, buttonContainer),
-		buttonContainer,
-	)
-
-	rightSpacer := verticalSpacer(30)
-	outerContainer := container.New(
-		layout.NewBorderLayout(nil, bottomButtonRow, nil, rightSpacer),
-		bottomButtonRow,
-		rightSpacer,
-		innerContainer,
-	)
-
-	for _, entry := range []*widget.Entry{
-		channelUsernameEntry, appClientIdEntry, appClientSecretEntry, appClientRedirectUri,
-	} {
-		entry.OnChanged = func(_ string) {
-			saveConfigButton.Enable()
-			authenticateButton.Disable()
-		}
-	}
-
-	saveConfigButton.OnTapped = func() {
-		prevPreferences := config.Preferences
-		if err := handleSaveTwitchConfig(
-			channelUsernameEntry, appClientIdEntry, appClientSecretEntry,
-			appClientRedirectUri, channelUserIdEntry, channelAccessTokenEntry,
-		); err != nil {
-			// restore old preferences
-			config.Preferences = prevPreferences
-			if err2 := config.SavePreferences(); err2 != nil {
-				err = err2
-			}
-			showErrorDialog(
-				err,
-				"Unable to save twitch configuration - see logs for details.",
-				g.SecondaryWindow,
-			)
-			return
-		}
-		saveConfigButton.Disable()
-		authenticateButton.Enable()
-	}
-
-	authenticateButton.OnTapped = func() {
-		go func() {
-			prevPreferences := config.Preferences
-			if err := handleAuthenticate(
-				channelUserIdEntry,
-				channelAccessTokenEntry,
-			); err != nil {
-				// restore old preferences
-				config.Preferences = prevPreferences
-				if err2 := config.SavePreferences(); err2 != nil {
-					err = err2
-				}
-				showErrorDialog(
-					err,
-					"Unable to authenticate using Twitch credentials - see logs for details.",
-					g.SecondaryWindow,
-				)
-				fyne.Do(func() { saveConfigButton.Enable() }) // to encourage to change settings + save again
-			}
-			fyne.Do(func() { authenticateButton.Disable() }) // to encourage to authenticate again
-		}()
-	}
-
-	return container.NewPadded(outerContainer)
-}
-
-func handleSaveTwitchConfig(
-	channelUsernameEntry *widget.Entry,
-	appClientIdEntry *widget.Entry,
-	appClientSecretEntry *widget.Entry,
-	appClientRedirectUri *widget.Entry,
-	channelUserIdEntry *widget.Entry,
-	channelAccessTokenEntry *widget.Entry,
-) error {
-	twitchUsername := channelUsernameEntry.Text
-	clientId := appClientIdEntry.Text
-	clientSecret := appClientSecretEntry.Text
-	clientRedirectUri := appClientRedirectUri.Text
-
-	if twitchUsername == "" || clientId == "" || clientSecret == "" || clientRedirectUri == "" {
-		return errors.New("not all twitch application fields were populated")
-	}
-
-	err := validateRedirectUri(clientRedirectUri)
-	if err != nil {
-		return errors.New("redirect URI is not valid")
-	}
-
-	config.Preferences.TwitchConfig = config.TwitchConfigT{
-		UserName:          twitchUsername,
-		UserId:            "",
-		ClientId:          clientId,
-		ClientSecret:      clientSecret,
-		ClientRedirectUri: clientRedirectUri,
-		Credentials:       config.CredentialsT{},
-	}
-
-	fyne.Do(func() {
-		channelUserIdEntry.SetText(config.Preferences.TwitchConfig.UserId)
-		channelAccessTokenEntry.SetText(config.Preferences.TwitchConfig.Credentials.UserAccessToken)
-	})
-
-	if err := config.SavePreferences(); err != nil {
-		return fmt.Errorf("unable to save preferences - err: %w", err)
-	}
-
-	return nil
-}
-
-func handleAuthenticate(channelUserIdEntry *widget.Entry, channelAccessTokenEntry *widget.Entry) error {
-	codeChan := make(chan string)
-	csrfToken := helpers.GenerateCsrfToken(32)
-	hostAndPort := strings.Replace(strings.Replace(config.Preferences.TwitchConfig.ClientRedirectUri, "https://", "", 1), "http://", "", 1)
-
-	if helpers.PortInUse(hostAndPort) {
-		config.Logger.LogInfof("%s is already in use - not creating a new one", hostAndPort)
-	} else {
-		config.Logger.LogInfo("creating authcode listener")
-		if err := twitch.CreateAuthCodeListener(hostAndPort, codeChan, csrfToken); err != nil {
-			return fmt.Errorf("unable to open listener port - error: %v", err)
-		}
-	}
-
-	twitch.SendGetRequestForAuthCode(csrfToken)
-	authCode := <-codeChan
-	config.Logger.LogInfof("auth code: %v", authCode)
-
-	userAccessTokenInfo, err := twitch.GetUserAccessTokenFromAuthCode(authCode)
-	if err != nil {
-		return fmt.Errorf("unable to retrieve user access token information - error: %v", err)
-	}
-	config.Logger.LogInfof("userAccessTokenInfo: %v", userAccessTokenInfo)
-
-	twitchUserId, err := twitch.GetTwitchUserId(userAccessTokenInfo.AccessToken)
-	if err != nil {
-		return fmt.Errorf("unable to retrieve twitch user id - error: %v", err)
-	}
-	config.Logger.LogInfof("twitchUserId: %v", twitchUserId)
-
-	config.Preferences.TwitchConfig.Credentials = config.CredentialsT{
-		UserAccessToken:        userAccessTokenInfo.AccessToken,
-		UserAccessRefreshToken: userAccessTokenInfo.RefreshToken,
-		UserAccessScope:        userAccessTokenInfo.Scope,
-		ExpiryUnixTimestamp:    time.Now().Unix() + int64(userAccessTokenInfo.ExpiresIn),
-	}
-	config.Preferences.TwitchConfig.UserId = twitchUserId
-
-	if err := config.SavePreferences(); err != nil {
-		return fmt.Errorf("unable to save preferences - error: %v", err)
-	}
-
-	fyne.Do(func() {
-		channelUserIdEntry.SetText(config.Preferences.TwitchConfig.UserId)
-		channelAccessTokenEntry.SetText(config.Preferences.TwitchConfig.Credentials.UserAccessToken)
-	})
-
-	config.Logger.LogInfo("successfully authenticated (got access token + twitch user id)")
-	return nil
-}
-
-func validateRedirectUri(redirectUri string) error {
-	regexPattern := `^https?://localhost:\d+$`
-	compiledPattern, err := regexp.Compile(regexPattern)
-	if err != nil {
-		return fmt.Errorf("unable to parse regexPattern %s - %w", regexPattern, err)
-	}
-	redirectUriBytes := []byte(redirectUri)
-	isValid, err := regexp.Match(compiledPattern.String(), redirectUriBytes)
-	if err != nil {
-		return fmt.Errorf("unable to conduct comparison between pattern %s and redirectUri %s", regexPattern, redirectUri)
-	}
-	if !isValid {
-		return fmt.Errorf("redirectUri %s is not valid", redirectUri)
-	}
-	return nil
-}
+package gui
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+var twitchConfigWindowSize fyne.Size = fyne.NewSize(800, 1) // height 1 lets the layout determine the height
+
+func (g *GuiWrapper) getTwitchConfigSubsection() *fyne.Container {
+
+	channelUsernameEntry := widget.NewEntry()
+	channelUserIdEntry := widget.NewPasswordEntry()
+	channelUserIdEntry.Disable()
+	appClientIdEntry := widget.NewPasswordEntry()
+	appClientSecretEntry := widget.NewPasswordEntry()
+	appClientRedirectUri := widget.NewEntry()
+	channelAccessTokenEntry := widget.NewPasswordEntry()
+	channelAccessTokenEntry.Disable()
+
+	twitchConfig := config.Preferences.TwitchConfig
+
+	channelUsernameEntry.SetText(twitchConfig.UserName)
+	channelUserIdEntry.SetText(twitchConfig.UserId)
+	appClientIdEntry.SetText(twitchConfig.ClientId)
+	appClientSecretEntry.SetText(twitchConfig.ClientSecret)
+	appClientRedirectUri.SetText(twitchConfig.ClientRedirectUri)
+	channelAccessTokenEntry.SetText(twitchConfig.Credentials.UserAccessToken)
+
+	configForm := container.New(
+		layout.NewGridLayoutWithColumns(2),
+		widget.NewLabel("Twitch Username"), channelUsernameEntry,
+		widget.NewLabel("Client ID"), appClientIdEntry,
+		widget.NewLabel("Client Secret"), appClientSecretEntry,
+		widget.NewLabel("Redirect URI"), appClientRedirectUri,
+		horizontalSpacer(10), layout.NewSpacer(),
+		widget.NewLabel("Twitch User ID"), channelUserIdEntry,
+		widget.NewLabel("Access Token"), channelAccessTokenEntry,
+	)
+
+	channelHeader := canvas.NewText("Twitch Channel", theme.Color(theme.ColorNameForeground))
+	channelHeader.TextSize = headerSize
+
+	// enabled features whose scopes were not granted when the user last authenticated
+	missingScopesLabel := widget.NewLabel("")
+	missingScopesLabel.Wrapping = fyne.TextWrapWord
+	missingScopesLabel.Importance = widget.WarningImportance
+	grantScopesButton := widget.NewButton("Re-authenticate", nil)
+	missingScopesRow := container.New(
+		layout.NewBorderLayout(nil, nil, nil, grantScopesButton),
+		grantScopesButton,
+		missingScopesLabel,
+	)
+	refreshMissingScopes := func() {
+		missingScopes := twitch.GetMissingScopes(config.Preferences)
+		if config.Preferences.TwitchConfig.Credentials.UserAccessToken == "" || len(missingScopes) == 0 {
+			missingScopesRow.Hide()
+			return
+		}
+		missingScopesLabel.SetText(getMissingScopesText(missingScopes))
+		missingScopesRow.Show()
+	}
+	refreshMissingScopes()
+
+	innerContainer := container.New(
+		layout.NewVBoxLayout(),
+		horizontalSpacer(10),
+		configForm,
+		missingScopesRow,
+		horizontalSpacer(4),
+	)
+
+	saveConfigButton := widget.NewButton("Save", nil)
+	saveConfigButton.Disable()
+
+	authenticateButton := widget.NewButton("Authenticate", nil)
+	deviceCodeButton := widget.NewButton("Use Device Code", nil)
+
+	disconnectButton := widget.NewButton("Disconnect Twitch", nil)
+	if twitchConfig.Credentials.UserAccessToken == "" {
+		disconnectButton.Disable()
+	}
+
+	buttonContainer := container.New(layout.NewHBoxLayout(), disconnectButton, saveConfigButton, authenticateButton, deviceCodeButton)
+
+	bottomButtonRow := container.New(
+		layout.NewBorderLayout(nil, nil, nil, buttonContainer),
+		buttonContainer,
+	)
+
+	rightSpacer := verticalSpacer(30)
+	outerContainer := container.New(
+		layout.NewBorderLayout(nil, bottomButtonRow, nil, rightSpacer),
+		bottomButtonRow,
+		rightSpacer,
+		innerContainer,
+	)
+
+	for _, entry := range []*widget.Entry{
+		channelUsernameEntry, appClientIdEntry, appClientSecretEntry, appClientRedirectUri,
+	} {
+		entry.OnChanged = func(_ string) {
+			saveConfigButton.Enable()
+			authenticateButton.Disable()
+			deviceCodeButton.Disable()
+		}
+	}
+
+	saveConfigButton.OnTapped = func() {
+		prevPreferences := config.Preferences
+		if err := handleSaveTwitchConfig(
+			channelUsernameEntry, appClientIdEntry, appClientSecretEntry,
+			appClientRedirectUri, channelUserIdEntry, channelAccessTokenEntry,
+		); err != nil {
+			// restore old preferences
+			config.Preferences = prevPreferences
+			if err2 := config.SavePreferences(); err2 != nil {
+				err = err2
+			}
+			showErrorDialog(
+				err,
+				"Unable to save twitch configuration - see logs for details.",
+				g.SecondaryWindow,
+			)
+			return
+		}
+		saveConfigButton.Disable()
+		authenticateButton.Enable()
+		deviceCodeButton.Enable()
+	}
+
+	authenticateButton.OnTapped = func() {
+		authenticateButton.Disable() // one listener at a time
+		go func() {
+			prevPreferences := config.Preferences
+			if err := handleAuthenticate(
+				channelUserIdEntry,
+				channelAccessTokenEntry,
+			); err != nil {
+				// restore old preferences
+				config.Preferences = prevPreferences
+				if err2 := config.SavePreferences(); err2 != nil {
+					err = err2
+				}
+				showErrorDialog(err, getAuthenticateErrorText(err), g.SecondaryWindow)
+				fyne.Do(func() { saveConfigButton.Enable() }) // to encourage to change settings + save again
+			}
+			fyne.Do(func() {
+				authenticateButton.Disable() // to encourage to authenticate again
+				if config.Preferences.TwitchConfig.Credentials.UserAccessToken != "" {
+					disconnectButton.Enable()
+				}
+				refreshMissingScopes()
+			})
+		}()
+	}
+
+	deviceCodeButton.OnTapped = func() {
+		deviceCodeButton.Disable()
+		g.authenticateWithDeviceCode(func(err error) {
+			deviceCodeButton.Enable()
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					showErrorDialog(
+						err,
+						"Unable to authenticate using a device code - see logs for details.",
+						g.SecondaryWindow,
+					)
+				}
+				return
+			}
+			channelUserIdEntry.SetText(config.Preferences.TwitchConfig.UserId)
+			channelAccessTokenEntry.SetText(config.Preferences.TwitchConfig.Credentials.UserAccessToken)
+			disconnectButton.Enable()
+			refreshMissingScopes()
+		})
+	}
+
+	// requests every scope the enabled features need, with whichever flow the application is set up for
+	grantScopesButton.OnTapped = func() {
+		button := deviceCodeButton
+		if config.Preferences.TwitchConfig.ClientSecret != "" && config.Preferences.TwitchConfig.ClientRedirectUri != "" {
+			button = authenticateButton
+		}
+		if !button.Disabled() {
+			button.OnTapped()
+		} else if !saveConfigButton.Disabled() {
+			showInfoDialog("Re-authenticate", "Save your Twitch configuration before re-authenticating.", g.SecondaryWindow)
+		} else {
+			showInfoDialog("Re-authenticate", "Authentication is already in progress.", g.SecondaryWindow)
+		}
+	}
+
+	disconnectButton.OnTapped = func() {
+		if updaterTicker != nil {
+			// a running update would carry on with the wiped credentials
+			showInfoDialog("Disconnect Twitch", "Stop Tidal before disconnecting from Twitch.", g.SecondaryWindow)
+			return
+		}
+		dialog.ShowConfirm(
+			"Disconnect Twitch",
+			"Revoke Tidal's access to your Twitch account?\nYou will need to authenticate again before Tidal can update your title.",
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				go func() {
+					ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+					defer cancel()
+					if err := twitch.Disconnect(ctx); err != nil {
+						showErrorDialog(
+							err,
+							"Unable to disconnect from Twitch - see logs for details.",
+							g.SecondaryWindow,
+						)
+						return
+					}
+					fyne.Do(func() {
+						channelAccessTokenEntry.SetText("")
+						disconnectButton.Disable()
+						authenticateButton.Enable()
+						refreshMissingScopes()
+					})
+				}()
+			},
+			g.SecondaryWindow,
+		)
+	}
+
+	return container.NewPadded(outerContainer)
+}
+
+func getMissingScopesText(missingScopes []twitch.MissingScopesT) string {
+	lines := []string{"Re-authenticate to grant the scopes these enabled features need:"}
+	for _, missing := range missingScopes {
+		lines = append(lines, fmt.Sprintf("- %s (%s)", missing.Feature, strings.Join(missing.Scopes, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Opens the Twitch Configuration window, e.g. so the user can re-authenticate
+func (g *GuiWrapper) openTwitchConfigWindow() {
+	configSection := g.getTwitchConfigSubsection()
+	g.openSecondaryWindow(
+		"Twitch Configuration",
+		secondaryWindowSectionWrapper(
+			"Twitch Configuration",
+			configSection,
+			getTwitchConfigurationHelpSection(configSection.MinSize()),
+		),
+		&twitchConfigWindowSize,
+	)
+}
+
+func getTwitchConfigurationHelpSection(minSize fyne.Size) fyne.CanvasObject {
+	markdownLines := []string{
+		`In order for Tidal to change your Twitch stream's title, you will need to create a **Developer Application**, by following the instructions below.`,
+		"- Navigate to the Twitch Developer Console, found at **https://dev.twitch.tv/console**",
+		`- Under **Applications**, click **Register Your Application**, and populate the fields with the given values:`,
+		"-> `Name`:" + `**AnyUniqueNameLikeThis**`,
+		"-> `OAuth Redirect URLs`: **http://localhost:17563** (a path such as **http://localhost:17563/callback** also works, as long as Tidal's **Redirect URI** matches it exactly)",
+		"-> `Category`: **Application Integration**",
+		"-> `Client Type`: **Confidential**",
+		"- Click **Create**, then click the **Manage** button next to your listed application.",
+		"- Click **New Secret**, then copy or write down your **Client Secret** in a safe place.",
+		"- Using the values above, populate the **Twitch Username**, **Client ID**, **Client Secret** and **Redirect URI** fields in the Tidal **Twitch Configuration**.",
+		"- Click **Save**, then **Authenticate**, and your browser will open. You have 5 minutes to authorise Tidal before it stops waiting.",
+		"- Ensure you are signed into your Twitch account (it must match the username you provided in Tidal), and **Authorize** the application.",
+		"- This populates the **Twitch User ID** and **Access Token** fields - your credentials are now set up!",
+		"**Note: if you ever change any field values, your Twitch User ID and Access Token will reset, meaning you need to re-authorize.**",
+		"- To test Tidal against the Twitch CLI `mock-api` (or another local server), set `api_base_url` and `auth_base_url` in your preferences file, e.g. **http://localhost:8080/mock** and **http://localhost:8080/auth**.",
+		"**Device Code:** if Tidal runs on a machine without a browser, or you cannot register a redirect URI, create the application with `Client Type` **Public** instead.",
+		"- Populate only the **Twitch Username** and **Client ID** fields, click **Save**, then **Use Device Code**.",
+		"- Visit the URL shown on any device, enter the code, and **Authorize** the application - Tidal finishes authenticating by itself.",
+		"- From a terminal, run `tidal auth -username <name> -client-id <id>` to do the same without the GUI.",
+		"**Scopes:** each Tidal feature asks Twitch only for the permissions (scopes) it needs. If you enable a feature after authenticating, e.g. **Send chat message per title update**, the features missing scopes are listed here - click **Re-authenticate** to grant them.",
+		"- Click **Disconnect Twitch** to revoke Tidal's access token and remove your credentials from Tidal. Tidal must be stopped first.",
+		"- Tidal refreshes your Access Token automatically, and checks it with Twitch when it starts and every hour. If Twitch rejects the refresh (e.g. because you disconnected the application from your Twitch account), you will be asked to **Authenticate** again.",
+	}
+	scroll := container.NewVScroll(helpSectionWrapper("", markdownLines))
+	scroll.SetMinSize(minSize)
+	return scroll
+}
+
+func handleSaveTwitchConfig(
+	channelUsernameEntry *widget.Entry,
+	appClientIdEntry *widget.Entry,
+	appClientSecretEntry *widget.Entry,
+	appClientRedirectUri *widget.Entry,
+	channelUserIdEntry *widget.Entry,
+	channelAccessTokenEntry *widget.Entry,
+) error {
+	twitchUsername := channelUsernameEntry.Text
+	clientId := appClientIdEntry.Text
+	clientSecret := appClientSecretEntry.Text
+	clientRedirectUri := appClientRedirectUri.Text
+
+	// the client secret and redirect uri are only needed to authenticate in the browser, not with a device code
+	if twitchUsername == "" || clientId == "" {
+		return errors.New("the twitch username and client id must be populated")
+	}
+
+	if clientRedirectUri != "" {
+		if err := validateRedirectUri(clientRedirectUri); err != nil {
+			return errors.New("redirect URI is not valid")
+		}
+	}
+
+	config.Preferences.TwitchConfig = config.TwitchConfigT{
+		UserName:          twitchUsername,
+		UserId:            "",
+		ClientId:          clientId,
+		ClientSecret:      clientSecret,
+		ClientRedirectUri: clientRedirectUri,
+		Credentials:       config.CredentialsT{},
+		ApiBaseUrl:        config.Preferences.TwitchConfig.ApiBaseUrl,
+		AuthBaseUrl:       config.Preferences.TwitchConfig.AuthBaseUrl,
+	}
+
+	fyne.Do(func() {
+		channelUserIdEntry.SetText(config.Preferences.TwitchConfig.UserId)
+		channelAccessTokenEntry.SetText(config.Preferences.TwitchConfig.Credentials.UserAccessToken)
+	})
+
+	if err := config.SavePreferences(); err != nil {
+		return fmt.Errorf("unable to save preferences - err: %w", err)
+	}
+
+	return nil
+}
+
+func handleAuthenticate(channelUserIdEntry *widget.Entry, channelAccessTokenEntry *widget.Entry) error {
+	if config.Preferences.TwitchConfig.ClientSecret == "" || config.Preferences.TwitchConfig.ClientRedirectUri == "" {
+		return errors.New("authenticating in the browser needs a client secret and redirect URI - populate them, or use a device code instead")
+	}
+
+	csrfToken := helpers.GenerateCsrfToken(32)
+
+	config.Logger.LogInfo("creating authcode listener")
+	resultChan, err := twitch.ListenForAuthCode(context.Background(), config.Preferences.TwitchConfig.ClientRedirectUri, csrfToken)
+	if err != nil {
+		return fmt.Errorf("unable to open listener port - error: %w", err)
+	}
+
+	twitch.SendGetRequestForAuthCode(csrfToken)
+	result := <-resultChan
+	if result.Err != nil {
+		return fmt.Errorf("unable to receive auth code - error: %w", result.Err)
+	}
+	config.Logger.LogInfo("received auth code")
+
+	userAccessTokenInfo, err := twitch.GetUserAccessTokenFromAuthCode(result.Code)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve user access token information - error: %v", err)
+	}
+	config.Logger.LogInfof("userAccessTokenInfo: %v", userAccessTokenInfo)
+
+	if err := twitch.SaveAuthentication(userAccessTokenInfo); err != nil {
+		return err
+	}
+
+	fyne.Do(func() {
+		channelUserIdEntry.SetText(config.Preferences.TwitchConfig.UserId)
+		channelAccessTokenEntry.SetText(config.Preferences.TwitchConfig.Credentials.UserAccessToken)
+	})
+	return nil
+}
+
+// Shows the device code to enter at Twitch, and waits for the user to authorise it.
+// onDone is called on the UI thread, with context.Canceled if the user cancelled.
+func (g *GuiWrapper) authenticateWithDeviceCode(onDone func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer cancel()
+		done := func(err error) { fyne.Do(func() { onDone(err) }) }
+
+		deviceCode, err := twitch.RequestDeviceCode(ctx)
+		if err != nil {
+			done(err)
+			return
+		}
+
+		var deviceCodeDialog dialog.Dialog
+		fyne.Do(func() {
+			userCodeEntry := widget.NewEntry()
+			userCodeEntry.SetText(deviceCode.UserCode)
+			userCodeEntry.TextStyle = fyne.TextStyle{Bold: true, Monospace: true}
+
+			openUrlBtn := widget.NewButton("Open Link", func() {
+				helpers.OpenUrlInBrowser(deviceCode.VerificationUri)
+			})
+			copyCodeBtn := widget.NewButton("Copy Code", func() {
+				g.App.Clipboard().SetContent(deviceCode.UserCode)
+			})
+			cancelBtn := widget.NewButton("Cancel", func() {
+				cancel()
+			})
+
+			content := container.New(
+				layout.NewVBoxLayout(),
+				widget.NewLabel(fmt.Sprintf("On any device, go to %s and enter this code:", deviceCode.VerificationUri)),
+				userCodeEntry,
+				widget.NewLabel("Waiting for you to authorise Tidal..."),
+				container.New(layout.NewHBoxLayout(), layout.NewSpacer(), openUrlBtn, copyCodeBtn, cancelBtn, layout.NewSpacer()),
+			)
+			deviceCodeDialog = dialog.NewCustomWithoutButtons("Authenticate with a Device Code", content, g.SecondaryWindow)
+			deviceCodeDialog.Show()
+		})
+
+		userAccessTokenInfo, err := twitch.PollDeviceCodeToken(ctx, deviceCode)
+		if err == nil {
+			err = twitch.SaveAuthentication(userAccessTokenInfo)
+		}
+		fyne.Do(func() {
+			if deviceCodeDialog != nil {
+				deviceCodeDialog.Hide()
+			}
+		})
+		done(err)
+	}()
+}
+
+func getAuthenticateErrorText(err error) string {
+	var authorisationErr *twitch.AuthorisationError
+	switch {
+	case errors.As(err, &authorisationErr):
+		return fmt.Sprintf("Twitch did not authorise Tidal - %s.", cmp.Or(authorisationErr.Description, authorisationErr.Code))
+	case errors.Is(err, twitch.ErrAuthCodeTimeout):
+		return "Timed out waiting for you to authorise Tidal in your browser - please try again."
+	default:
+		return "Unable to authenticate using Twitch credentials - see logs for details."
+	}
+}
+
+func validateRedirectUri(redirectUri string) error {
+	regexPattern := `^https?://localhost:\d+(/[A-Za-z0-9._~\-/]*)?$` // an optional callback path
+	compiledPattern, err := regexp.Compile(regexPattern)
+	if err != nil {
+		return fmt.Errorf("unable to parse regexPattern %s - %w", regexPattern, err)
+	}
+	redirectUriBytes := []byte(redirectUri)
+	isValid, err := regexp.Match(compiledPattern.String(), redirectUriBytes)
+	if err != nil {
+		return fmt.Errorf("unable to conduct comparison between pattern %s and redirectUri %s", regexPattern, redirectUri)
+	}
+	if !isValid {
+		return fmt.Errorf("redirectUri %s is not valid", redirectUri)
+	}
+	return nil
+}
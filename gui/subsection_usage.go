@@ -0,0 +1,180 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/llm"
+)
+
+var usageWindowSize fyne.Size = fyne.NewSize(700, 1) // height 1 lets the layout determine the height
+
+func (g *GuiWrapper) getUsageSubsection() *fyne.Container {
+
+	llmConfig := config.Preferences.LlmConfig
+	now := time.Now()
+
+	saveButton := widget.NewButton("Save", nil)
+	saveButton.Disable()
+
+	// session usage, per variable
+	sessionGrid := container.NewGridWithColumns(5,
+		widget.NewLabelWithStyle("Source", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Calls", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Input Tokens", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Output Tokens", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle("Est. Cost", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+	)
+	sessionTotals := llm.Usage.SessionTotals()
+	sources := make([]string, 0, len(sessionTotals))
+	for source := range sessionTotals {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+	for _, source := range sources {
+		sessionGrid.Objects = append(sessionGrid.Objects, getUsageRowLabels(source, sessionTotals[source])...)
+	}
+	if len(sources) == 0 {
+		sessionGrid.Objects = append(sessionGrid.Objects, widget.NewLabel("No LLM requests this session"))
+	}
+	sessionGrid.Objects = append(sessionGrid.Objects, getUsageRowLabels("Today", llm.Usage.DayTotals(now))...)
+	sessionGrid.Objects = append(sessionGrid.Objects, getUsageRowLabels("This Month", llm.Usage.MonthTotals(now))...)
+
+	dailyLimitEntry := widget.NewEntry()
+	dailyLimitEntry.SetText(formatUsdLimit(llmConfig.Budget.DailyLimitUsd))
+	dailyLimitEntry.SetPlaceHolder("No limit")
+	monthlyLimitEntry := widget.NewEntry()
+	monthlyLimitEntry.SetText(formatUsdLimit(llmConfig.Budget.MonthlyLimitUsd))
+	monthlyLimitEntry.SetPlaceHolder("No limit")
+	for _, entry := range []*widget.Entry{dailyLimitEntry, monthlyLimitEntry} {
+		entry.OnChanged = func(_ string) { saveButton.Enable() }
+	}
+
+	prices := llmConfig.Prices
+	if prices == nil {
+		prices = config.DefaultLlmPrices
+	}
+	priceLines := make([]string, 0, len(prices))
+	for _, p := range prices {
+		priceLines = append(priceLines, fmt.Sprintf("%s, %v, %v", p.Model, p.InputPerMillionTokens, p.OutputPerMillionTokens))
+	}
+	pricesEntry := getMultilineEntry(strings.Join(priceLines, "\n"), saveButton, standardMultilineEntryHeight, fyne.ScrollVerticalOnly, fyne.TextWrapOff)
+	pricesEntry.SetPlaceHolder("model, input price per 1M tokens, output price per 1M tokens")
+
+	saveButton.OnTapped = func() {
+		dailyLimit, err := parseUsdLimit(dailyLimitEntry.Text)
+		if err != nil {
+			showErrorDialog(err, "Daily limit must be a positive number, or empty for no limit.", g.SecondaryWindow)
+			return
+		}
+		monthlyLimit, err := parseUsdLimit(monthlyLimitEntry.Text)
+		if err != nil {
+			showErrorDialog(err, "Monthly limit must be a positive number, or empty for no limit.", g.SecondaryWindow)
+			return
+		}
+		updatedPrices, err := parsePrices(pricesEntry.Text)
+		if err != nil {
+			showErrorDialog(err, fmt.Sprintf("Invalid price table - %v.", err), g.SecondaryWindow)
+			return
+		}
+		config.Preferences.LlmConfig.Budget = config.LlmBudgetT{DailyLimitUsd: dailyLimit, MonthlyLimitUsd: monthlyLimit}
+		config.Preferences.LlmConfig.Prices = updatedPrices
+		if err := config.SavePreferences(); err != nil {
+			showErrorDialog(
+				fmt.Errorf("unable to save LLM budget - err: %w", err),
+				"Unable to save LLM budget.",
+				g.SecondaryWindow,
+			)
+			return
+		}
+		saveButton.Disable()
+		g.closeSecondaryWindow()
+	}
+
+	return container.New(
+		layout.NewVBoxLayout(),
+		sessionGrid,
+		widget.NewSeparator(),
+		container.New(
+			layout.NewFormLayout(),
+			widget.NewLabel("Daily Limit ($)"), dailyLimitEntry,
+			widget.NewLabel("Monthly Limit ($)"), monthlyLimitEntry,
+			widget.NewLabel("Prices ($)"), pricesEntry,
+			layout.NewSpacer(), saveButton,
+		),
+	)
+}
+
+func getUsageRowLabels(source string, totals config.UsageTotalsT) []fyne.CanvasObject {
+	return []fyne.CanvasObject{
+		widget.NewLabel(source),
+		widget.NewLabel(strconv.Itoa(totals.Calls)),
+		widget.NewLabel(strconv.Itoa(totals.InputTokens)),
+		widget.NewLabel(strconv.Itoa(totals.OutputTokens)),
+		widget.NewLabel(fmt.Sprintf("$%.4f", totals.CostUsd)),
+	}
+}
+
+func formatUsdLimit(limit float64) string {
+	if limit <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(limit, 'f', -1, 64)
+}
+
+// An empty limit means no limit
+func parseUsdLimit(text string) (float64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse limit %q - err: %w", text, err)
+	}
+	if limit < 0 {
+		return 0, fmt.Errorf("limit %v must not be negative", limit)
+	}
+	return limit, nil
+}
+
+// Parses one "model, input price, output price" line per model
+func parsePrices(text string) ([]config.LlmPriceT, error) {
+	prices := []config.LlmPriceT{}
+	for _, line := range splitLines(text) {
+		parts := helpers.SplitCommaSeparated(line)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("line %q must have a model, an input price and an output price", line)
+		}
+		inputPrice, inputErr := strconv.ParseFloat(parts[1], 64)
+		outputPrice, outputErr := strconv.ParseFloat(parts[2], 64)
+		if err := errors.Join(inputErr, outputErr); err != nil || inputPrice < 0 || outputPrice < 0 {
+			return nil, fmt.Errorf("prices on line %q must be non-negative numbers", line)
+		}
+		prices = append(prices, config.LlmPriceT{
+			Model:                  parts[0],
+			InputPerMillionTokens:  inputPrice,
+			OutputPerMillionTokens: outputPrice,
+		})
+	}
+	return prices, nil
+}
+
+func getUsageHelpSection() fyne.CanvasObject {
+	markdownLines := []string{
+		"- **LLM Usage** shows the tokens used by each AI-Generated Variable (and the title judge) since Tidal was last started, along with totals for today and this month.",
+		"- Costs are estimates, calculated from the token counts reported by each provider and the **Prices** table. Models without a price are counted as free.",
+		"- Once the **Daily Limit** or **Monthly Limit** has been reached, no more LLM requests are sent. AI-Generated Variables are paused and reuse their last values (or follow their **On Failure** settings) until the next day or month.",
+	}
+	return helpSectionWrapper("", markdownLines)
+}
@@ -0,0 +1,233 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/llm"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+var (
+	tagsTicker     *time.Ticker
+	tagsTickerDone chan struct{}
+	tagsCancel     context.CancelFunc // cancels the running tags cycle
+)
+
+// Begins a ticker to update the stream tags, independently of the title.
+// Does nothing if AI-generated tags are disabled.
+func startTagsUpdater() error {
+	prefs := config.SnapshotPreferences()
+	tagsConfig := prefs.Tags
+	if !tagsConfig.Enabled {
+		return nil
+	}
+	if tagsTicker != nil {
+		return errors.New("tags ticker already running - stop it first")
+	}
+	if tagsConfig.UpdateIntervalMinutes < helpers.MinTitleUpdateIntervalMinutes ||
+		tagsConfig.UpdateIntervalMinutes > helpers.MaxTitleUpdateIntervalMinutes {
+		return fmt.Errorf(
+			"tags update interval (%v minutes) is not in the valid range between %v and %v",
+			tagsConfig.UpdateIntervalMinutes, helpers.MinTitleUpdateIntervalMinutes, helpers.MaxTitleUpdateIntervalMinutes,
+		)
+	}
+	if _, err := getTagsVariable(prefs); err != nil {
+		return err
+	}
+
+	tagsTicker = time.NewTicker(time.Duration(tagsConfig.UpdateIntervalMinutes) * time.Minute)
+	tagsTickerDone = make(chan struct{})
+	tagsCtx, cancelTags := context.WithCancel(context.Background())
+	tagsCancel = cancelTags
+	ticker, done := tagsTicker, tagsTickerDone
+
+	go func() {
+		if prefs.Title.UpdateImmediatelyOnStart {
+			runTagsCycle(tagsCtx)
+		}
+		for {
+			select {
+			case <-done:
+				config.Logger.LogInfo("tagsTicker finished")
+				return
+			case <-ticker.C:
+				runTagsCycle(tagsCtx)
+			}
+		}
+	}()
+	return nil
+}
+
+func stopTagsUpdater() {
+	if tagsCancel != nil {
+		tagsCancel()
+		tagsCancel = nil
+	}
+	if tagsTicker != nil {
+		config.Logger.LogInfo("tagsTicker stopped")
+		tagsTicker.Stop()
+		tagsTicker = nil
+	}
+	if tagsTickerDone != nil {
+		close(tagsTickerDone)
+		tagsTickerDone = nil
+	}
+}
+
+// Tags are not essential, so a failed update keeps the current tags rather than stopping Tidal
+func runTagsCycle(tagsCtx context.Context) {
+	ctx, cancel := context.WithTimeout(tagsCtx, singleCycleTimeout)
+	defer cancel()
+	if err := updateTags(ctx); err != nil {
+		if tagsCtx.Err() != nil {
+			// cycles cut short by stopping the updater are not errors
+			return
+		}
+		config.Logger.LogErrorf("unable to update tags - err: %v", err)
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf("Tags were not updated - %v", err),
+		); err != nil {
+			config.Logger.LogErrorf("unable to push tags failure to console - err: %v", err)
+		}
+	}
+}
+
+// Generates tags from the tags variable, merges them with the always-on tags, and applies them.
+// Like updateTitle, works from a snapshot of the preferences and only writes back the generated value.
+func updateTags(ctx context.Context) error {
+	prefs := config.SnapshotPreferences()
+	v, err := getTagsVariable(prefs)
+	if err != nil {
+		return err
+	}
+	placeholderStr := helpers.GenerateVarPlaceholderString(v.Name)
+
+	prompt, err := renderTagsPrompt(v, prefs)
+	if err != nil {
+		return err
+	}
+	providerChain, err := llm.GetProviderChain(prefs.LlmConfig, v)
+	if err != nil {
+		return fmt.Errorf("unable to get provider chain for %v - err: %w", placeholderStr, err)
+	}
+
+	generated := true
+	result, err := llm.GenerateVariableValue(ctx, llm.GenerationRequestT{
+		Variable:   v,
+		Profiles:   providerChain,
+		Prompt:     prompt,
+		Images:     (&promptImagesT{}).getImages(ctx, v),
+		Timeout:    llmResponseTimeout,
+		Moderation: prefs.Moderation,
+	})
+	if err != nil {
+		outcome, policyErr := llm.ApplyFailurePolicy(v, err)
+		if policyErr != nil || outcome.DropSection {
+			return fmt.Errorf("unable to generate %v - err: %w", placeholderStr, err)
+		}
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf("%s failed to generate - %s", placeholderStr, outcome.Description),
+		); err != nil {
+			config.Logger.LogErrorf("unable to push failure info to console - err: %v", err)
+		}
+		result = llm.GenerationResultT{Value: outcome.Value}
+		generated = false
+	}
+
+	tags := mergeTags(prefs.Tags.AlwaysOnTags, llm.ParseTags(result.Value))
+	if len(tags) == 0 {
+		return errors.New("there were no valid tags to apply")
+	}
+	if err := twitch.UpdateChannel(ctx, prefs, twitch.ChannelUpdateT{Tags: tags}); err != nil {
+		return fmt.Errorf("unable to update tags - err: %w", err)
+	}
+
+	source := ""
+	if result.ProviderName != "" {
+		source = fmt.Sprintf(" (generated by %q)", result.ProviderName)
+	}
+	if err := ActivityConsole.pushToConsole(
+		config.Logger.LogToBufferf("Updated tags to %s%s", strings.Join(tags, ", "), source),
+	); err != nil {
+		return fmt.Errorf("unable to push tags update to console - err: %w", err)
+	}
+
+	if generated {
+		if err := config.UpdatePreferences(func(newPrefs *config.PreferencesFormat) {
+			setGeneratedValues(newPrefs.AiGeneratedVariables, map[string]string{placeholderStr: result.Value})
+		}); err != nil {
+			config.Logger.LogErrorf("unable to save the generated tags - err: %v", err)
+		}
+	}
+	return nil
+}
+
+func getTagsVariable(prefs config.PreferencesFormat) (config.LlmVariableT, error) {
+	for _, v := range prefs.AiGeneratedVariables {
+		if v.Name == prefs.Tags.VariableName {
+			if !v.IsTags() {
+				return config.LlmVariableT{}, fmt.Errorf("AI-generated variable %q is not in %q mode", v.Name, config.VariableModeTags)
+			}
+			return v, nil
+		}
+	}
+	return config.LlmVariableT{}, fmt.Errorf("AI-generated variable %q, used for tags, does not exist", prefs.Tags.VariableName)
+}
+
+// Substitutes variables into the tags variable's prompt, then adds the stream's category, title and knowledge base.
+// AI-generated variables the prompt references use their most recent values.
+func renderTagsPrompt(v config.LlmVariableT, prefs config.PreferencesFormat) (string, error) {
+	prompt := v.PromptMain
+	if v.PromptSuffix != "" {
+		prompt += "\n" + v.PromptSuffix
+	}
+
+	twitchVariableStringReplacer, err := getTwitchVariablesStringReplacer(prefs.TwitchVariables)
+	if err != nil {
+		return "", fmt.Errorf("unable to get twitch variables string replacer - err: %w", err)
+	}
+	prompt = twitchVariableStringReplacer.Replace(prompt)
+
+	dependencies := []config.LlmVariableT{}
+	savedValuesMap := map[string]string{}
+	for _, dependencyName := range llm.GetVariableDependencies(v, prefs.AiGeneratedVariables) {
+		for _, dependency := range prefs.AiGeneratedVariables {
+			if dependency.Name != dependencyName {
+				continue
+			}
+			dependencies = append(dependencies, dependency)
+			if placeholderValues, err := llm.GetPlaceholderValues(dependency, dependency.Value); err == nil {
+				maps.Copy(savedValuesMap, placeholderValues)
+			}
+		}
+	}
+	aiGeneratedVariablesStringReplacer, err := getAiGeneratedVariablesStringReplacer(dependencies, savedValuesMap)
+	if err != nil {
+		return "", err
+	}
+	prompt = aiGeneratedVariablesStringReplacer.Replace(prompt)
+
+	streamContext := []string{}
+	if category := prefs.TwitchVariables.StreamCategory.Value; category != "" {
+		streamContext = append(streamContext, fmt.Sprintf("The stream's category is %q.", category))
+	}
+	if prefs.Title.Value != "" {
+		streamContext = append(streamContext, fmt.Sprintf("The stream's title is %q.", prefs.Title.Value))
+	}
+	if len(streamContext) > 0 {
+		prompt = fmt.Sprintf("%s\n\n%s", prompt, strings.Join(streamContext, " "))
+	}
+	return addKnowledgeToPrompt(prompt, v), nil
+}
+
+// Always-on tags come first, so they are kept if there are more than twitch allows
+func mergeTags(alwaysOnTags []string, generatedTags []string) []string {
+	return helpers.NormaliseTags(append(append([]string{}, alwaysOnTags...), generatedTags...))
+}
@@ -0,0 +1,222 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/llm"
+	"github.com/finahdinner/tidal/moderation"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+const (
+	maxTitleCandidates       = 5
+	maxTitleHistoryEntries   = 50
+	numRecentTitlesToCompare = 10
+	targetTitleLength        = 60 // longer titles are more likely to be cut off in twitch's directory
+)
+
+// A rendered title, along with the AI-generated values used to render it
+type titleCandidateT struct {
+	title                   string
+	aiGeneratedResponsesMap map[string]string // saved to preferences if this candidate is published
+	variableValues          map[string]string // every resolved AI-generated placeholder, used to render the channel profile
+	generatedResponses      []generatedResponseT
+	scores                  map[string]float64
+}
+
+// A newly generated value, cached only once its candidate is chosen
+type generatedResponseT struct {
+	variableName string
+	cacheKey     string
+	category     string
+	value        string
+	providerName string
+	generatedAt  time.Time
+}
+
+// Caches the values generated for the candidate, so later cycles reuse the values which were actually chosen
+func (c titleCandidateT) cacheResponses() {
+	for _, r := range c.generatedResponses {
+		llmResponseCache.Store(r.variableName, r.cacheKey, r.category, r.value, r.providerName, r.generatedAt)
+	}
+}
+
+// Renders candidates in parallel, skipping any which fail as long as at least one succeeds.
+// AI-generated variables used in the other templates are generated alongside the title's.
+// Only the first candidate reuses cached values, otherwise every candidate would be rendered from the same values.
+func renderTitleCandidates(
+	ctx context.Context,
+	prefs config.PreferencesFormat,
+	titleTemplate string,
+	otherTemplates []string,
+	numCandidates int,
+) ([]titleCandidateT, error) {
+	if numCandidates == 1 {
+		candidate, err := renderTitleCandidate(ctx, prefs, titleTemplate, otherTemplates, true)
+		if err != nil {
+			return nil, err
+		}
+		return []titleCandidateT{candidate}, nil
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	candidates := []titleCandidateT{}
+	var errs []error
+
+	for idx := range min(numCandidates, maxTitleCandidates) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			candidate, err := renderTitleCandidate(ctx, prefs, titleTemplate, otherTemplates, idx == 0)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				config.Logger.LogErrorf("unable to render title candidate - err: %v", err)
+				errs = append(errs, err)
+				return
+			}
+			candidates = append(candidates, candidate)
+		}()
+	}
+	wg.Wait()
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("all title candidates failed - err: %w", errors.Join(errs...))
+	}
+	return candidates, nil
+}
+
+// Scores each candidate with the enabled scorers, logs them to the console, and returns the index of the best one
+func selectTitleCandidate(ctx context.Context, candidates []titleCandidateT, prefs config.PreferencesFormat) int {
+	candidatesConfig := prefs.Title.Candidates
+
+	for idx := range candidates {
+		candidates[idx].scores = map[string]float64{}
+	}
+	for _, scorer := range candidatesConfig.Scorers {
+		switch scorer {
+		case config.TitleScorerLength:
+			for idx, c := range candidates {
+				candidates[idx].scores[scorer] = scoreTitleLength(c.title)
+			}
+		case config.TitleScorerModeration:
+			for idx, c := range candidates {
+				candidates[idx].scores[scorer] = boolToScore(len(moderation.Check(c.title, prefs.Moderation).Matches) == 0)
+			}
+		case config.TitleScorerNovelty:
+			recentHistory := prefs.TitleHistory[max(len(prefs.TitleHistory)-numRecentTitlesToCompare, 0):]
+			for idx, c := range candidates {
+				maxSimilarity := 0.0
+				for _, entry := range recentHistory {
+					maxSimilarity = max(maxSimilarity, llm.Similarity(config.SimilarityMethodNgramOverlap, c.title, entry.Title))
+				}
+				candidates[idx].scores[scorer] = 1 - maxSimilarity
+			}
+		case config.TitleScorerLlmJudge:
+			judgeScores, err := judgeTitleCandidates(ctx, candidates, prefs)
+			if err != nil {
+				// the other scorers can still choose a candidate
+				config.Logger.LogErrorf("unable to judge title candidates - err: %v", err)
+				if err := ActivityConsole.pushToConsole(
+					config.Logger.LogToBufferf("LLM judge failed - %v", err),
+				); err != nil {
+					config.Logger.LogErrorf("unable to push judge failure to console - err: %v", err)
+				}
+				continue
+			}
+			for idx, score := range judgeScores {
+				candidates[idx].scores[scorer] = score
+			}
+		}
+	}
+
+	bestIdx := 0
+	for idx, c := range candidates {
+		if c.totalScore() > candidates[bestIdx].totalScore() {
+			bestIdx = idx
+		}
+	}
+
+	for idx, c := range candidates {
+		scoreDetails := []string{}
+		for _, scorer := range candidatesConfig.Scorers {
+			if score, exists := c.scores[scorer]; exists {
+				scoreDetails = append(scoreDetails, fmt.Sprintf("%s %.2f", scorer, score))
+			}
+		}
+		chosenMarker := ""
+		if idx == bestIdx {
+			chosenMarker = " (chosen)"
+		}
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf(
+				"Candidate %v: %q - score %.2f [%s]%s",
+				idx+1, c.title, c.totalScore(), strings.Join(scoreDetails, ", "), chosenMarker,
+			),
+		); err != nil {
+			config.Logger.LogErrorf("unable to push title candidate to console - err: %v", err)
+		}
+	}
+	return bestIdx
+}
+
+func judgeTitleCandidates(ctx context.Context, candidates []titleCandidateT, prefs config.PreferencesFormat) ([]float64, error) {
+	profiles, err := llm.GetProviderChain(
+		prefs.LlmConfig, config.LlmVariableT{ProviderChain: prefs.Title.Candidates.JudgeProviderChain},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get judge provider chain - err: %w", err)
+	}
+	titles := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		titles = append(titles, c.title)
+	}
+	judgePrompt := prefs.Title.Candidates.JudgePrompt
+	if judgePrompt == "" {
+		judgePrompt = config.DefaultTitleJudgePrompt
+	}
+	return llm.JudgeCandidates(ctx, profiles, judgePrompt, titles, llmResponseTimeout)
+}
+
+func (c titleCandidateT) totalScore() float64 {
+	total := 0.0
+	for _, score := range c.scores {
+		total += score
+	}
+	return total
+}
+
+// Titles up to targetTitleLength score 1, falling to 0 at twitch's limit.
+// Titles over the limit are usually rejected before they are scored, so this favours titles which are shown in full.
+func scoreTitleLength(title string) float64 {
+	if len(title) <= targetTitleLength {
+		return 1
+	}
+	return max(1-float64(len(title)-targetTitleLength)/float64(twitch.MaxTitleLength-targetTitleLength), 0)
+}
+
+func boolToScore(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Adds a published title to the history, dropping the oldest entries beyond the limit
+func appendToTitleHistory(history []config.TitleHistoryEntryT, title string) []config.TitleHistoryEntryT {
+	history = append(append([]config.TitleHistoryEntryT{}, history...), config.TitleHistoryEntryT{
+		Title:                title,
+		UpdatedUnixTimestamp: time.Now().Unix(),
+	})
+	if len(history) > maxTitleHistoryEntries {
+		history = history[len(history)-maxTitleHistoryEntries:]
+	}
+	return history
+}
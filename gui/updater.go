@@ -1,297 +1,719 @@
-package gui
-
-import (
-	"context"
-	"errors"
-	"fmt"
-	"strings"
-	"sync"
-	"time"
-
-	"github.com/finahdinner/tidal/config"
-	"github.com/finahdinner/tidal/helpers"
-	"github.com/finahdinner/tidal/llm"
-	"github.com/finahdinner/tidal/twitch"
-)
-
-const (
-	llmResponseTimeout = 5 * time.Second
-	singleCycleTimeout = 10 * time.Second
-
-	emptyVariablePlaceholder = "<<<N/A>>>"
-)
-
-var (
-	updaterTicker     *time.Ticker
-	updaterTickerDone chan struct{}
-
-	updateVariablesSectionSignal = make(chan struct{}, 1)
-)
-
-// Begins a ticker to update the twitch title
-func startUpdater() error {
-
-	if updaterTicker != nil {
-		return errors.New("ticker already running - stop it first")
-	}
-
-	updateIntervalMinutes := config.Preferences.Title.TitleUpdateIntervalMinutes
-	if updateIntervalMinutes < helpers.MinTitleUpdateIntervalMinutes ||
-		updateIntervalMinutes > helpers.MaxTitleUpdateIntervalMinutes {
-		return fmt.Errorf(
-			"update interval (%v minutes) is not in the valid range between %v and %v",
-			updateIntervalMinutes, helpers.MinTitleUpdateIntervalMinutes, helpers.MaxTitleUpdateIntervalMinutes,
-		)
-	}
-	updateIntervalSeconds := updateIntervalMinutes * 60
-
-	updaterTicker = time.NewTicker(time.Duration(updateIntervalSeconds) * time.Second)
-	updaterTickerDone = make(chan struct{})
-
-	errChan := make(chan error, 1)
-	doneChan := make(chan struct{})
-
-	go func() {
-		defer close(doneChan)
-		if config.Preferences.Title.UpdateImmediatelyOnStart {
-			ctx, cancel := context.WithTimeout(context.Background(), singleCycleTimeout)
-			if err := updateCycle(ctx, cancel); err != nil {
-				errChan <- fmt.Errorf("unable to complete update cycle - err: %w", err)
-			}
-		}
-
-		for {
-			select {
-			case <-updaterTickerDone:
-				config.Logger.LogInfo("updateTicker finished")
-				return
-			case <-updaterTicker.C:
-				ctx, cancel := context.WithTimeout(context.Background(), singleCycleTimeout)
-				if err := updateCycle(ctx, cancel); err != nil {
-					errChan <- fmt.Errorf("unable to complete update cycle - err: %w", err)
-					continue
-				}
-
-				select {
-				case updateVariablesSectionSignal <- struct{}{}:
-					// signal to update widgets in variables sections
-				default:
-					// reached if updateVariablesSectionSignal is full
-					config.Logger.LogDebug("updateVariablesSectionSignal chan is full - skipping")
-				}
-			}
-		}
-	}()
-
-	// reached when the ticker stops
-	select {
-	case err := <-errChan:
-		stopUpdater()
-		return fmt.Errorf("ticker stopped due to error - err: %w", err)
-	case <-doneChan:
-		return nil
-	}
-}
-
-// One single update cycle - updates Twitch variables then updates the title
-func updateCycle(ctx context.Context, cancel context.CancelFunc) error {
-	defer cancel()
-	if err := twitch.UpdateTwitchVariables(ctx); err != nil {
-		if errors.Is(err, twitch.Err401Unauthorised) {
-			return fmt.Errorf("unable to update twitch variables - err: %w", err)
-		}
-	}
-	if err := updateTitle(ctx); err != nil {
-		return fmt.Errorf("unable to update title - err: %w", err)
-	}
-	return nil
-}
-
-// Assumes Twitch variables have been updated already
-func updateTitle(ctx context.Context) error {
-
-	titleTemplate := config.Preferences.Title.TitleTemplate
-
-	twitchVariableStringReplacer, err := getTwitchVariablesStringReplacer(config.Preferences.TwitchVariables)
-	if err != nil {
-		return fmt.Errorf("unable to get twitch variables string replacer - err: %v", err)
-	}
-
-	aiGeneratedVariableUsedMap := map[string]config.LlmVariableT{}
-	for _, v := range config.Preferences.AiGeneratedVariables {
-		placeholderName := helpers.GenerateVarPlaceholderString(v.Name)
-		if strings.Contains(titleTemplate, placeholderName) {
-			aiGeneratedVariableUsedMap[placeholderName] = v
-		}
-	}
-
-	aiGeneratedResponsesMap := map[string]string{}
-
-	if len(aiGeneratedVariableUsedMap) > 0 {
-
-		promptsMap := map[string]string{}
-		for placeholderStr, v := range aiGeneratedVariableUsedMap {
-			prompt := v.PromptMain
-			if v.PromptSuffix != "" {
-				prompt += "\n" + v.PromptSuffix
-			}
-			prompt = twitchVariableStringReplacer.Replace(prompt)
-			if config.Preferences.Title.ThrowErrorIfEmptyVariable && strings.Contains(prompt, emptyVariablePlaceholder) {
-				return fmt.Errorf("prompt for aiGeneratedVariable %v has an empty value", placeholderStr)
-			}
-			promptsMap[placeholderStr] = prompt
-		}
-
-		llmProvider := config.Preferences.LlmConfig.Provider
-		apiKey := config.Preferences.LlmConfig.ApiKey
-
-		llmHandler, err := llm.NewLlmHandler(llmProvider, apiKey)
-		if err != nil {
-			return fmt.Errorf("unable to create new llm handler - err: %w", err)
-		}
-
-		var wg sync.WaitGroup
-		var responsesMapMutex sync.Mutex
-		doneChan := make(chan struct{})
-		errChan := make(chan error, 1)
-
-		for placeholderStr, prompt := range promptsMap {
-			wg.Add(1)
-			go func(placeholderStr, prompt string) {
-				defer wg.Done()
-				config.Logger.LogDebugf("sending prompt: %q", prompt)
-				response, err := llmHandler.GetResponseText(prompt, llmResponseTimeout)
-				if err != nil {
-					errChan <- fmt.Errorf("unable to get response text for %v - err: %w", prompt, err)
-					return
-				}
-				responsesMapMutex.Lock()
-				aiGeneratedResponsesMap[placeholderStr] = response
-				responsesMapMutex.Unlock()
-			}(placeholderStr, prompt)
-		}
-
-		go func() {
-			wg.Wait()
-			close(doneChan)
-		}()
-
-		select {
-		case err := <-errChan:
-			return fmt.Errorf("unable to retrieve all LLM responses - err: %w", err)
-		case <-doneChan:
-			//
-		}
-	}
-
-	newPreferences := config.Preferences
-
-	// update preferences with llm variable values AND the new title
-	for placeholderStr, response := range aiGeneratedResponsesMap {
-		for idx, v := range newPreferences.AiGeneratedVariables {
-			if v.Name == helpers.GetVarNameFromPlaceholderString(placeholderStr) {
-				newPreferences.AiGeneratedVariables[idx].Value = response
-			}
-		}
-	}
-
-	// used to replace ALL mentioned variables with their respective value
-	fullVariableReplacementMap := aiGeneratedResponsesMap
-
-	allTwitchVariablesMap := helpers.GenerateMapFromHomogenousStruct[
-		config.TwitchVariablesT, config.TwitchVariableT,
-	](config.Preferences.TwitchVariables)
-
-	twitchVariablesUsedInTitleMap := map[string]config.TwitchVariableT{}
-	for varName, twitchVar := range allTwitchVariablesMap {
-		varNamePlaceholder := helpers.GenerateVarPlaceholderString(varName)
-		if strings.Contains(titleTemplate, varNamePlaceholder) {
-			twitchVariablesUsedInTitleMap[varName] = twitchVar
-		}
-	}
-	config.Logger.LogDebugf("twitchVariablesUsedInTitleMap: %v", twitchVariablesUsedInTitleMap)
-
-	for varName, twitchVar := range twitchVariablesUsedInTitleMap {
-		replaceFrom := helpers.GenerateVarPlaceholderString(varName)
-		if _, exists := fullVariableReplacementMap[replaceFrom]; exists {
-			return fmt.Errorf("conflicting variable name: %q", replaceFrom)
-		}
-		fullVariableReplacementMap[replaceFrom] = twitchVar.Value
-	}
-	config.Logger.LogDebugf("fullVariableReplacementMap: %v", fullVariableReplacementMap)
-
-	allVariablesReplacer, err := helpers.GetStringReplacerFromMap(
-		fullVariableReplacementMap, !config.Preferences.Title.ThrowErrorIfEmptyVariable, false,
-	)
-	if err != nil {
-		return fmt.Errorf("unable to construct allVariablesReplacer - err: %w", err)
-	}
-
-	newTitle := strings.TrimSpace(allVariablesReplacer.Replace(titleTemplate))
-
-	// check there are no "placeholder" values (non-existent variables) left
-	matchingVariables := helpers.ExtractVariableNamesFromText(newTitle)
-	if config.Preferences.Title.ThrowErrorIfNonExistentVariable && len(matchingVariables) > 0 {
-		return fmt.Errorf("non-existent variable in resulting twitch title - err: %w", err)
-	}
-
-	if config.Preferences.Title.ThrowErrorIfTooLong && len(newTitle) > twitch.MaxTitleLength {
-		return fmt.Errorf("title is too long (%v chars) - err: %w", len(newTitle), err)
-	}
-
-	newPreferences.Title.Value = newTitle
-
-	config.Logger.LogDebugf("attempting to update stream title to %q", newPreferences.Title.Value)
-	if err := twitch.UpdateStreamTitle(ctx, newPreferences); err != nil {
-		return fmt.Errorf("unable to update stream title - err: %w", err)
-	}
-
-	if err := ActivityConsole.pushToConsole(
-		config.Logger.LogToBufferf("Updated title to %q", newTitle),
-	); err != nil {
-		return fmt.Errorf("unable to push title update to console - err: %w", err)
-	}
-
-	if config.Preferences.Title.SendChatMessagePerTitleUpdate {
-		msg := fmt.Sprintf("✅ New stream title: %q", newTitle)
-		if err := twitch.SendChatMessage(ctx, newPreferences, msg); err != nil {
-			config.Logger.LogErrorf("unable to send message about updating the stream title - err: %s", err)
-		}
-	}
-
-	config.Logger.LogInfof("successfully updated title to %q", newPreferences.Title.Value)
-	config.Preferences = newPreferences
-	config.SavePreferences()
-
-	return nil
-}
-
-func stopUpdater() {
-	if updaterTicker != nil {
-		config.Logger.LogInfo("updaterTicker stopped")
-		updaterTicker.Stop()
-		updaterTicker = nil
-	}
-	if updaterTickerDone != nil {
-		config.Logger.LogInfo("updaterTickerDone closed")
-		close(updaterTickerDone)
-		updaterTickerDone = nil
-	}
-}
-
-func getTwitchVariablesStringReplacer(twitchVariables config.TwitchVariablesT) (*strings.Replacer, error) {
-	twitchVariablesMap := helpers.GenerateMapFromHomogenousStruct[config.TwitchVariablesT, config.TwitchVariableT](twitchVariables)
-	twitchVariablesValuesMap := map[string]string{}
-	for varName, v := range twitchVariablesMap {
-		val := v.Value
-		if val == "" {
-			val = emptyVariablePlaceholder
-		}
-		twitchVariablesValuesMap[helpers.GenerateVarPlaceholderString(varName)] = val
-	}
-	twitchVariablesStringReplacer, err := helpers.GetStringReplacerFromMap(twitchVariablesValuesMap, true, false)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create string replacer map for twitch variables - err: %w", err)
-	}
-	return twitchVariablesStringReplacer, nil
-}
+package gui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finahdinner/tidal/approval"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/llm"
+	"github.com/finahdinner/tidal/moderation"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+const (
+	llmResponseTimeout = 5 * time.Second
+	singleCycleTimeout = 30 * time.Second // allows for falling back through several llm providers
+
+	maxModerationRegenerations = 2
+
+	emptyVariablePlaceholder = "<<<N/A>>>"
+)
+
+var (
+	updaterTicker     *time.Ticker
+	updaterTickerDone chan struct{}
+	updaterCancel     context.CancelFunc // cancels the running cycle, including any title awaiting approval
+
+	updateVariablesSectionSignal = make(chan struct{}, 1)
+
+	llmResponseCache = llm.NewResponseCache()
+
+	errTitleRejectedByModeration = errors.New("title rejected by moderation")
+	errTitleToRegenerate         = errors.New("title needs regenerating after moderation")
+)
+
+// Begins a ticker to update the twitch title
+func startUpdater() error {
+
+	if updaterTicker != nil {
+		return errors.New("ticker already running - stop it first")
+	}
+
+	updateIntervalMinutes := config.Preferences.Title.TitleUpdateIntervalMinutes
+	if updateIntervalMinutes < helpers.MinTitleUpdateIntervalMinutes ||
+		updateIntervalMinutes > helpers.MaxTitleUpdateIntervalMinutes {
+		return fmt.Errorf(
+			"update interval (%v minutes) is not in the valid range between %v and %v",
+			updateIntervalMinutes, helpers.MinTitleUpdateIntervalMinutes, helpers.MaxTitleUpdateIntervalMinutes,
+		)
+	}
+	updateIntervalSeconds := updateIntervalMinutes * 60
+
+	if err := startControlServer(); err != nil {
+		return err
+	}
+	// tags are not essential, so the title is still updated if they cannot be
+	if err := startTagsUpdater(); err != nil {
+		config.Logger.LogErrorf("unable to start tags updater - err: %v", err)
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf("Tags will not be updated - %v", err),
+		); err != nil {
+			config.Logger.LogErrorf("unable to push tags updater failure to console - err: %v", err)
+		}
+	}
+	cycleTimeout := getCycleTimeout()
+	llm.Usage.ResetSession()
+
+	for _, missing := range twitch.GetMissingScopes(config.Preferences) {
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf("%s may fail - re-authenticate in Twitch Configuration to grant %s", missing.Feature, strings.Join(missing.Scopes, ", ")),
+		); err != nil {
+			config.Logger.LogErrorf("unable to push missing scopes to console - err: %v", err)
+		}
+	}
+
+	updaterTicker = time.NewTicker(time.Duration(updateIntervalSeconds) * time.Second)
+	updaterTickerDone = make(chan struct{})
+	updaterCtx, cancelUpdater := context.WithCancel(context.Background())
+	updaterCancel = cancelUpdater
+	ticker, done := updaterTicker, updaterTickerDone
+
+	errChan := make(chan error, 1)
+	doneChan := make(chan struct{})
+
+	go func() {
+		defer close(doneChan)
+		if config.Preferences.Title.UpdateImmediatelyOnStart {
+			ctx, cancel := context.WithTimeout(updaterCtx, cycleTimeout)
+			if err := updateCycle(ctx, cancel); err != nil && updaterCtx.Err() == nil {
+				errChan <- fmt.Errorf("unable to complete update cycle - err: %w", err)
+			}
+		}
+
+		for {
+			select {
+			case <-done:
+				config.Logger.LogInfo("updateTicker finished")
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(updaterCtx, cycleTimeout)
+				if err := updateCycle(ctx, cancel); err != nil {
+					if updaterCtx.Err() == nil {
+						// cycles cut short by stopping the updater are not errors
+						errChan <- fmt.Errorf("unable to complete update cycle - err: %w", err)
+					}
+					continue
+				}
+
+				select {
+				case updateVariablesSectionSignal <- struct{}{}:
+					// signal to update widgets in variables sections
+				default:
+					// reached if updateVariablesSectionSignal is full
+					config.Logger.LogDebug("updateVariablesSectionSignal chan is full - skipping")
+				}
+			}
+		}
+	}()
+
+	// reached when the ticker stops
+	select {
+	case err := <-errChan:
+		stopUpdater()
+		return fmt.Errorf("ticker stopped due to error - err: %w", err)
+	case <-doneChan:
+		return nil
+	}
+}
+
+// One single update cycle - updates Twitch variables then updates the title
+func updateCycle(ctx context.Context, cancel context.CancelFunc) error {
+	defer cancel()
+	if err := twitch.UpdateTwitchVariables(ctx); err != nil {
+		if errors.Is(err, twitch.Err401Unauthorised) {
+			return fmt.Errorf("unable to update twitch variables - err: %w", err)
+		}
+	}
+	if err := updateTitle(ctx); err != nil {
+		return fmt.Errorf("unable to update title - err: %w", err)
+	}
+	return nil
+}
+
+// Assumes Twitch variables have been updated already.
+// Works from a snapshot of the preferences, and only writes back the title and generated values,
+// so credentials refreshed during the cycle are never overwritten.
+func updateTitle(ctx context.Context) error {
+
+	prefs := config.SnapshotPreferences()
+	titleTemplate := prefs.Title.TitleTemplate
+	channelProfile, hasChannelProfile := prefs.ActiveChannelProfile(time.Now())
+	profileTemplates := []string{}
+	if hasChannelProfile {
+		if channelProfile.TitleTemplate != "" {
+			titleTemplate = channelProfile.TitleTemplate
+		}
+		profileTemplates = append([]string{channelProfile.GameName}, channelProfile.Tags...)
+	}
+
+	var chosenCandidate titleCandidateT
+	var newTitle string
+	for moderationRegenerations := 0; ; moderationRegenerations++ {
+		for regenerations := 0; ; regenerations++ {
+			candidates, err := renderTitleCandidates(ctx, prefs, titleTemplate, profileTemplates, max(prefs.Title.Candidates.NumCandidates, 1))
+			if err != nil {
+				return fmt.Errorf("unable to render title - err: %w", err)
+			}
+			chosenIdx := 0
+			if len(candidates) > 1 {
+				chosenIdx = selectTitleCandidate(ctx, candidates, prefs)
+			}
+			chosenCandidate = candidates[chosenIdx]
+
+			if !prefs.Title.Approval.Enabled {
+				break
+			}
+			decision, err := requestTitleApproval(ctx, candidates, chosenIdx)
+			if err != nil {
+				return fmt.Errorf("unable to get title approval - err: %w", err)
+			}
+			if decision.Action == config.ApprovalActionRegenerate && regenerations < maxApprovalRegenerations {
+				invalidateCachedResponses(prefs.AiGeneratedVariables)
+				continue
+			}
+			if decision.Action != config.ApprovalActionApprove && decision.Action != config.ApprovalActionEdit {
+				// keep the current title - the next cycle will generate a new one
+				reason := "it was skipped"
+				if decision.Action == config.ApprovalActionRegenerate {
+					reason = fmt.Sprintf("it was regenerated more than %v times", maxApprovalRegenerations)
+				}
+				if err := ActivityConsole.pushToConsole(config.Logger.LogToBufferf("Title was not published - %s", reason)); err != nil {
+					return fmt.Errorf("unable to push approval result to console - err: %w", err)
+				}
+				return nil
+			}
+			chosenCandidate = candidates[decision.Candidate]
+			if decision.Action == config.ApprovalActionEdit {
+				chosenCandidate.title = strings.TrimSpace(decision.Title)
+				if len(chosenCandidate.title) > twitch.MaxTitleLength {
+					// keep the current title - the next cycle will generate a new one
+					if err := ActivityConsole.pushToConsole(config.Logger.LogToBufferf(
+						"Edited title %q was not published - it is longer than %v characters", chosenCandidate.title, twitch.MaxTitleLength,
+					)); err != nil {
+						return fmt.Errorf("unable to push approval result to console - err: %w", err)
+					}
+					return nil
+				}
+			}
+			break
+		}
+		chosenCandidate.cacheResponses()
+		newTitle = chosenCandidate.title
+		if !prefs.Moderation.Enabled {
+			break
+		}
+
+		moderatedTitle, err := moderateTitle(ctx, newTitle, prefs)
+		if errors.Is(err, errTitleToRegenerate) && moderationRegenerations < maxModerationRegenerations {
+			if err := ActivityConsole.pushToConsole(
+				config.Logger.LogToBufferf("Title %q was not published - %v", newTitle, err),
+			); err != nil {
+				return fmt.Errorf("unable to push moderation result to console - err: %w", err)
+			}
+			// the blocked terms may have come from any cached value, so none are reused
+			invalidateCachedResponses(prefs.AiGeneratedVariables)
+			continue
+		}
+		if errors.Is(err, errTitleRejectedByModeration) || errors.Is(err, errTitleToRegenerate) {
+			// keep the current title - the next cycle will generate a new one
+			if err := ActivityConsole.pushToConsole(
+				config.Logger.LogToBufferf("Title %q was not published - %v", newTitle, err),
+			); err != nil {
+				return fmt.Errorf("unable to push moderation result to console - err: %w", err)
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to moderate title - err: %w", err)
+		}
+		newTitle = moderatedTitle
+		break
+	}
+
+	channelUpdate := twitch.ChannelUpdateT{Title: newTitle}
+	if hasChannelProfile {
+		addChannelProfileToUpdate(ctx, channelProfile, prefs, chosenCandidate.variableValues, &channelUpdate)
+	}
+
+	config.Logger.LogDebugf("attempting to update stream title to %q", newTitle)
+	if err := twitch.UpdateChannel(ctx, prefs, channelUpdate); err != nil {
+		if errors.Is(err, twitch.ErrRateLimited) || errors.Is(err, twitch.ErrServerError) {
+			// twitch is struggling rather than rejecting the title, so try again next cycle
+			config.Logger.LogErrorf("unable to update stream title - err: %v", err)
+			return ActivityConsole.pushToConsole(
+				config.Logger.LogToBufferf("Twitch is unavailable, so the title was not updated - it will be retried next update"),
+			)
+		}
+		return fmt.Errorf("unable to update stream title - err: %w", err)
+	}
+
+	if err := ActivityConsole.pushToConsole(
+		config.Logger.LogToBufferf("Updated title to %q", newTitle),
+	); err != nil {
+		return fmt.Errorf("unable to push title update to console - err: %w", err)
+	}
+	if hasChannelProfile {
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf("Applied channel profile %q", channelProfile.Name),
+		); err != nil {
+			return fmt.Errorf("unable to push channel profile to console - err: %w", err)
+		}
+	}
+
+	var marker *twitch.StreamMarkerT
+	if prefs.Title.CreateMarkerPerTitleUpdate {
+		marker = addStreamMarker(ctx, prefs, newTitle)
+	}
+
+	if prefs.Title.SendChatMessagePerTitleUpdate {
+		msg := fmt.Sprintf("✅ New stream title: %q", newTitle)
+		if err := twitch.SendChatMessage(ctx, prefs, msg); err != nil {
+			config.Logger.LogErrorf("unable to send message about updating the stream title - err: %s", err)
+		}
+	}
+
+	config.Logger.LogInfof("successfully updated title to %q", newTitle)
+	if err := config.UpdatePreferences(func(newPrefs *config.PreferencesFormat) {
+		newPrefs.Title.Value = newTitle
+		newPrefs.TitleHistory = appendToTitleHistory(newPrefs.TitleHistory, newTitle)
+		if marker != nil {
+			latestEntry := &newPrefs.TitleHistory[len(newPrefs.TitleHistory)-1]
+			latestEntry.MarkerId = marker.Id
+			latestEntry.MarkerPositionSeconds = marker.PositionSeconds
+		}
+		setGeneratedValues(newPrefs.AiGeneratedVariables, chosenCandidate.aiGeneratedResponsesMap)
+	}); err != nil {
+		config.Logger.LogErrorf("unable to save the new title - err: %v", err)
+	}
+
+	return nil
+}
+
+// Regenerated titles would otherwise reuse the cached values they were rendered from
+func invalidateCachedResponses(variables []config.LlmVariableT) {
+	for _, v := range variables {
+		llmResponseCache.Invalidate(v.Name)
+	}
+}
+
+// Saves generated responses, keyed by placeholder string, as their variables' values
+func setGeneratedValues(variables []config.LlmVariableT, responsesMap map[string]string) {
+	for placeholderStr, response := range responsesMap {
+		for idx, v := range variables {
+			if v.Name == helpers.GetVarNameFromPlaceholderString(placeholderStr) {
+				variables[idx].Value = response
+				variables[idx].RecentValues = llm.AppendToHistory(v, response)
+			}
+		}
+	}
+}
+
+// Generates the AI-generated variables used in the title template and other templates, then renders the title template
+func renderTitleCandidate(
+	ctx context.Context,
+	prefs config.PreferencesFormat,
+	titleTemplate string,
+	otherTemplates []string,
+	useCache bool,
+) (titleCandidateT, error) {
+
+	allTemplates := strings.Join(append([]string{titleTemplate}, otherTemplates...), "\n")
+
+	twitchVariableStringReplacer, err := getTwitchVariablesStringReplacer(prefs.TwitchVariables)
+	if err != nil {
+		return titleCandidateT{}, fmt.Errorf("unable to get twitch variables string replacer - err: %v", err)
+	}
+
+	aiGeneratedVariablesMap := map[string]config.LlmVariableT{} // keyed by placeholder string
+	aiGeneratedVariablesInTemplates := []string{}
+	for _, v := range prefs.AiGeneratedVariables {
+		placeholderName := helpers.GenerateVarPlaceholderString(v.Name)
+		aiGeneratedVariablesMap[placeholderName] = v
+		if strings.Contains(allTemplates, placeholderName) {
+			aiGeneratedVariablesInTemplates = append(aiGeneratedVariablesInTemplates, v.Name)
+		}
+	}
+
+	// the variables used in the templates, along with any variables their prompts reference
+	generationLayers, err := llm.GetGenerationLayers(prefs.AiGeneratedVariables, aiGeneratedVariablesInTemplates)
+	if err != nil {
+		return titleCandidateT{}, fmt.Errorf("unable to resolve aiGeneratedVariable dependencies - err: %w", err)
+	}
+
+	aiGeneratedResponsesMap := map[string]string{}
+	fallbackValuesMap := map[string]string{} // substituted for variables which failed, but are not saved
+	cachedResponsesMap := map[string]string{}
+	generatedResponses := []generatedResponseT{}
+	droppedPlaceholders := []string{}
+	resolvedValuesMap := map[string]string{} // values from earlier layers, substituted into dependent prompts
+	promptImages := &promptImagesT{}
+
+	streamCategory := prefs.TwitchVariables.StreamCategory.Value
+
+	// each layer only depends on the layers before it
+	for _, layer := range generationLayers {
+
+		promptsMap := map[string]string{}
+		imagesMap := map[string][]llm.ImageT{}
+		cacheKeysMap := map[string]string{} // the prompt and a hash of its images
+		for _, name := range layer {
+			placeholderStr := helpers.GenerateVarPlaceholderString(name)
+			v := aiGeneratedVariablesMap[placeholderStr]
+			prompt := v.PromptMain
+			if v.PromptSuffix != "" {
+				prompt += "\n" + v.PromptSuffix
+			}
+			prompt = twitchVariableStringReplacer.Replace(prompt)
+			dependencies := []config.LlmVariableT{}
+			for _, dependencyName := range llm.GetVariableDependencies(v, prefs.AiGeneratedVariables) {
+				dependencies = append(dependencies, aiGeneratedVariablesMap[helpers.GenerateVarPlaceholderString(dependencyName)])
+			}
+			aiGeneratedVariablesStringReplacer, err := getAiGeneratedVariablesStringReplacer(dependencies, resolvedValuesMap)
+			if err != nil {
+				return titleCandidateT{}, fmt.Errorf("unable to get aiGeneratedVariables string replacer for %v - err: %w", placeholderStr, err)
+			}
+			prompt = aiGeneratedVariablesStringReplacer.Replace(prompt)
+			if prefs.Title.ThrowErrorIfEmptyVariable && strings.Contains(prompt, emptyVariablePlaceholder) {
+				return titleCandidateT{}, fmt.Errorf("prompt for aiGeneratedVariable %v has an empty value", placeholderStr)
+			}
+			promptsMap[placeholderStr] = addKnowledgeToPrompt(prompt, v)
+			imagesMap[placeholderStr] = promptImages.getImages(ctx, v)
+			cacheKeysMap[placeholderStr] = llm.CacheKey(promptsMap[placeholderStr], imagesMap[placeholderStr])
+		}
+
+		// reuse cached values for variables which do not need refreshing yet
+		if useCache {
+			for placeholderStr := range promptsMap {
+				cached, exists := llmResponseCache.Lookup(aiGeneratedVariablesMap[placeholderStr], cacheKeysMap[placeholderStr], streamCategory, time.Now())
+				if !exists {
+					continue
+				}
+				cachedResponsesMap[placeholderStr] = cached.Value
+				delete(promptsMap, placeholderStr)
+				if err := ActivityConsole.pushToConsole(
+					config.Logger.LogToBufferf(
+						"%s reused from cache (generated %v ago by %q)",
+						placeholderStr, time.Since(cached.GeneratedAt).Round(time.Second), cached.ProviderName,
+					),
+				); err != nil {
+					config.Logger.LogErrorf("unable to push cache info to console - err: %v", err)
+				}
+			}
+		}
+
+		providerChainsMap := map[string][]config.LlmProviderProfileT{}
+		for placeholderStr := range promptsMap {
+			v := aiGeneratedVariablesMap[placeholderStr]
+			providerChain, err := llm.GetProviderChain(prefs.LlmConfig, v)
+			if err != nil {
+				return titleCandidateT{}, fmt.Errorf("unable to get provider chain for aiGeneratedVariable %v - err: %w", placeholderStr, err)
+			}
+			providerChainsMap[placeholderStr] = providerChain
+		}
+
+		var wg sync.WaitGroup
+		var responsesMapMutex sync.Mutex
+		doneChan := make(chan struct{})
+		errChan := make(chan error, len(promptsMap))
+
+		for placeholderStr, prompt := range promptsMap {
+			wg.Add(1)
+			go func(placeholderStr, prompt string) {
+				defer wg.Done()
+				config.Logger.LogDebugf("sending prompt: %q", prompt)
+				v := aiGeneratedVariablesMap[placeholderStr]
+				result, err := llm.GenerateVariableValue(ctx, llm.GenerationRequestT{
+					Variable:   v,
+					Profiles:   providerChainsMap[placeholderStr],
+					Prompt:     prompt,
+					Images:     imagesMap[placeholderStr],
+					Timeout:    llmResponseTimeout,
+					Moderation: prefs.Moderation,
+				})
+				if err != nil {
+					outcome, policyErr := llm.ApplyFailurePolicy(v, err)
+					if policyErr != nil {
+						errChan <- fmt.Errorf("unable to get response text for %v - err: %w", prompt, policyErr)
+						return
+					}
+					config.Logger.LogErrorf("unable to generate %v - %s - err: %v", placeholderStr, outcome.Description, err)
+					reason := "failed to generate"
+					if errors.Is(err, llm.ErrBudgetExceeded) {
+						reason = "paused as the LLM budget has been exceeded"
+					}
+					if err := ActivityConsole.pushToConsole(
+						config.Logger.LogToBufferf("%s %s - %s", placeholderStr, reason, outcome.Description),
+					); err != nil {
+						config.Logger.LogErrorf("unable to push failure info to console - err: %v", err)
+					}
+					responsesMapMutex.Lock()
+					if outcome.DropSection {
+						for _, name := range v.VariableNames() {
+							droppedPlaceholders = append(droppedPlaceholders, helpers.GenerateVarPlaceholderString(name))
+						}
+					} else {
+						fallbackValuesMap[placeholderStr] = outcome.Value
+					}
+					responsesMapMutex.Unlock()
+					return
+				}
+				if err := ActivityConsole.pushToConsole(
+					config.Logger.LogToBufferf("%s generated by %q", placeholderStr, result.ProviderName),
+				); err != nil {
+					config.Logger.LogErrorf("unable to push provider info to console - err: %v", err)
+				}
+				responsesMapMutex.Lock()
+				aiGeneratedResponsesMap[placeholderStr] = result.Value
+				generatedResponses = append(generatedResponses, generatedResponseT{
+					variableName: v.Name,
+					cacheKey:     cacheKeysMap[placeholderStr],
+					category:     streamCategory,
+					value:        result.Value,
+					providerName: result.ProviderName,
+					generatedAt:  time.Now(),
+				})
+				responsesMapMutex.Unlock()
+			}(placeholderStr, prompt)
+		}
+
+		go func() {
+			wg.Wait()
+			close(doneChan)
+		}()
+
+		select {
+		case err := <-errChan:
+			return titleCandidateT{}, fmt.Errorf("unable to retrieve all LLM responses - err: %w", err)
+		case <-doneChan:
+			//
+		}
+
+		for _, name := range layer {
+			placeholderStr := helpers.GenerateVarPlaceholderString(name)
+			for _, valuesMap := range []map[string]string{aiGeneratedResponsesMap, fallbackValuesMap, cachedResponsesMap} {
+				value, exists := valuesMap[placeholderStr]
+				if !exists {
+					continue
+				}
+				// groups resolve to one value per field
+				placeholderValues, err := llm.GetPlaceholderValues(aiGeneratedVariablesMap[placeholderStr], value)
+				if err != nil {
+					return titleCandidateT{}, fmt.Errorf("unable to resolve aiGeneratedVariable %v - err: %w", placeholderStr, err)
+				}
+				maps.Copy(resolvedValuesMap, placeholderValues)
+			}
+		}
+	}
+
+	// used to replace ALL mentioned variables with their respective value
+	fullVariableReplacementMap := maps.Clone(resolvedValuesMap)
+
+	// remove the sections of any variables which failed, then unwrap the rest
+	titleTemplate = helpers.ResolveTemplateSections(titleTemplate, droppedPlaceholders)
+
+	allTwitchVariablesMap := helpers.GenerateMapFromHomogenousStruct[
+		config.TwitchVariablesT, config.TwitchVariableT,
+	](prefs.TwitchVariables)
+
+	twitchVariablesUsedInTitleMap := map[string]config.TwitchVariableT{}
+	for varName, twitchVar := range allTwitchVariablesMap {
+		varNamePlaceholder := helpers.GenerateVarPlaceholderString(varName)
+		if strings.Contains(titleTemplate, varNamePlaceholder) {
+			twitchVariablesUsedInTitleMap[varName] = twitchVar
+		}
+	}
+	config.Logger.LogDebugf("twitchVariablesUsedInTitleMap: %v", twitchVariablesUsedInTitleMap)
+
+	for varName, twitchVar := range twitchVariablesUsedInTitleMap {
+		replaceFrom := helpers.GenerateVarPlaceholderString(varName)
+		if _, exists := fullVariableReplacementMap[replaceFrom]; exists {
+			return titleCandidateT{}, fmt.Errorf("conflicting variable name: %q", replaceFrom)
+		}
+		fullVariableReplacementMap[replaceFrom] = twitchVar.Value
+	}
+	config.Logger.LogDebugf("fullVariableReplacementMap: %v", fullVariableReplacementMap)
+
+	allVariablesReplacer, err := helpers.GetStringReplacerFromMap(
+		fullVariableReplacementMap, !prefs.Title.ThrowErrorIfEmptyVariable, false,
+	)
+	if err != nil {
+		return titleCandidateT{}, fmt.Errorf("unable to construct allVariablesReplacer - err: %w", err)
+	}
+
+	newTitle := strings.TrimSpace(allVariablesReplacer.Replace(titleTemplate))
+
+	// check there are no "placeholder" values (non-existent variables) left
+	matchingVariables := helpers.ExtractVariableNamesFromText(newTitle, nil)
+	if prefs.Title.ThrowErrorIfNonExistentVariable && len(matchingVariables) > 0 {
+		return titleCandidateT{}, fmt.Errorf("non-existent variable in resulting twitch title - err: %w", err)
+	}
+
+	if prefs.Title.ThrowErrorIfTooLong && len(newTitle) > twitch.MaxTitleLength {
+		return titleCandidateT{}, fmt.Errorf("title is too long (%v chars) - err: %w", len(newTitle), err)
+	}
+
+	return titleCandidateT{
+		title:                   newTitle,
+		aiGeneratedResponsesMap: aiGeneratedResponsesMap,
+		generatedResponses:      generatedResponses,
+		variableValues:          resolvedValuesMap,
+	}, nil
+}
+
+// Marks where the new title began in the stream's VOD, returning the marker to record in the title history.
+// Markers are not essential, so the title update carries on without one if it cannot be created.
+func addStreamMarker(ctx context.Context, prefs config.PreferencesFormat, title string) *twitch.StreamMarkerT {
+	marker, err := twitch.CreateStreamMarker(ctx, prefs, title)
+	if err != nil {
+		reason := "it could not be created"
+		switch {
+		case errors.Is(err, twitch.ErrStreamNotLive):
+			reason = "the stream is not live"
+		case errors.Is(err, twitch.ErrMissingScope):
+			reason = fmt.Sprintf("re-authenticate in Twitch Configuration to grant %s", twitch.ScopeChannelManageBroadcast)
+		default:
+			config.Logger.LogErrorf("unable to create stream marker - err: %v", err)
+		}
+		if err := ActivityConsole.pushToConsole(config.Logger.LogToBufferf("Stream marker skipped - %s", reason)); err != nil {
+			config.Logger.LogErrorf("unable to push stream marker info to console - err: %v", err)
+		}
+		return nil
+	}
+	if err := ActivityConsole.pushToConsole(
+		config.Logger.LogToBufferf("Added stream marker at %s", helpers.GetTimeStringFromSeconds(marker.PositionSeconds)),
+	); err != nil {
+		config.Logger.LogErrorf("unable to push stream marker info to console - err: %v", err)
+	}
+	return marker
+}
+
+// Applies local moderation, then optionally Twitch AutoMod, to a rendered title
+func moderateTitle(ctx context.Context, title string, prefs config.PreferencesFormat) (string, error) {
+	result := moderation.Check(title, prefs.Moderation)
+	switch result.Action {
+	case config.ModerationActionReject:
+		return "", fmt.Errorf("%w - matched %q", errTitleRejectedByModeration, result.MatchedTerms())
+	case config.ModerationActionRegenerate:
+		return "", fmt.Errorf("%w - matched %q", errTitleToRegenerate, result.MatchedTerms())
+	case config.ModerationActionMask:
+		title = result.Text
+	}
+	if prefs.Moderation.UseTwitchAutoMod {
+		isPermitted, err := twitch.CheckAutoModStatus(ctx, prefs, title)
+		if err != nil {
+			// fail closed - never publish a title which could not be checked
+			return "", fmt.Errorf("%w - unable to check automod status - err: %v", errTitleRejectedByModeration, err)
+		}
+		if !isPermitted {
+			return "", fmt.Errorf("%w by twitch automod", errTitleRejectedByModeration)
+		}
+	}
+	return title, nil
+}
+
+// Allows for waiting on title approval, and for regenerating titles after approval or moderation
+func getCycleTimeout() time.Duration {
+	attemptTimeout := singleCycleTimeout
+	if approvalConfig := config.Preferences.Title.Approval; approvalConfig.Enabled {
+		approvalTimeout := time.Duration(approvalConfig.TimeoutSeconds) * time.Second
+		attemptTimeout = (singleCycleTimeout + approvalTimeout) * (maxApprovalRegenerations + 1)
+	}
+	if !config.Preferences.Moderation.Enabled {
+		return attemptTimeout
+	}
+	return attemptTimeout * (maxModerationRegenerations + 1)
+}
+
+func stopUpdater() {
+	if updaterCancel != nil {
+		updaterCancel()
+		updaterCancel = nil
+	}
+	approval.Queue.Clear()
+	stopControlServer()
+	stopTagsUpdater()
+	if updaterTicker != nil {
+		config.Logger.LogInfo("updaterTicker stopped")
+		updaterTicker.Stop()
+		updaterTicker = nil
+	}
+	if updaterTickerDone != nil {
+		config.Logger.LogInfo("updaterTickerDone closed")
+		close(updaterTickerDone)
+		updaterTickerDone = nil
+	}
+}
+
+// Prepends the variable's knowledge base snippets to its prompt.
+// If the documents cannot be read, the prompt is sent without them.
+func addKnowledgeToPrompt(prompt string, variable config.LlmVariableT) string {
+	if len(variable.Knowledge.Documents) == 0 {
+		return prompt
+	}
+	documents, err := llm.LoadKnowledgeDocuments(config.KnowledgeBaseDir, variable.Knowledge)
+	if err != nil {
+		placeholderStr := helpers.GenerateVarPlaceholderString(variable.Name)
+		config.Logger.LogErrorf("unable to load knowledge base for %v - err: %v", placeholderStr, err)
+		if err := ActivityConsole.pushToConsole(
+			config.Logger.LogToBufferf("%s is being generated without its knowledge base - %v", placeholderStr, err),
+		); err != nil {
+			config.Logger.LogErrorf("unable to push knowledge base failure to console - err: %v", err)
+		}
+		return prompt
+	}
+	return llm.AddKnowledgeToPrompt(prompt, documents, variable.Knowledge.MaxTokens)
+}
+
+// Substitutes the values of a prompt's AI-generated variable dependencies, which must already be resolved
+func getAiGeneratedVariablesStringReplacer(dependencies []config.LlmVariableT, resolvedValuesMap map[string]string) (*strings.Replacer, error) {
+	dependencyValuesMap := map[string]string{}
+	for _, dependency := range dependencies {
+		for _, name := range dependency.VariableNames() {
+			placeholderStr := helpers.GenerateVarPlaceholderString(name)
+			val := resolvedValuesMap[placeholderStr]
+			if val == "" {
+				// the dependency failed and its section was dropped
+				val = emptyVariablePlaceholder
+			}
+			dependencyValuesMap[placeholderStr] = val
+		}
+	}
+	aiGeneratedVariablesStringReplacer, err := helpers.GetStringReplacerFromMap(dependencyValuesMap, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create string replacer map for aiGeneratedVariables - err: %w", err)
+	}
+	return aiGeneratedVariablesStringReplacer, nil
+}
+
+func getTwitchVariablesStringReplacer(twitchVariables config.TwitchVariablesT) (*strings.Replacer, error) {
+	twitchVariablesMap := helpers.GenerateMapFromHomogenousStruct[config.TwitchVariablesT, config.TwitchVariableT](twitchVariables)
+	twitchVariablesValuesMap := map[string]string{}
+	for varName, v := range twitchVariablesMap {
+		val := v.Value
+		if val == "" {
+			val = emptyVariablePlaceholder
+		}
+		twitchVariablesValuesMap[helpers.GenerateVarPlaceholderString(varName)] = val
+	}
+	twitchVariablesStringReplacer, err := helpers.GetStringReplacerFromMap(twitchVariablesValuesMap, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create string replacer map for twitch variables - err: %w", err)
+	}
+	return twitchVariablesStringReplacer, nil
+}
@@ -0,0 +1,450 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+	"github.com/finahdinner/tidal/approval"
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/llm"
+	"github.com/finahdinner/tidal/twitch"
+	"github.com/finahdinner/tidal/twitch/twitchtest"
+)
+
+func TestMain(m *testing.M) {
+	// start from fresh preferences, removed once the tests finish
+	dir, err := os.MkdirTemp("", "tidal-gui-test-*")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := config.UseAppConfigDir(dir); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Sets up preferences for a title update against the fake Twitch started beforehand, with AI-generated variables from the mock provider
+func useTestPreferences(t *testing.T, titleTemplate string, variables ...config.LlmVariableT) {
+	t.Helper()
+	Gui = &GuiWrapper{App: test.NewTempApp(t)}
+	ActivityConsole = NewActivityConsole()
+	llm.ResetMockProviders()
+	llmResponseCache = llm.NewResponseCache()
+
+	prevPreferences := config.Preferences
+	prefs := config.Preferences
+	prefs.Title = config.TitleT{TitleTemplate: titleTemplate, Candidates: config.TitleCandidatesConfigT{NumCandidates: 1}}
+	prefs.TitleHistory = nil
+	prefs.Moderation = config.ModerationConfigT{}
+	prefs.ChannelProfiles = nil
+	prefs.Tags = config.TagsConfigT{}
+	prefs.LlmConfig = config.LlmConfigT{}
+	prefs.AiGeneratedVariables = variables
+	config.Preferences = prefs
+
+	t.Cleanup(func() {
+		config.Preferences = prevPreferences
+	})
+}
+
+// Writes a mock provider script, and adds a provider profile which uses it
+func addMockProvider(t *testing.T, name string, script string) {
+	t.Helper()
+	scriptPath := filepath.Join(t.TempDir(), name+".json")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.Preferences.LlmConfig.ProviderProfiles = append(config.Preferences.LlmConfig.ProviderProfiles, config.LlmProviderProfileT{
+		Name:       name,
+		Provider:   llm.ProviderMock,
+		ScriptPath: scriptPath,
+	})
+}
+
+func TestUpdateTitleKeepsCredentialsRefreshedMidCycle(t *testing.T) {
+	fake := twitchtest.New(t)
+	useTestPreferences(t, "Playing $$Game", config.LlmVariableT{
+		Name:          "Game",
+		PromptMain:    "Name a game",
+		ProviderChain: []string{"mock"},
+	})
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["Chess", "Go"]}`)
+
+	// twitch no longer accepts the access token, so the cycle refreshes it part way through
+	fake.RevokeAccessToken()
+	if err := updateTitle(context.Background()); err != nil {
+		t.Fatalf("unable to update title - err: %v", err)
+	}
+
+	credentials := config.GetCredentials()
+	if credentials.UserAccessToken != "access-1" || credentials.UserAccessRefreshToken != "refresh-1" {
+		t.Fatalf("expected the refreshed credentials to be kept, got %+v", credentials)
+	}
+	saved, err := config.GetPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.TwitchConfig.Credentials.UserAccessRefreshToken != "refresh-1" {
+		t.Errorf("expected the refreshed credentials to be saved, got %+v", saved.TwitchConfig.Credentials)
+	}
+	if saved.Title.Value != "Playing Chess" || saved.AiGeneratedVariables[0].Value != "Chess" {
+		t.Errorf("expected the title and generated value to be saved, got %q and %q", saved.Title.Value, saved.AiGeneratedVariables[0].Value)
+	}
+
+	// refresh tokens are single use, so a rolled back refresh token would fail here
+	fake.RevokeAccessToken()
+	if err := updateTitle(context.Background()); err != nil {
+		t.Fatalf("unable to update title after refreshing - err: %v", err)
+	}
+	if fake.TokensIssued != 2 {
+		t.Errorf("expected 2 refreshes, got %v", fake.TokensIssued)
+	}
+	if strings.Join(fake.Titles, ",") != "Playing Chess,Playing Go" {
+		t.Errorf("unexpected titles %q", fake.Titles)
+	}
+}
+
+func TestRenderTitleCandidateRoundRobin(t *testing.T) {
+	twitchtest.New(t)
+	useTestPreferences(t, "Today: $$Topic", config.LlmVariableT{
+		Name:          "Topic",
+		PromptMain:    "Suggest a topic",
+		ProviderChain: []string{"mock"},
+	})
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["cats", "dogs"]}`)
+
+	for _, expected := range []string{"Today: cats", "Today: dogs", "Today: cats"} {
+		candidate, err := renderTitleCandidate(context.Background(), config.SnapshotPreferences(), config.Preferences.Title.TitleTemplate, nil, true)
+		if err != nil {
+			t.Fatalf("unable to render title - err: %v", err)
+		}
+		if candidate.title != expected {
+			t.Errorf("expected %q, got %q", expected, candidate.title)
+		}
+	}
+}
+
+func TestRenderTitleCandidateRegexWithDependencies(t *testing.T) {
+	twitchtest.New(t)
+	useTestPreferences(t, "$$Game - $$Fact",
+		config.LlmVariableT{Name: "Game", PromptMain: "Name a game", ProviderChain: []string{"mock"}},
+		config.LlmVariableT{Name: "Fact", PromptMain: "Share a fact about $$Game", ProviderChain: []string{"mock"}},
+	)
+	addMockProvider(t, "mock", `{
+		"mode": "regex",
+		"rules": [{"pattern": "fact about (\\w+)", "response": "$1 is older than it looks"}],
+		"responses": ["Chess"]
+	}`)
+
+	candidate, err := renderTitleCandidate(context.Background(), config.SnapshotPreferences(), config.Preferences.Title.TitleTemplate, nil, true)
+	if err != nil {
+		t.Fatalf("unable to render title - err: %v", err)
+	}
+	// the dependency is generated first, so its value is substituted into the dependent prompt
+	if candidate.title != "Chess - Chess is older than it looks" {
+		t.Errorf("unexpected title %q", candidate.title)
+	}
+	if candidate.aiGeneratedResponsesMap["$$Game"] != "Chess" {
+		t.Errorf("expected the dependency's response to be kept, got %v", candidate.aiGeneratedResponsesMap)
+	}
+}
+
+func TestRenderTitleCandidateFailurePolicies(t *testing.T) {
+	twitchtest.New(t)
+	useTestPreferences(t, "$$Fallback[[ with $$Dropped]]",
+		config.LlmVariableT{
+			Name:          "Fallback",
+			PromptMain:    "Say something",
+			ProviderChain: []string{"failing"},
+			FailurePolicy: config.LlmFailurePolicyT{OnFailure: config.FailureActionFallbackValue, FallbackValue: "Just chatting"},
+		},
+		config.LlmVariableT{
+			Name:          "Dropped",
+			PromptMain:    "Say something else",
+			ProviderChain: []string{"failing"},
+			FailurePolicy: config.LlmFailurePolicyT{OnFailure: config.FailureActionDropSection},
+		},
+		config.LlmVariableT{
+			Name:          "Stopping",
+			PromptMain:    "Say one more thing",
+			ProviderChain: []string{"failing"},
+			FailurePolicy: config.LlmFailurePolicyT{OnFailure: config.FailureActionStopTidal},
+		},
+	)
+	addMockProvider(t, "failing", `{"mode": "round_robin", "responses": ["unused"], "errors": {"every_nth_call": 1}}`)
+
+	candidate, err := renderTitleCandidate(context.Background(), config.SnapshotPreferences(), config.Preferences.Title.TitleTemplate, nil, true)
+	if err != nil {
+		t.Fatalf("unable to render title - err: %v", err)
+	}
+	if candidate.title != "Just chatting" {
+		t.Errorf("expected the fallback value with the failed section dropped, got %q", candidate.title)
+	}
+	// fallback values are substituted, but never saved as the variable's value
+	if len(candidate.aiGeneratedResponsesMap) != 0 {
+		t.Errorf("expected no generated responses, got %v", candidate.aiGeneratedResponsesMap)
+	}
+
+	if _, err := renderTitleCandidate(context.Background(), config.SnapshotPreferences(), "$$Fallback $$Stopping", nil, true); err == nil {
+		t.Error("expected a variable whose policy is to stop Tidal to fail the title")
+	}
+}
+
+func TestRenderTitleCandidateFallsBackFromSlowProvider(t *testing.T) {
+	twitchtest.New(t)
+	useTestPreferences(t, "$$Quick and $$Patient",
+		config.LlmVariableT{Name: "Quick", PromptMain: "Be quick", ProviderChain: []string{"slow", "fast"}},
+		config.LlmVariableT{Name: "Patient", PromptMain: "Take your time", ProviderChain: []string{"steady", "fast"}},
+	)
+	// slower than llmResponseTimeout, so it times out and the next provider in the chain is used
+	addMockProvider(t, "slow", fmt.Sprintf(
+		`{"mode": "round_robin", "responses": ["too late"], "latency": {"milliseconds": %v}}`,
+		(llmResponseTimeout+time.Second).Milliseconds(),
+	))
+	addMockProvider(t, "steady", `{"mode": "round_robin", "responses": ["steady"], "latency": {"milliseconds": 50}}`)
+	addMockProvider(t, "fast", `{"mode": "round_robin", "responses": ["fast"]}`)
+
+	candidate, err := renderTitleCandidate(context.Background(), config.SnapshotPreferences(), config.Preferences.Title.TitleTemplate, nil, true)
+	if err != nil {
+		t.Fatalf("unable to render title - err: %v", err)
+	}
+	if candidate.title != "fast and steady" {
+		t.Errorf("unexpected title %q", candidate.title)
+	}
+}
+
+func TestUpdateTitleDoesNotPublishTooLongTitles(t *testing.T) {
+	fake := twitchtest.New(t)
+	useTestPreferences(t, "$$Rambling", config.LlmVariableT{
+		Name:          "Rambling",
+		PromptMain:    "Ramble on",
+		ProviderChain: []string{"mock"},
+	})
+	config.Preferences.Title.ThrowErrorIfTooLong = true
+	addMockProvider(t, "mock", fmt.Sprintf(`{"mode": "round_robin", "responses": [%q]}`, strings.Repeat("a", twitch.MaxTitleLength+1)))
+
+	if err := updateTitle(context.Background()); err == nil {
+		t.Fatal("expected a title which is too long to fail")
+	}
+	if len(fake.Titles) != 0 {
+		t.Errorf("expected no title to be published, got %q", fake.Titles)
+	}
+	if config.Preferences.AiGeneratedVariables[0].Value != "" {
+		t.Errorf("expected the generated value not to be saved, got %q", config.Preferences.AiGeneratedVariables[0].Value)
+	}
+}
+
+func TestUpdateTitleSavesTitleHistory(t *testing.T) {
+	twitchtest.New(t)
+	useTestPreferences(t, "Round $$Round", config.LlmVariableT{
+		Name:          "Round",
+		PromptMain:    "Count",
+		ProviderChain: []string{"mock"},
+		History:       config.LlmHistoryConfigT{Size: 2},
+	})
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["1", "2", "3"]}`)
+
+	for range 3 {
+		if err := updateTitle(context.Background()); err != nil {
+			t.Fatalf("unable to update title - err: %v", err)
+		}
+	}
+
+	prefs := config.SnapshotPreferences()
+	if prefs.Title.Value != "Round 3" {
+		t.Errorf("expected the latest title to be saved, got %q", prefs.Title.Value)
+	}
+	historyTitles := []string{}
+	for _, entry := range prefs.TitleHistory {
+		historyTitles = append(historyTitles, entry.Title)
+	}
+	if strings.Join(historyTitles, ",") != "Round 1,Round 2,Round 3" {
+		t.Errorf("unexpected title history %q", historyTitles)
+	}
+	if v := prefs.AiGeneratedVariables[0]; v.Value != "3" || strings.Join(v.RecentValues, ",") != "2,3" {
+		t.Errorf("unexpected variable value %q and recent values %q", v.Value, v.RecentValues)
+	}
+}
+
+func TestUpdateTagsSavesGeneratedValue(t *testing.T) {
+	twitchtest.New(t)
+	useTestPreferences(t, "Playing chess", config.LlmVariableT{
+		Name:          "StreamTags",
+		PromptMain:    "Suggest tags",
+		Mode:          config.VariableModeTags,
+		ProviderChain: []string{"mock"},
+		History:       config.LlmHistoryConfigT{Size: 2},
+	})
+	config.Preferences.Tags = config.TagsConfigT{Enabled: true, VariableName: "StreamTags", AlwaysOnTags: []string{"English"}}
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["Cozy, Chess"]}`)
+
+	if err := updateTags(context.Background()); err != nil {
+		t.Fatalf("unable to update tags - err: %v", err)
+	}
+	prefs := config.SnapshotPreferences()
+	if v := prefs.AiGeneratedVariables[0]; v.Value != "Cozy, Chess" || strings.Join(v.RecentValues, ",") != "Cozy, Chess" {
+		t.Errorf("unexpected variable value %q and recent values %q", v.Value, v.RecentValues)
+	}
+}
+
+func TestScoreTitleLength(t *testing.T) {
+	for _, tc := range []struct {
+		length   int
+		expected float64
+	}{
+		{length: targetTitleLength, expected: 1},
+		{length: (targetTitleLength + twitch.MaxTitleLength) / 2, expected: 0.5},
+		{length: twitch.MaxTitleLength, expected: 0},
+		{length: twitch.MaxTitleLength + 10, expected: 0},
+	} {
+		if score := scoreTitleLength(strings.Repeat("a", tc.length)); score != tc.expected {
+			t.Errorf("%v characters: expected a score of %v, got %v", tc.length, tc.expected, score)
+		}
+	}
+}
+
+func TestUpdateTitleRegeneratesAfterModeration(t *testing.T) {
+	fake := twitchtest.New(t)
+	useTestPreferences(t, "Free $$Thing", config.LlmVariableT{
+		Name:          "Thing",
+		PromptMain:    "Name a thing",
+		ProviderChain: []string{"mock"},
+	})
+	// the blocked term only appears once the value is in the title
+	config.Preferences.Moderation = config.ModerationConfigT{
+		Enabled:         true,
+		SevereBlocklist: []string{"free money"},
+		SevereAction:    config.ModerationActionRegenerate,
+	}
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["money", "chess"]}`)
+
+	if err := updateTitle(context.Background()); err != nil {
+		t.Fatalf("unable to update title - err: %v", err)
+	}
+	if strings.Join(fake.Titles, ",") != "Free chess" {
+		t.Errorf("expected only the regenerated title to be published, got %q", fake.Titles)
+	}
+}
+
+func TestUpdateTitleApprovalRegenerateSkipsCache(t *testing.T) {
+	fake := twitchtest.New(t)
+	useTestPreferences(t, "Playing $$Game", config.LlmVariableT{
+		Name:          "Game",
+		PromptMain:    "Name a game",
+		ProviderChain: []string{"mock"},
+		Refresh:       config.LlmRefreshPolicyT{Mode: config.RefreshModeInterval, IntervalMinutes: 60},
+	})
+	config.Preferences.Title.Approval = config.TitleApprovalConfigT{
+		Enabled:         true,
+		TimeoutSeconds:  5,
+		OnTimeoutAction: config.ApprovalActionSkip,
+	}
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["Chess", "Go"]}`)
+
+	// regenerate the first title, then approve the second
+	decidedTitles := make(chan []string, 1)
+	go func() {
+		titles := []string{}
+		prevId := ""
+		for len(titles) < 2 {
+			request, exists := approval.Queue.Pending()
+			if !exists || request.Id == prevId {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			prevId = request.Id
+			titles = append(titles, request.Candidates[0])
+			action := config.ApprovalActionRegenerate
+			if len(titles) == 2 {
+				action = config.ApprovalActionApprove
+			}
+			if err := approval.Queue.Decide(request.Id, approval.DecisionT{Action: action}); err != nil {
+				t.Error(err)
+			}
+		}
+		decidedTitles <- titles
+	}()
+
+	if err := updateTitle(context.Background()); err != nil {
+		t.Fatalf("unable to update title - err: %v", err)
+	}
+	if titles := <-decidedTitles; strings.Join(titles, ",") != "Playing Chess,Playing Go" {
+		t.Errorf("expected the regenerated title not to reuse the cached value, got %q", titles)
+	}
+	if strings.Join(fake.Titles, ",") != "Playing Go" {
+		t.Errorf("unexpected published titles %q", fake.Titles)
+	}
+}
+
+func TestRenderTitleCandidatesOnlyFirstUsesCache(t *testing.T) {
+	twitchtest.New(t)
+	useTestPreferences(t, "Playing $$Game", config.LlmVariableT{
+		Name:          "Game",
+		PromptMain:    "Name a game",
+		ProviderChain: []string{"mock"},
+		Refresh:       config.LlmRefreshPolicyT{Mode: config.RefreshModeInterval, IntervalMinutes: 60},
+	})
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["Chess", "Go", "Poker"]}`)
+
+	// caches Chess
+	if err := updateTitle(context.Background()); err != nil {
+		t.Fatalf("unable to update title - err: %v", err)
+	}
+
+	candidates, err := renderTitleCandidates(context.Background(), config.SnapshotPreferences(), "Playing $$Game", nil, 3)
+	if err != nil {
+		t.Fatalf("unable to render title candidates - err: %v", err)
+	}
+	titles := []string{}
+	for _, c := range candidates {
+		titles = append(titles, c.title)
+	}
+	slices.Sort(titles)
+	if strings.Join(titles, ",") != "Playing Chess,Playing Go,Playing Poker" {
+		t.Errorf("expected one cached candidate and two newly generated ones, got %q", titles)
+	}
+}
+
+func TestUpdateTitleRegeneratesWhenImageChanges(t *testing.T) {
+	twitchtest.New(t)
+	screenshotPath := filepath.Join(t.TempDir(), "screenshot.png")
+	useTestPreferences(t, "Playing $$Game", config.LlmVariableT{
+		Name:          "Game",
+		PromptMain:    "Name the game in the screenshot",
+		ProviderChain: []string{"mock"},
+		Refresh:       config.LlmRefreshPolicyT{Mode: config.RefreshModeInputChange},
+		Image:         config.LlmImageConfigT{Source: config.ImageSourceScreenshotFile, FilePath: screenshotPath},
+	})
+	addMockProvider(t, "mock", `{"mode": "round_robin", "responses": ["Chess", "Go"]}`)
+
+	// the prompt stays the same, so only the screenshot decides whether the cached value is reused
+	for _, tc := range []struct {
+		screenshot string
+		expected   string
+	}{
+		{screenshot: "first", expected: "Playing Chess"},
+		{screenshot: "first", expected: "Playing Chess"},
+		{screenshot: "second", expected: "Playing Go"},
+	} {
+		if err := os.WriteFile(screenshotPath, []byte("\x89PNG\r\n\x1a\n"+tc.screenshot), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := updateTitle(context.Background()); err != nil {
+			t.Fatalf("unable to update title - err: %v", err)
+		}
+		if title := config.SnapshotPreferences().Title.Value; title != tc.expected {
+			t.Errorf("with the %v screenshot, expected %q, got %q", tc.screenshot, tc.expected, title)
+		}
+	}
+}
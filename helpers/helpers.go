@@ -1,141 +1,279 @@
-package helpers
-
-import (
-	"fmt"
-	"math/rand/v2"
-	"net"
-	"os/exec"
-	"reflect"
-	"regexp"
-	"runtime"
-	"strings"
-	"time"
-)
-
-const (
-	MinTitleUpdateIntervalMinutes = 1
-	MaxTitleUpdateIntervalMinutes = 1440
-
-	VarNamePlaceholderPrefix = "$$"
-	VariablePlaceholderValue = "-"
-)
-
-func GenerateCsrfToken(length int) string {
-	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
-	res := make([]byte, length)
-	for i := range length {
-		res[i] = chars[rand.IntN(len(chars))]
-	}
-	return string(res)[:length]
-}
-
-func GenerateVarPlaceholderString(varName string) string {
-	return fmt.Sprintf("%v%v", VarNamePlaceholderPrefix, varName)
-}
-
-func GetVarNameFromPlaceholderString(placeholderString string) string {
-	return strings.Replace(placeholderString, VarNamePlaceholderPrefix, "", 1)
-}
-
-func GetStringReplacerFromMap(m map[string]string, allowEmptyReplacements bool, allowSubstrings bool) (*strings.Replacer, error) {
-	replacementList := []string{}
-	replaceFromKeys := make([]string, 0, len(m))
-
-	for replaceFrom, replaceTo := range m {
-		if replaceFrom == "" {
-			return nil, fmt.Errorf("mapping of %q to %q is not valid - replaceFrom must not be empty", replaceFrom, replaceTo)
-		}
-		if !allowEmptyReplacements && replaceTo == "" {
-			return nil, fmt.Errorf("mapping of %q to %q is not valid - they must both be populated", replaceFrom, replaceTo)
-		}
-		replacementList = append(replacementList, replaceFrom, replaceTo)
-		replaceFromKeys = append(replaceFromKeys, replaceFrom)
-	}
-	// check if any replaceFrom is a substring of any other replaceFrom
-	if !allowSubstrings {
-		for _, k := range replaceFromKeys {
-			for _, j := range replaceFromKeys {
-				if k == j {
-					continue
-				}
-				if strings.Contains(k, j) {
-					return nil, fmt.Errorf("invalid replacement map: key %q contains key %q as a substring", k, j)
-				}
-			}
-		}
-	}
-	return strings.NewReplacer(replacementList...), nil
-}
-
-// convert seconds to HH:MM::SS
-func GetTimeStringFromSeconds(totalSeconds int) string {
-	duration := time.Duration(totalSeconds) * time.Second
-	hours := int(duration.Hours())
-	minutes := int(duration.Minutes()) % 60
-	seconds := int(duration.Seconds()) % 60
-	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
-}
-
-func PortInUse(hostAndPort string) bool {
-	listener, err := net.Listen("tcp", hostAndPort)
-	if err != nil {
-		return true
-	}
-	listener.Close()
-	return false
-}
-
-func OpenUrlInBrowser(url string) error {
-	var err error
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("powershell", "-Command", fmt.Sprintf("Start-Process '%s'", url)).Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
-	default:
-		err = fmt.Errorf("unsupported platform - cannot open browser")
-	}
-	return err
-}
-
-func NumFieldsInStruct(val any) (int, error) {
-	t := reflect.TypeOf(val)
-	if t.Kind() == reflect.Struct {
-		return t.NumField(), nil
-	}
-	return -1, fmt.Errorf("%v is not a struct", val)
-}
-
-// only works for homogenous structs
-func GenerateMapFromHomogenousStruct[ParentType any, FieldValueType any](strct ParentType) map[string]FieldValueType {
-	fields := reflect.TypeOf(strct)
-	vals := reflect.ValueOf(strct)
-	res := map[string]FieldValueType{}
-	for idx := range vals.NumField() {
-		fieldName := fields.Field(idx).Name
-		value := vals.Field(idx).Interface().(FieldValueType)
-		res[fieldName] = value
-	}
-	return res
-}
-
-// Returns unique variable names
-func ExtractVariableNamesFromText(text string) []string {
-	fmtStr := `%s(\w+)`
-	escapedPrefix := regexp.QuoteMeta(VarNamePlaceholderPrefix)
-	r := regexp.MustCompile(fmt.Sprintf(fmtStr, escapedPrefix))
-	matches := r.FindAllStringSubmatch(text, -1)
-	variableNamesMap := make(map[string]struct{}, len(matches))
-	for _, match := range matches {
-		if len(match) > 1 {
-			variableNamesMap[match[1]] = struct{}{}
-		}
-	}
-	variableNamesSlice := make([]string, 0, len(variableNamesMap))
-	for v := range variableNamesMap {
-		variableNamesSlice = append(variableNamesSlice, v)
-	}
-	return variableNamesSlice
-}
+package helpers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MinTitleUpdateIntervalMinutes = 1
+	MaxTitleUpdateIntervalMinutes = 1440
+
+	VarNamePlaceholderPrefix = "$$"
+	VariablePlaceholderValue = "-"
+
+	// wraps an optional part of a title template, which can be dropped if a variable inside it fails
+	TemplateSectionStart = "[["
+	TemplateSectionEnd   = "]]"
+
+	// twitch's limits for stream tags
+	MaxTags      = 10
+	MaxTagLength = 25
+)
+
+func GenerateCsrfToken(length int) string {
+	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	res := make([]byte, length)
+	for i := range length {
+		res[i] = chars[rand.IntN(len(chars))]
+	}
+	return string(res)[:length]
+}
+
+func GenerateVarPlaceholderString(varName string) string {
+	return fmt.Sprintf("%v%v", VarNamePlaceholderPrefix, varName)
+}
+
+func GetVarNameFromPlaceholderString(placeholderString string) string {
+	return strings.Replace(placeholderString, VarNamePlaceholderPrefix, "", 1)
+}
+
+func GetStringReplacerFromMap(m map[string]string, allowEmptyReplacements bool, allowSubstrings bool) (*strings.Replacer, error) {
+	replacementList := []string{}
+	replaceFromKeys := make([]string, 0, len(m))
+
+	for replaceFrom, replaceTo := range m {
+		if replaceFrom == "" {
+			return nil, fmt.Errorf("mapping of %q to %q is not valid - replaceFrom must not be empty", replaceFrom, replaceTo)
+		}
+		if !allowEmptyReplacements && replaceTo == "" {
+			return nil, fmt.Errorf("mapping of %q to %q is not valid - they must both be populated", replaceFrom, replaceTo)
+		}
+		replacementList = append(replacementList, replaceFrom, replaceTo)
+		replaceFromKeys = append(replaceFromKeys, replaceFrom)
+	}
+	// check if any replaceFrom is a substring of any other replaceFrom
+	if !allowSubstrings {
+		for _, k := range replaceFromKeys {
+			for _, j := range replaceFromKeys {
+				if k == j {
+					continue
+				}
+				if strings.Contains(k, j) {
+					return nil, fmt.Errorf("invalid replacement map: key %q contains key %q as a substring", k, j)
+				}
+			}
+		}
+	}
+	return strings.NewReplacer(replacementList...), nil
+}
+
+// convert seconds to HH:MM::SS
+func GetTimeStringFromSeconds(totalSeconds int) string {
+	duration := time.Duration(totalSeconds) * time.Second
+	hours := int(duration.Hours())
+	minutes := int(duration.Minutes()) % 60
+	seconds := int(duration.Seconds()) % 60
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+}
+
+func OpenUrlInBrowser(url string) error {
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		err = exec.Command("xdg-open", url).Start()
+	case "windows":
+		err = exec.Command("powershell", "-Command", fmt.Sprintf("Start-Process '%s'", url)).Start()
+	case "darwin":
+		err = exec.Command("open", url).Start()
+	default:
+		err = fmt.Errorf("unsupported platform - cannot open browser")
+	}
+	return err
+}
+
+func NumFieldsInStruct(val any) (int, error) {
+	t := reflect.TypeOf(val)
+	if t.Kind() == reflect.Struct {
+		return t.NumField(), nil
+	}
+	return -1, fmt.Errorf("%v is not a struct", val)
+}
+
+// only works for homogenous structs
+func GenerateMapFromHomogenousStruct[ParentType any, FieldValueType any](strct ParentType) map[string]FieldValueType {
+	fields := reflect.TypeOf(strct)
+	vals := reflect.ValueOf(strct)
+	res := map[string]FieldValueType{}
+	for idx := range vals.NumField() {
+		fieldName := fields.Field(idx).Name
+		value := vals.Field(idx).Interface().(FieldValueType)
+		res[fieldName] = value
+	}
+	return res
+}
+
+// Returns unique variable names.
+// Fields of the named ai-generated variable groups are referenced as Group.field - for any other variable,
+// a following .word is just text (e.g. $$StreamCategory.Live).
+func ExtractVariableNamesFromText(text string, groupNames []string) []string {
+	fmtStr := `%s(\w+)(\.\w+)?`
+	escapedPrefix := regexp.QuoteMeta(VarNamePlaceholderPrefix)
+	r := regexp.MustCompile(fmt.Sprintf(fmtStr, escapedPrefix))
+	matches := r.FindAllStringSubmatch(text, -1)
+	variableNamesMap := make(map[string]struct{}, len(matches))
+	for _, match := range matches {
+		name := match[1]
+		if match[2] != "" && slices.Contains(groupNames, name) {
+			name += match[2]
+		}
+		variableNamesMap[name] = struct{}{}
+	}
+	variableNamesSlice := make([]string, 0, len(variableNamesMap))
+	for v := range variableNamesMap {
+		variableNamesSlice = append(variableNamesSlice, v)
+	}
+	return variableNamesSlice
+}
+
+// Splits a comma-separated string, trimming whitespace and dropping empty items
+func SplitCommaSeparated(text string) []string {
+	items := []string{}
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Removes template sections containing any of the dropped placeholders, and unwraps all other sections.
+// Dropped placeholders which are not inside a section are removed on their own.
+func ResolveTemplateSections(template string, droppedPlaceholders []string) string {
+	placeholderRegexes := make([]*regexp.Regexp, 0, len(droppedPlaceholders))
+	for _, placeholder := range droppedPlaceholders {
+		placeholderRegexes = append(placeholderRegexes, regexp.MustCompile(regexp.QuoteMeta(placeholder)+`\b`))
+	}
+
+	sectionRegex := regexp.MustCompile(
+		`(?s)` + regexp.QuoteMeta(TemplateSectionStart) + `(.*?)` + regexp.QuoteMeta(TemplateSectionEnd),
+	)
+	resolved := sectionRegex.ReplaceAllStringFunc(template, func(section string) string {
+		inner := section[len(TemplateSectionStart) : len(section)-len(TemplateSectionEnd)]
+		for _, r := range placeholderRegexes {
+			if r.MatchString(inner) {
+				return ""
+			}
+		}
+		return inner
+	})
+
+	if len(placeholderRegexes) == 0 {
+		return resolved
+	}
+	for _, r := range placeholderRegexes {
+		resolved = r.ReplaceAllString(resolved, "")
+	}
+	// tidy up whitespace left behind by removed sections
+	return regexp.MustCompile(` {2,}`).ReplaceAllString(resolved, " ")
+}
+
+// Similarity between 0 (nothing in common) and 1 (identical), ignoring case and surrounding whitespace
+func NormalisedEditDistanceSimilarity(a string, b string) float64 {
+	aRunes := []rune(strings.ToLower(strings.TrimSpace(a)))
+	bRunes := []rune(strings.ToLower(strings.TrimSpace(b)))
+	maxLen := max(len(aRunes), len(bRunes))
+	if maxLen == 0 {
+		return 1
+	}
+	// levenshtein distance, keeping only the previous row
+	prevRow := make([]int, len(bRunes)+1)
+	for j := range prevRow {
+		prevRow[j] = j
+	}
+	for i := 1; i <= len(aRunes); i++ {
+		row := make([]int, len(bRunes)+1)
+		row[0] = i
+		for j := 1; j <= len(bRunes); j++ {
+			substitutionCost := 1
+			if aRunes[i-1] == bRunes[j-1] {
+				substitutionCost = 0
+			}
+			row[j] = min(prevRow[j]+1, row[j-1]+1, prevRow[j-1]+substitutionCost)
+		}
+		prevRow = row
+	}
+	return 1 - float64(prevRow[len(bRunes)])/float64(maxLen)
+}
+
+// Jaccard similarity of the character n-grams of both strings, between 0 and 1
+func NgramOverlapSimilarity(a string, b string, n int) float64 {
+	aNgrams := characterNgrams(a, n)
+	bNgrams := characterNgrams(b, n)
+	if len(aNgrams) == 0 && len(bNgrams) == 0 {
+		return 1
+	}
+	intersection := 0
+	for ngram := range aNgrams {
+		if _, exists := bNgrams[ngram]; exists {
+			intersection++
+		}
+	}
+	union := len(aNgrams) + len(bNgrams) - intersection
+	return float64(intersection) / float64(union)
+}
+
+func characterNgrams(text string, n int) map[string]struct{} {
+	runes := []rune(strings.Join(strings.Fields(strings.ToLower(text)), " "))
+	ngrams := map[string]struct{}{}
+	if len(runes) > 0 && len(runes) < n {
+		ngrams[string(runes)] = struct{}{}
+	}
+	for i := 0; i+n <= len(runes); i++ {
+		ngrams[string(runes[i:i+n])] = struct{}{}
+	}
+	return ngrams
+}
+
+// Converts tags to Twitch's rules - letters and numbers only, at most MaxTagLength characters,
+// and at most MaxTags tags. Empty and duplicate tags are dropped.
+func NormaliseTags(tags []string) []string {
+	normalisedTags := []string{}
+	seen := map[string]struct{}{}
+	for _, tag := range tags {
+		normalised := NormaliseTag(tag)
+		if normalised == "" {
+			continue
+		}
+		key := strings.ToLower(normalised)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		normalisedTags = append(normalisedTags, normalised)
+		if len(normalisedTags) == MaxTags {
+			break
+		}
+	}
+	return normalisedTags
+}
+
+// Removes spaces and special characters from a tag, then truncates it to MaxTagLength characters
+func NormaliseTag(tag string) string {
+	runes := []rune{}
+	for _, r := range tag {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	return string(runes[:min(len(runes), MaxTagLength)])
+}
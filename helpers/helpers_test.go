@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestExtractVariableNamesFromText(t *testing.T) {
+	names := ExtractVariableNamesFromText("$$Game.Live: $$Joke.setup - $$Joke.punchline ($$Game)", []string{"Joke"})
+	slices.Sort(names)
+	// only groups have fields, so .Live is not part of $$Game
+	if !slices.Equal(names, []string{"Game", "Joke.punchline", "Joke.setup"}) {
+		t.Errorf("unexpected variable names %q", names)
+	}
+}
+
+func TestResolveTemplateSections(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		dropped  []string
+		expected string
+	}{
+		{template: "$$Game[[ - $$Joke]]", dropped: nil, expected: "$$Game - $$Joke"},
+		{template: "$$Game[[ - $$Joke]]", dropped: []string{"$$Joke"}, expected: "$$Game"},
+		{template: "$$Game[[ - $$Joke]][[ ($$Fact)]]", dropped: []string{"$$Joke"}, expected: "$$Game ($$Fact)"},
+		// placeholders outside sections are removed on their own
+		{template: "$$Game $$Joke today", dropped: []string{"$$Joke"}, expected: "$$Game today"},
+		// a dropped placeholder does not drop placeholders it is a prefix of
+		{template: "$$Game[[ - $$Jokes]]", dropped: []string{"$$Joke"}, expected: "$$Game - $$Jokes"},
+	} {
+		if resolved := ResolveTemplateSections(tc.template, tc.dropped); resolved != tc.expected {
+			t.Errorf("%q with %q dropped: expected %q, got %q", tc.template, tc.dropped, tc.expected, resolved)
+		}
+	}
+}
+
+func TestGetStringReplacerFromMap(t *testing.T) {
+	replacer, err := GetStringReplacerFromMap(map[string]string{"$$Game": "Chess", "$$Joke": ""}, true, false)
+	if err != nil {
+		t.Fatalf("unable to get replacer - err: %v", err)
+	}
+	if replaced := replacer.Replace("$$Game$$Joke"); replaced != "Chess" {
+		t.Errorf("unexpected replacement %q", replaced)
+	}
+	if _, err := GetStringReplacerFromMap(map[string]string{"$$Joke": ""}, false, false); err == nil {
+		t.Error("expected empty replacements to be rejected")
+	}
+	if _, err := GetStringReplacerFromMap(map[string]string{"$$Game": "a", "$$GameName": "b"}, true, false); err == nil {
+		t.Error("expected keys which contain other keys to be rejected")
+	}
+}
+
+func TestSplitCommaSeparated(t *testing.T) {
+	if items := SplitCommaSeparated(" one, ,two ,, three "); !slices.Equal(items, []string{"one", "two", "three"}) {
+		t.Errorf("unexpected items %q", items)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if similarity := NormalisedEditDistanceSimilarity("Chess Night", " chess night "); similarity != 1 {
+		t.Errorf("expected identical text to have a similarity of 1, got %v", similarity)
+	}
+	if similarity := NormalisedEditDistanceSimilarity("abcd", "abcf"); similarity != 0.75 {
+		t.Errorf("expected one substitution in four characters to have a similarity of 0.75, got %v", similarity)
+	}
+	if similarity := NgramOverlapSimilarity("chess", "poker", 3); similarity != 0 {
+		t.Errorf("expected no shared ngrams to have a similarity of 0, got %v", similarity)
+	}
+}
+
+func TestNormaliseTags(t *testing.T) {
+	tags := []string{}
+	for i := range 12 {
+		tags = append(tags, fmt.Sprintf("tag %v", i))
+	}
+	tags = append([]string{"A very long tag that goes on and on", "Español"}, tags...)
+
+	normalised := NormaliseTags(tags)
+	if len(normalised) != MaxTags {
+		t.Fatalf("got %v tags, want %v", len(normalised), MaxTags)
+	}
+	if normalised[0] != "Averylongtagthatgoesonand" || normalised[1] != "Español" || normalised[2] != "tag0" {
+		t.Errorf("tags = %q, want the long tag truncated, accents kept and spaces removed", normalised)
+	}
+}
+
+func TestGetTimeStringFromSeconds(t *testing.T) {
+	if timeString := GetTimeStringFromSeconds(3*3600 + 25*60 + 7); timeString != "03:25:07" {
+		t.Errorf("unexpected time string %q", timeString)
+	}
+}
@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const maxCacheEntriesPerVariable = 20
+
+type cacheEntryT struct {
+	value        string
+	providerName string
+	category     string
+	generatedAt  time.Time
+}
+
+// Caches generated values, keyed on each variable's fully rendered prompt and its images (see CacheKey),
+// so that variables are only regenerated when their refresh policy requires it
+type ResponseCacheT struct {
+	mu      sync.Mutex
+	entries map[string]map[string]cacheEntryT // variable name -> cache key -> entry
+	latest  map[string]string                 // variable name -> most recently stored cache key
+}
+
+type CachedResponseT struct {
+	Value        string
+	ProviderName string
+	GeneratedAt  time.Time
+}
+
+func NewResponseCache() *ResponseCacheT {
+	return &ResponseCacheT{
+		entries: map[string]map[string]cacheEntryT{},
+		latest:  map[string]string{},
+	}
+}
+
+// Returns a cached value for the variable if its refresh policy does not require a new one
+func (c *ResponseCacheT) Lookup(variable config.LlmVariableT, key string, category string, now time.Time) (CachedResponseT, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entry cacheEntryT
+	var exists bool
+	switch variable.Refresh.Mode {
+	case config.RefreshModeInterval:
+		entry, exists = c.entries[variable.Name][c.latest[variable.Name]]
+		exists = exists && now.Sub(entry.generatedAt) < time.Duration(variable.Refresh.IntervalMinutes)*time.Minute
+	case config.RefreshModeCategoryChange:
+		entry, exists = c.entries[variable.Name][c.latest[variable.Name]]
+		exists = exists && entry.category == category
+	case config.RefreshModeInputChange:
+		entry, exists = c.entries[variable.Name][key]
+	default:
+		// RefreshModeEveryCycle
+		return CachedResponseT{}, false
+	}
+	if !exists {
+		return CachedResponseT{}, false
+	}
+	return CachedResponseT{Value: entry.value, ProviderName: entry.providerName, GeneratedAt: entry.generatedAt}, true
+}
+
+func (c *ResponseCacheT) Store(variableName string, key string, category string, value string, providerName string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	variableEntries, exists := c.entries[variableName]
+	if !exists {
+		variableEntries = map[string]cacheEntryT{}
+		c.entries[variableName] = variableEntries
+	}
+	variableEntries[key] = cacheEntryT{
+		value:        value,
+		providerName: providerName,
+		category:     category,
+		generatedAt:  now,
+	}
+	c.latest[variableName] = key
+
+	// evict the oldest entries, e.g. when a prompt contains a frequently changing viewer count
+	for len(variableEntries) > maxCacheEntriesPerVariable {
+		oldestPrompt := ""
+		for prompt, entry := range variableEntries {
+			if oldestPrompt == "" || entry.generatedAt.Before(variableEntries[oldestPrompt].generatedAt) {
+				oldestPrompt = prompt
+			}
+		}
+		delete(variableEntries, oldestPrompt)
+	}
+}
+
+// Keys a rendered prompt together with the images sent with it, so a new image counts as a new input
+func CacheKey(renderedPrompt string, images []ImageT) string {
+	key := renderedPrompt
+	for _, image := range images {
+		hash := sha256.Sum256(image.Data)
+		key += "\nimage:" + hex.EncodeToString(hash[:])
+	}
+	return key
+}
+
+// Removes all cached values for a variable, e.g. after its settings have been edited
+func (c *ResponseCacheT) Invalidate(variableName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, variableName)
+	delete(c.latest, variableName)
+}
@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+)
+
+// Returns the names of the other AI-generated variables referenced in a variable's prompts
+func GetVariableDependencies(variable config.LlmVariableT, variables []config.LlmVariableT) []string {
+	referencedNames := helpers.ExtractVariableNamesFromText(
+		variable.PromptMain+"\n"+variable.PromptSuffix, config.GetGroupNames(variables),
+	)
+	for idx, name := range referencedNames {
+		// fields of groups depend on the group as a whole
+		referencedNames[idx], _, _ = strings.Cut(name, config.GroupFieldSeparator)
+	}
+	dependencies := []string{}
+	for _, v := range variables {
+		if slices.Contains(referencedNames, v.Name) {
+			dependencies = append(dependencies, v.Name)
+		}
+	}
+	return dependencies
+}
+
+// Returns a dependency cycle between variables (e.g. [A B A]), or nil if there are none
+func FindDependencyCycle(variables []config.LlmVariableT) []string {
+	dependenciesMap := getDependenciesMap(variables)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := map[string]int{}
+	path := []string{}
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		states[name] = visiting
+		path = append(path, name)
+		for _, dependency := range dependenciesMap[name] {
+			switch states[dependency] {
+			case visiting:
+				cycleStart := slices.Index(path, dependency)
+				return append(slices.Clone(path[cycleStart:]), dependency)
+			case unvisited:
+				if cycle := visit(dependency); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		states[name] = visited
+		return nil
+	}
+
+	for _, v := range variables {
+		if states[v.Name] == unvisited {
+			if cycle := visit(v.Name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the names of the given variables and everything they depend on, grouped into layers.
+// Layers must be generated in order, but the variables within a layer are independent of each other.
+func GetGenerationLayers(variables []config.LlmVariableT, names []string) ([][]string, error) {
+	if cycle := FindDependencyCycle(variables); cycle != nil {
+		return nil, fmt.Errorf("variables have a dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+	dependenciesMap := getDependenciesMap(variables)
+
+	// every variable needed, directly or indirectly
+	required := map[string]struct{}{}
+	toVisit := slices.Clone(names)
+	for len(toVisit) > 0 {
+		name := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+		if _, exists := required[name]; exists {
+			continue
+		}
+		required[name] = struct{}{}
+		toVisit = append(toVisit, dependenciesMap[name]...)
+	}
+
+	layers := [][]string{}
+	generated := map[string]struct{}{}
+	for len(generated) < len(required) {
+		layer := []string{}
+		for name := range required {
+			if _, exists := generated[name]; exists {
+				continue
+			}
+			ready := true
+			for _, dependency := range dependenciesMap[name] {
+				if _, exists := generated[dependency]; !exists {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				layer = append(layer, name)
+			}
+		}
+		for _, name := range layer {
+			generated[name] = struct{}{}
+		}
+		slices.Sort(layer)
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+func getDependenciesMap(variables []config.LlmVariableT) map[string][]string {
+	dependenciesMap := make(map[string][]string, len(variables))
+	for _, v := range variables {
+		dependenciesMap[v.Name] = GetVariableDependencies(v, variables)
+	}
+	return dependenciesMap
+}
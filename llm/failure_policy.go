@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const MaxRetries = 5
+
+// The result of applying a failure policy, once all attempts at generating a variable have failed
+type FailureOutcomeT struct {
+	Value       string
+	DropSection bool
+	Description string
+}
+
+// Retries the whole provider chain according to the policy, backing off exponentially between attempts
+func GetResponseTextWithRetries(
+	ctx context.Context,
+	profiles []config.LlmProviderProfileT,
+	prompt PromptT,
+	timeoutDuration time.Duration,
+	policy config.LlmFailurePolicyT,
+) (string, string, error) {
+	maxRetries := min(max(policy.MaxRetries, 0), MaxRetries)
+	backoff := time.Duration(max(policy.RetryBackoffSeconds, 0)) * time.Second
+
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			config.Logger.LogInfof("retrying in %v (retry %v of %v)", backoff, attempt, maxRetries)
+			select {
+			case <-ctx.Done():
+				return "", "", fmt.Errorf("gave up retrying - err: %w", errors.Join(err, ctx.Err()))
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		var response, providerName string
+		response, providerName, err = GetResponseTextFromChain(profiles, prompt, timeoutDuration)
+		if err == nil {
+			return response, providerName, nil
+		}
+		if errors.Is(err, ErrBudgetExceeded) {
+			break // retrying will not help
+		}
+	}
+	return "", "", err
+}
+
+// Decides what to substitute for a variable which could not be generated.
+// Returns an error if the policy is to stop Tidal.
+func ApplyFailurePolicy(variable config.LlmVariableT, generationErr error) (FailureOutcomeT, error) {
+	policy := variable.FailurePolicy
+	if errors.Is(generationErr, ErrBudgetExceeded) &&
+		(policy.OnFailure == "" || policy.OnFailure == config.FailureActionStopTidal) {
+		// budget caps pause ai-generated variables, rather than stopping tidal
+		policy.OnFailure = config.FailureActionReuseLastValue
+	}
+	switch policy.OnFailure {
+	case config.FailureActionReuseLastValue:
+		if isSubstituteValid(variable, variable.Value) {
+			return FailureOutcomeT{Value: variable.Value, Description: "reusing last value"}, nil
+		}
+	case config.FailureActionFallbackValue:
+		if isSubstituteValid(variable, policy.FallbackValue) {
+			return FailureOutcomeT{Value: policy.FallbackValue, Description: "using fallback value"}, nil
+		}
+	case config.FailureActionBackupPool:
+		if len(policy.BackupPool) > 0 {
+			value := policy.BackupPool[rand.IntN(len(policy.BackupPool))]
+			if isSubstituteValid(variable, value) {
+				return FailureOutcomeT{Value: value, Description: "using a value from the backup pool"}, nil
+			}
+		}
+	case config.FailureActionDropSection:
+		// handled below
+	default:
+		return FailureOutcomeT{}, generationErr
+	}
+	// also reached if the chosen action has nothing to substitute
+	return FailureOutcomeT{DropSection: true, Description: "dropping its template section"}, nil
+}
+
+// Groups can only substitute values which are JSON objects containing all of their fields,
+// and tags can only substitute values containing at least one valid tag
+func isSubstituteValid(variable config.LlmVariableT, value string) bool {
+	if value == "" {
+		return false
+	}
+	if variable.IsGroup() {
+		_, err := ParseStructuredResponse(value, variable.Fields)
+		return err == nil
+	}
+	if variable.IsTags() {
+		return len(ParseTags(value)) > 0
+	}
+	return true
+}
@@ -1,45 +1,80 @@
-package llm
-
-import (
-	"context"
-	"time"
-
-	"google.golang.org/genai"
-)
-
-const geminiModel = "gemini-2.0-flash"
-
-type GoogleGeminiHandler struct {
-	client *genai.Client
-}
-
-func newGoogleGeminiHandler(apiKey string) (*GoogleGeminiHandler, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
-	if err != nil {
-		return nil, err
-	}
-	return &GoogleGeminiHandler{client}, nil
-}
-
-// func (h *GoogleGeminiHandler) BuildPrompt(promptParts []string) string {
-// 	return strings.Join(promptParts, "\n")
-// }
-
-func (h *GoogleGeminiHandler) GetResponseText(prompt string, timeoutDuration time.Duration) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
-	defer cancel()
-	result, err := h.client.Models.GenerateContent(
-		ctx,
-		geminiModel,
-		genai.Text(prompt),
-		nil,
-	)
-	if err != nil {
-		return "", err
-	}
-	return result.Text(), nil
-}
+package llm
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const defaultGeminiModel = "gemini-2.0-flash"
+
+type GoogleGeminiHandler struct {
+	client *genai.Client
+	model  string
+}
+
+func newGoogleGeminiHandler(apiKey string, model string) (*GoogleGeminiHandler, error) {
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  apiKey,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GoogleGeminiHandler{client, model}, nil
+}
+
+// func (h *GoogleGeminiHandler) BuildPrompt(promptParts []string) string {
+// 	return strings.Join(promptParts, "\n")
+// }
+
+func (h *GoogleGeminiHandler) GetResponseText(prompt PromptT, timeoutDuration time.Duration) (ResponseT, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	defer cancel()
+
+	var generateContentConfig *genai.GenerateContentConfig
+	if len(prompt.ResponseFields) > 0 {
+		// native json mode, constrained to the requested fields
+		properties := make(map[string]*genai.Schema, len(prompt.ResponseFields))
+		for _, field := range prompt.ResponseFields {
+			properties[field] = &genai.Schema{Type: genai.TypeString}
+		}
+		generateContentConfig = &genai.GenerateContentConfig{
+			ResponseMIMEType: "application/json",
+			ResponseSchema: &genai.Schema{
+				Type:             genai.TypeObject,
+				Properties:       properties,
+				Required:         prompt.ResponseFields,
+				PropertyOrdering: prompt.ResponseFields,
+			},
+		}
+	}
+
+	parts := []*genai.Part{genai.NewPartFromText(prompt.Text)}
+	for _, image := range prompt.Images {
+		parts = append(parts, genai.NewPartFromBytes(image.Data, image.MimeType))
+	}
+
+	result, err := h.client.Models.GenerateContent(
+		ctx,
+		h.model,
+		[]*genai.Content{genai.NewContentFromParts(parts, genai.RoleUser)},
+		generateContentConfig,
+	)
+	if err != nil {
+		return ResponseT{}, err
+	}
+	response := ResponseT{Text: result.Text()}
+	if result.UsageMetadata != nil {
+		response.Usage = UsageT{
+			InputTokens:  int(result.UsageMetadata.PromptTokenCount),
+			OutputTokens: int(result.UsageMetadata.CandidatesTokenCount + result.UsageMetadata.ThoughtsTokenCount),
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+)
+
+const (
+	MaxHistorySize = 20
+
+	ngramSize = 3
+)
+
+// Appends the variable's recent values to the prompt, asking the LLM not to repeat them
+func addHistoryToPrompt(prompt string, variable config.LlmVariableT) string {
+	if !variable.History.IncludeInPrompt || len(variable.RecentValues) == 0 {
+		return prompt
+	}
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\nDo not repeat, or closely resemble, any of these previous responses:")
+	for _, recentValue := range variable.RecentValues {
+		sb.WriteString("\n- " + recentValue)
+	}
+	return sb.String()
+}
+
+// Returns an error describing the most similar recent value, if any are above the similarity threshold
+func checkSimilarityToHistory(value string, variable config.LlmVariableT) error {
+	threshold := variable.History.SimilarityThreshold
+	if threshold <= 0 {
+		return nil
+	}
+	value = getHistoryText(variable, value)
+	for _, recentValue := range variable.RecentValues {
+		if similarity := Similarity(variable.History.SimilarityMethod, value, recentValue); similarity >= threshold {
+			return fmt.Errorf("it was too similar (%.0f%%) to a previous response: %q", similarity*100, recentValue)
+		}
+	}
+	return nil
+}
+
+func Similarity(method string, a string, b string) float64 {
+	if method == config.SimilarityMethodEditDistance {
+		return helpers.NormalisedEditDistanceSimilarity(a, b)
+	}
+	return helpers.NgramOverlapSimilarity(a, b, ngramSize)
+}
+
+// Returns the variable's recent values with the new value added, dropping the oldest beyond the history size
+func AppendToHistory(variable config.LlmVariableT, value string) []string {
+	size := min(variable.History.Size, MaxHistorySize)
+	if size <= 0 {
+		return []string{}
+	}
+	recentValues := append(append([]string{}, variable.RecentValues...), getHistoryText(variable, value))
+	if len(recentValues) > size {
+		recentValues = recentValues[len(recentValues)-size:]
+	}
+	return recentValues
+}
@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const maxImageBytes = 10 * 1024 * 1024
+
+// Loads an image from disk, such as a screenshot, to attach to a prompt
+func LoadImageFile(path string) (ImageT, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ImageT{}, fmt.Errorf("unable to find image %v - err: %w", path, err)
+	}
+	if info.Size() > maxImageBytes {
+		return ImageT{}, fmt.Errorf("image %v is larger than %v MB", path, maxImageBytes/1024/1024)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ImageT{}, fmt.Errorf("unable to read image %v - err: %w", path, err)
+	}
+	return NewImage(data)
+}
+
+// Wraps image data, detecting its mime type
+func NewImage(data []byte) (ImageT, error) {
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return ImageT{}, fmt.Errorf("data is not an image (detected %v)", mimeType)
+	}
+	return ImageT{Data: data, MimeType: mimeType}, nil
+}
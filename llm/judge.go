@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	maxJudgeScore    = 10
+	judgeUsageSource = "Title Judge"
+)
+
+// Asks an LLM to rate each candidate, returning scores between 0 and 1 in the same order
+func JudgeCandidates(
+	ctx context.Context,
+	profiles []config.LlmProviderProfileT,
+	judgePrompt string,
+	candidates []string,
+	timeoutDuration time.Duration,
+) ([]float64, error) {
+	fields := make([]string, 0, len(candidates))
+	var sb strings.Builder
+	sb.WriteString(judgePrompt + "\n")
+	for idx, candidate := range candidates {
+		field := fmt.Sprintf("candidate_%v", idx+1)
+		fields = append(fields, field)
+		sb.WriteString(fmt.Sprintf("\n%s: %s", field, candidate))
+	}
+	sb.WriteString(fmt.Sprintf(
+		"\n\nRespond only with a JSON object containing a score from 0 to %v for each of these fields: %s",
+		maxJudgeScore, strings.Join(fields, ", "),
+	))
+
+	response, _, err := GetResponseTextWithRetries(
+		ctx, profiles, PromptT{Text: sb.String(), ResponseFields: fields, Source: judgeUsageSource}, timeoutDuration, config.LlmFailurePolicyT{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get judge response - err: %w", err)
+	}
+	fieldValues, err := ParseStructuredResponse(response, fields)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse judge response %q - err: %w", response, err)
+	}
+	scores := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		score, err := strconv.ParseFloat(strings.TrimSpace(fieldValues[field]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("judge score for %v is not a number - err: %w", field, err)
+		}
+		scores = append(scores, min(max(score, 0), maxJudgeScore)/maxJudgeScore)
+	}
+	return scores, nil
+}
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	MaxKnowledgeTokens = 2000
+
+	charactersPerToken = 4 // rough estimate, good enough for budgeting snippets
+	minKeywordLength   = 3
+)
+
+var KnowledgeFileExtensions = []string{".md", ".txt"}
+
+var knowledgeStopWords = map[string]struct{}{
+	"the": {}, "and": {}, "for": {}, "are": {}, "but": {}, "not": {}, "you": {}, "your": {}, "with": {},
+	"this": {}, "that": {}, "from": {}, "they": {}, "was": {}, "were": {}, "have": {}, "has": {}, "had": {},
+	"will": {}, "would": {}, "should": {}, "can": {}, "could": {}, "does": {}, "did": {}, "its": {}, "into": {},
+	"about": {}, "than": {}, "then": {}, "them": {}, "what": {}, "which": {}, "who": {}, "when": {}, "where": {},
+	"response": {}, "ensure": {}, "characters": {}, "exceed": {}, "only": {}, "contain": {}, "text": {},
+}
+
+type KnowledgeDocumentT struct {
+	Name          string // file name within the knowledge base folder
+	Text          string
+	AlwaysInclude bool // its snippets are added before any others, even if the prompt does not mention them
+}
+
+type knowledgeSnippetT struct {
+	text          string
+	score         int
+	alwaysInclude bool
+	order         int // position across all documents, so snippets keep their original order
+}
+
+// Returns the names of all documents in the knowledge base folder
+func ListKnowledgeDocuments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read knowledge base folder %v - err: %w", dir, err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(KnowledgeFileExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// Reads the knowledge base's documents from the knowledge base folder
+func LoadKnowledgeDocuments(dir string, knowledge config.LlmKnowledgeT) ([]KnowledgeDocumentT, error) {
+	documents := make([]KnowledgeDocumentT, 0, len(knowledge.Documents))
+	for _, name := range knowledge.Documents {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.Base(name)))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read knowledge base document %q - err: %w", name, err)
+		}
+		documents = append(documents, KnowledgeDocumentT{
+			Name:          name,
+			Text:          string(data),
+			AlwaysInclude: slices.Contains(knowledge.AlwaysInclude, name),
+		})
+	}
+	return documents, nil
+}
+
+// Prepends the snippets of the documents most relevant to the prompt, within the token budget
+func AddKnowledgeToPrompt(prompt string, documents []KnowledgeDocumentT, maxTokens int) string {
+	snippets := selectKnowledgeSnippets(prompt, documents, min(maxTokens, MaxKnowledgeTokens))
+	if len(snippets) == 0 {
+		return prompt
+	}
+	var sb strings.Builder
+	sb.WriteString("Background knowledge about the channel, which you may use if relevant:\n")
+	for _, snippet := range snippets {
+		sb.WriteString(snippet + "\n")
+	}
+	sb.WriteString("\n" + prompt)
+	return sb.String()
+}
+
+// Ranks every snippet by the number of prompt keywords it contains, then fills the budget with the best.
+// Snippets of always included documents come first. Other snippets without any of the prompt's keywords
+// are irrelevant, so are never selected.
+func selectKnowledgeSnippets(prompt string, documents []KnowledgeDocumentT, maxTokens int) []string {
+	if maxTokens <= 0 {
+		return nil
+	}
+	promptKeywords := getKeywords(prompt)
+
+	snippets := []knowledgeSnippetT{}
+	for _, document := range documents {
+		for _, text := range splitIntoSnippets(document.Text) {
+			score := 0
+			for keyword := range getKeywords(text) {
+				if _, exists := promptKeywords[keyword]; exists {
+					score++
+				}
+			}
+			if score == 0 && !document.AlwaysInclude {
+				continue
+			}
+			snippets = append(snippets, knowledgeSnippetT{
+				text:          text,
+				score:         score,
+				alwaysInclude: document.AlwaysInclude,
+				order:         len(snippets),
+			})
+		}
+	}
+	slices.SortStableFunc(snippets, func(a, b knowledgeSnippetT) int {
+		if a.alwaysInclude != b.alwaysInclude {
+			if a.alwaysInclude {
+				return -1
+			}
+			return 1
+		}
+		return b.score - a.score
+	})
+
+	selected := []knowledgeSnippetT{}
+	remainingTokens := maxTokens
+	for _, snippet := range snippets {
+		tokens := estimateTokens(snippet.text)
+		if tokens > remainingTokens {
+			continue // a smaller snippet may still fit
+		}
+		selected = append(selected, snippet)
+		remainingTokens -= tokens
+	}
+	slices.SortFunc(selected, func(a, b knowledgeSnippetT) int {
+		return a.order - b.order
+	})
+
+	texts := make([]string, 0, len(selected))
+	for _, snippet := range selected {
+		texts = append(texts, snippet.text)
+	}
+	return texts
+}
+
+// Splits a document into paragraphs, keeping each markdown heading with the paragraph below it
+func splitIntoSnippets(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	snippets := []string{}
+	heading := ""
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if strings.HasPrefix(paragraph, "#") && !strings.Contains(paragraph, "\n") {
+			heading = paragraph
+			continue
+		}
+		if heading != "" {
+			paragraph = heading + "\n" + paragraph
+			heading = ""
+		}
+		snippets = append(snippets, paragraph)
+	}
+	return snippets
+}
+
+func getKeywords(text string) map[string]struct{} {
+	keywords := map[string]struct{}{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		if len([]rune(word)) < minKeywordLength {
+			continue
+		}
+		if _, isStopWord := knowledgeStopWords[word]; isStopWord {
+			continue
+		}
+		keywords[word] = struct{}{}
+	}
+	return keywords
+}
+
+func estimateTokens(text string) int {
+	return len([]rune(text))/charactersPerToken + 1
+}
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelectKnowledgeSnippets(t *testing.T) {
+	documents := []KnowledgeDocumentT{{
+		Name: "channel.md",
+		Text: "# Schedule\n\nChess on Mondays.\n\nSpeedruns on Fridays, with chess puzzles.\n\nThe cat is called Biscuit.",
+	}}
+
+	snippets := selectKnowledgeSnippets("Write a title for a chess stream", documents, MaxKnowledgeTokens)
+	// snippets without any prompt keywords are dropped, and the rest keep their original order
+	if !slices.Equal(snippets, []string{"# Schedule\nChess on Mondays.", "Speedruns on Fridays, with chess puzzles."}) {
+		t.Errorf("unexpected snippets %q", snippets)
+	}
+	if snippets := selectKnowledgeSnippets("Write a title for a poker stream", documents, MaxKnowledgeTokens); len(snippets) != 0 {
+		t.Errorf("expected no relevant snippets, got %q", snippets)
+	}
+}
+
+func TestSelectKnowledgeSnippetsAlwaysIncludesGeneralDocuments(t *testing.T) {
+	documents := []KnowledgeDocumentT{
+		{Name: "lore.md", Text: "The streamer lives on a boat.\n\nNever mention the weather.", AlwaysInclude: true},
+		{Name: "games.md", Text: "Chess on Mondays.\n\nPoker on Fridays."},
+	}
+
+	// general lore is added even when the prompt does not mention it, before the relevant snippets
+	snippets := selectKnowledgeSnippets("Write a title for a poker stream", documents, MaxKnowledgeTokens)
+	if !slices.Equal(snippets, []string{"The streamer lives on a boat.", "Never mention the weather.", "Poker on Fridays."}) {
+		t.Errorf("unexpected snippets %q", snippets)
+	}
+	snippets = selectKnowledgeSnippets("Write a funny title", documents, MaxKnowledgeTokens)
+	if !slices.Equal(snippets, []string{"The streamer lives on a boat.", "Never mention the weather."}) {
+		t.Errorf("expected only the general lore for an unrelated prompt, got %q", snippets)
+	}
+
+	// always included snippets take the budget before relevant ones
+	if snippets := selectKnowledgeSnippets("Write a title for a poker stream", documents, 10); slices.Contains(snippets, "Poker on Fridays.") {
+		t.Errorf("expected the general lore to use up the budget, got %q", snippets)
+	}
+}
@@ -1,29 +1,85 @@
-package llm
-
-import (
-	"fmt"
-	"time"
-)
-
-var LlmProviders = []string{"Google Gemini"}
-
-type LLMHandler interface {
-	// BuildPrompt([]string) string
-	GetResponseText(string, time.Duration) (string, error)
-}
-
-func NewLlmHandler(provider string, apiKey string) (LLMHandler, error) {
-	var handler LLMHandler
-	var err error
-
-	switch provider {
-	case "Google Gemini":
-		handler, err = newGoogleGeminiHandler(apiKey)
-		if err != nil {
-			return nil, fmt.Errorf("unable to create GoogleGeminiHandler - err: %w", err)
-		}
-	default:
-		return nil, fmt.Errorf("%v is not a valid LLM provider", provider)
-	}
-	return handler, nil
-}
+package llm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	ProviderGoogleGemini = "Google Gemini"
+	ProviderOllama       = "Ollama"
+	ProviderMock         = "Mock"
+)
+
+var LlmProviders = []string{ProviderGoogleGemini, ProviderOllama, ProviderMock}
+
+// A prompt to send to an LLM provider
+type PromptT struct {
+	Text           string
+	ResponseFields []string // if set, the response should be a JSON object with a string for each field
+	Source         string   // what the prompt is for (e.g. a variable name), used to track usage
+	Images         []ImageT // sent alongside the text, for providers and models which accept images
+}
+
+type ImageT struct {
+	Data     []byte
+	MimeType string // e.g. image/jpeg
+}
+
+type ResponseT struct {
+	Text  string
+	Usage UsageT
+}
+
+// Tokens used by a single request
+type UsageT struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+type LLMHandler interface {
+	// BuildPrompt([]string) string
+	GetResponseText(PromptT, time.Duration) (ResponseT, error)
+}
+
+// Name of the model a profile uses, as listed in the price table
+func GetModelName(profile config.LlmProviderProfileT) string {
+	if profile.Model != "" {
+		return profile.Model
+	}
+	switch profile.Provider {
+	case ProviderGoogleGemini:
+		return defaultGeminiModel
+	case ProviderMock:
+		return defaultMockModel
+	}
+	return profile.Model
+}
+
+func NewLlmHandler(profile config.LlmProviderProfileT) (LLMHandler, error) {
+	var handler LLMHandler
+	var err error
+
+	switch profile.Provider {
+	case ProviderGoogleGemini:
+		handler, err = newGoogleGeminiHandler(profile.ApiKey, profile.Model)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create GoogleGeminiHandler - err: %w", err)
+		}
+	case ProviderOllama:
+		handler, err = newOllamaHandler(profile.BaseUrl, profile.Model)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create OllamaHandler - err: %w", err)
+		}
+	case ProviderMock:
+		handler, err = newMockHandler(profile.ScriptPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create MockHandler - err: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("%v is not a valid LLM provider", profile.Provider)
+	}
+	return handler, nil
+}
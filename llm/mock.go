@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMockModel    = "mock"
+	defaultMockTemplate = "Mock response {{call}} for {{source}}"
+
+	MockModeRoundRobin = "round_robin"
+	MockModeRegex      = "regex"
+	MockModeEcho       = "echo"
+)
+
+var errMockFailure = errors.New("simulated mock provider failure")
+
+// Scripted behaviour of the mock provider, loaded from a JSON file
+type mockScriptT struct {
+	Mode      string       `json:"mode"`
+	Responses []string     `json:"responses"` // used in turn by round_robin, and by regex when no rule matches
+	Rules     []mockRuleT  `json:"rules"`     // regex only - the first rule whose pattern matches the prompt is used
+	Template  string       `json:"template"`  // echo only - may contain {{prompt}}, {{source}} and {{call}}
+	Latency   mockLatencyT `json:"latency"`
+	Errors    mockErrorsT  `json:"errors"`
+	Seed      uint64       `json:"seed"` // makes jitter and random errors repeatable
+	compiled  []*regexp.Regexp
+}
+
+type mockRuleT struct {
+	Pattern  string `json:"pattern"`
+	Response string `json:"response"` // may reference capture groups, e.g. $1
+}
+
+type mockLatencyT struct {
+	Milliseconds       int `json:"milliseconds"`
+	JitterMilliseconds int `json:"jitter_milliseconds"`
+}
+
+type mockErrorsT struct {
+	EveryNthCall int     `json:"every_nth_call"` // e.g. 3 fails the 3rd, 6th, 9th... call
+	Rate         float64 `json:"rate"`           // between 0 and 1
+	Message      string  `json:"message"`
+}
+
+// Call counts and random numbers are shared by every handler using the same script,
+// so round-robin responses carry on across requests
+type mockStateT struct {
+	mu    sync.Mutex
+	calls int
+	rng   *rand.Rand
+}
+
+var (
+	mockStates   = map[string]*mockStateT{} // script path -> state
+	mockStatesMu sync.Mutex
+)
+
+// Returns scripted responses without calling a real LLM, for testing and demos
+type MockHandler struct {
+	script mockScriptT
+	state  *mockStateT
+}
+
+func newMockHandler(scriptPath string) (*MockHandler, error) {
+	script := mockScriptT{Mode: MockModeEcho, Template: defaultMockTemplate}
+	if scriptPath != "" {
+		data, err := os.ReadFile(scriptPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read mock script %v - err: %w", scriptPath, err)
+		}
+		if err := json.Unmarshal(data, &script); err != nil {
+			return nil, fmt.Errorf("unable to parse mock script %v - err: %w", scriptPath, err)
+		}
+	}
+	if err := script.validate(); err != nil {
+		return nil, fmt.Errorf("mock script %v is not valid - err: %w", scriptPath, err)
+	}
+
+	mockStatesMu.Lock()
+	defer mockStatesMu.Unlock()
+	state, exists := mockStates[scriptPath]
+	if !exists {
+		state = &mockStateT{rng: rand.New(rand.NewPCG(script.Seed, script.Seed))}
+		mockStates[scriptPath] = state
+	}
+	return &MockHandler{script: script, state: state}, nil
+}
+
+// Clears the call counts of every mock script, so that scripts start again from their first response
+func ResetMockProviders() {
+	mockStatesMu.Lock()
+	defer mockStatesMu.Unlock()
+	mockStates = map[string]*mockStateT{}
+}
+
+func (s *mockScriptT) validate() error {
+	switch s.Mode {
+	case MockModeRoundRobin:
+		if len(s.Responses) == 0 {
+			return errors.New("round_robin requires at least one response")
+		}
+	case MockModeRegex:
+		for _, rule := range s.Rules {
+			r, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q - err: %w", rule.Pattern, err)
+			}
+			s.compiled = append(s.compiled, r)
+		}
+		if len(s.Rules) == 0 && len(s.Responses) == 0 {
+			return errors.New("regex requires at least one rule or response")
+		}
+	case MockModeEcho, "":
+		s.Mode = MockModeEcho
+		if s.Template == "" {
+			s.Template = defaultMockTemplate
+		}
+	default:
+		return fmt.Errorf("%q is not a valid mode - use %s, %s or %s", s.Mode, MockModeRoundRobin, MockModeRegex, MockModeEcho)
+	}
+	if s.Errors.Rate < 0 || s.Errors.Rate > 1 {
+		return fmt.Errorf("error rate %v must be between 0 and 1", s.Errors.Rate)
+	}
+	return nil
+}
+
+func (h *MockHandler) GetResponseText(prompt PromptT, timeoutDuration time.Duration) (ResponseT, error) {
+	h.state.mu.Lock()
+	h.state.calls++
+	call := h.state.calls
+	latency := time.Duration(h.script.Latency.Milliseconds) * time.Millisecond
+	if h.script.Latency.JitterMilliseconds > 0 {
+		latency += time.Duration(h.state.rng.IntN(h.script.Latency.JitterMilliseconds+1)) * time.Millisecond
+	}
+	fail := (h.script.Errors.EveryNthCall > 0 && call%h.script.Errors.EveryNthCall == 0) ||
+		(h.script.Errors.Rate > 0 && h.state.rng.Float64() < h.script.Errors.Rate)
+	h.state.mu.Unlock()
+
+	if latency > timeoutDuration {
+		time.Sleep(timeoutDuration)
+		return ResponseT{}, fmt.Errorf("mock response took longer than %v", timeoutDuration)
+	}
+	time.Sleep(latency)
+
+	if fail {
+		if h.script.Errors.Message != "" {
+			return ResponseT{}, fmt.Errorf("%w - %s", errMockFailure, h.script.Errors.Message)
+		}
+		return ResponseT{}, errMockFailure
+	}
+
+	text := h.getScriptedText(prompt, call)
+	if len(prompt.ResponseFields) > 0 {
+		text = wrapMockResponseInFields(text, prompt.ResponseFields)
+	}
+	return ResponseT{
+		Text:  text,
+		Usage: UsageT{InputTokens: estimateTokens(prompt.Text), OutputTokens: estimateTokens(text)},
+	}, nil
+}
+
+func (h *MockHandler) getScriptedText(prompt PromptT, call int) string {
+	switch h.script.Mode {
+	case MockModeRoundRobin:
+		return h.script.Responses[(call-1)%len(h.script.Responses)]
+	case MockModeRegex:
+		for idx, r := range h.script.compiled {
+			match := r.FindStringSubmatchIndex(prompt.Text)
+			if match != nil {
+				return string(r.ExpandString(nil, h.script.Rules[idx].Response, prompt.Text, match))
+			}
+		}
+		if len(h.script.Responses) == 0 {
+			return ""
+		}
+		return h.script.Responses[(call-1)%len(h.script.Responses)]
+	default:
+		return strings.NewReplacer(
+			"{{prompt}}", prompt.Text,
+			"{{source}}", prompt.Source,
+			"{{call}}", strconv.Itoa(call),
+		).Replace(h.script.Template)
+	}
+}
+
+// Scripted responses which are already JSON objects are returned as they are,
+// otherwise every field is given the scripted text
+func wrapMockResponseInFields(text string, fields []string) string {
+	if _, err := ParseStructuredResponse(text, fields); err == nil {
+		return text
+	}
+	fieldValues := make(map[string]string, len(fields))
+	for _, field := range fields {
+		fieldValues[field] = text
+	}
+	return EncodeFieldValues(fieldValues)
+}
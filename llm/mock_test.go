@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+// Writes a mock provider script, returning a profile which uses it
+func newMockProfile(t *testing.T, name string, script string) config.LlmProviderProfileT {
+	t.Helper()
+	ResetMockProviders()
+	scriptPath := filepath.Join(t.TempDir(), name+".json")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return config.LlmProviderProfileT{Name: name, Provider: ProviderMock, ScriptPath: scriptPath}
+}
+
+func getMockResponses(t *testing.T, profile config.LlmProviderProfileT, prompts ...string) []string {
+	t.Helper()
+	handler, err := NewLlmHandler(profile)
+	if err != nil {
+		t.Fatalf("unable to create mock handler - err: %v", err)
+	}
+	responses := []string{}
+	for _, prompt := range prompts {
+		response, err := handler.GetResponseText(PromptT{Text: prompt, Source: "Test"}, time.Second)
+		if err != nil {
+			responses = append(responses, "error")
+			continue
+		}
+		responses = append(responses, response.Text)
+	}
+	return responses
+}
+
+func TestMockModes(t *testing.T) {
+	roundRobin := newMockProfile(t, "round_robin", `{"mode": "round_robin", "responses": ["one", "two"]}`)
+	if responses := getMockResponses(t, roundRobin, "a", "b", "c"); !slices.Equal(responses, []string{"one", "two", "one"}) {
+		t.Errorf("unexpected round_robin responses %q", responses)
+	}
+
+	regex := newMockProfile(t, "regex", `{
+		"mode": "regex",
+		"rules": [{"pattern": "about (\\w+)", "response": "all about $1"}],
+		"responses": ["no match"]
+	}`)
+	if responses := getMockResponses(t, regex, "talk about chess", "say hi"); !slices.Equal(responses, []string{"all about chess", "no match"}) {
+		t.Errorf("unexpected regex responses %q", responses)
+	}
+
+	echo := newMockProfile(t, "echo", `{"mode": "echo", "template": "{{call}}: {{prompt}} for {{source}}"}`)
+	if responses := getMockResponses(t, echo, "hello"); !slices.Equal(responses, []string{"1: hello for Test"}) {
+		t.Errorf("unexpected echo responses %q", responses)
+	}
+
+	failing := newMockProfile(t, "failing", `{"mode": "round_robin", "responses": ["ok"], "errors": {"every_nth_call": 2}}`)
+	if responses := getMockResponses(t, failing, "a", "b", "c", "d"); !slices.Equal(responses, []string{"ok", "error", "ok", "error"}) {
+		t.Errorf("unexpected responses with errors %q", responses)
+	}
+}
+
+func TestMockLatency(t *testing.T) {
+	slow := newMockProfile(t, "slow", `{"mode": "round_robin", "responses": ["late"], "latency": {"milliseconds": 200}}`)
+	handler, err := NewLlmHandler(slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.GetResponseText(PromptT{Text: "a"}, 20*time.Millisecond); err == nil {
+		t.Error("expected a response slower than the timeout to fail")
+	}
+	if response, err := handler.GetResponseText(PromptT{Text: "a"}, time.Second); err != nil || response.Text != "late" {
+		t.Errorf("expected a response within the timeout, got %q - err: %v", response.Text, err)
+	}
+}
+
+func TestMockScriptValidation(t *testing.T) {
+	for _, script := range []string{
+		`{"mode": "round_robin"}`,
+		`{"mode": "regex", "rules": [{"pattern": "("}]}`,
+		`{"mode": "unknown"}`,
+		`{"mode": "echo", "errors": {"rate": 2}}`,
+	} {
+		if _, err := NewLlmHandler(newMockProfile(t, "invalid", script)); err == nil {
+			t.Errorf("expected script %s to be invalid", script)
+		}
+	}
+}
+
+func TestGenerateVariableValueRepromptsInvalidResponses(t *testing.T) {
+	profile := newMockProfile(t, "mock", `{"mode": "round_robin", "responses": ["far too long a response", "short"]}`)
+	result, err := GenerateVariableValue(context.Background(), GenerationRequestT{
+		Variable: config.LlmVariableT{
+			Name:   "Short",
+			Output: config.LlmOutputConfigT{MaxCharacters: 10, MaxAttempts: 2},
+		},
+		Profiles: []config.LlmProviderProfileT{profile},
+		Prompt:   "Be brief",
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unable to generate value - err: %v", err)
+	}
+	if result.Value != "short" || result.ProviderName != "mock" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestGenerateVariableValueForGroupsAndTags(t *testing.T) {
+	group := newMockProfile(t, "group", `{"mode": "round_robin", "responses": ["Sure! {\"setup\": \"Why?\", \"punchline\": \"Because.\"}"]}`)
+	result, err := GenerateVariableValue(context.Background(), GenerationRequestT{
+		Variable: config.LlmVariableT{Name: "Joke", Fields: []string{"setup", "punchline"}},
+		Profiles: []config.LlmProviderProfileT{group},
+		Prompt:   "Tell a joke",
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unable to generate group - err: %v", err)
+	}
+	fieldValues, err := ParseStructuredResponse(result.Value, []string{"setup", "punchline"})
+	if err != nil || fieldValues["setup"] != "Why?" || fieldValues["punchline"] != "Because." {
+		t.Errorf("unexpected group value %q - err: %v", result.Value, err)
+	}
+
+	tags := newMockProfile(t, "tags", `{"mode": "round_robin", "responses": ["#Speed Run, cozy\nchess, Cozy"]}`)
+	result, err = GenerateVariableValue(context.Background(), GenerationRequestT{
+		Variable: config.LlmVariableT{Name: "Tags", Mode: config.VariableModeTags},
+		Profiles: []config.LlmProviderProfileT{tags},
+		Prompt:   "Suggest tags",
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unable to generate tags - err: %v", err)
+	}
+	if result.Value != "SpeedRun, cozy, chess" {
+		t.Errorf("unexpected tags %q", result.Value)
+	}
+}
+
+func TestGenerateVariableValueFallsBackThroughChain(t *testing.T) {
+	failing := newMockProfile(t, "failing", `{"mode": "round_robin", "responses": ["unused"], "errors": {"every_nth_call": 1, "message": "down"}}`)
+	working := newMockProfile(t, "working", `{"mode": "round_robin", "responses": ["backup"]}`)
+	result, err := GenerateVariableValue(context.Background(), GenerationRequestT{
+		Variable: config.LlmVariableT{Name: "Chain"},
+		Profiles: []config.LlmProviderProfileT{failing, working},
+		Prompt:   "Say something",
+		Timeout:  time.Second,
+	})
+	if err != nil || result.Value != "backup" || result.ProviderName != "working" {
+		t.Errorf("expected the next provider in the chain to be used, got %+v - err: %v", result, err)
+	}
+
+	_, err = GenerateVariableValue(context.Background(), GenerationRequestT{
+		Variable: config.LlmVariableT{Name: "Chain"},
+		Profiles: []config.LlmProviderProfileT{failing},
+		Prompt:   "Say something",
+		Timeout:  time.Second,
+	})
+	if !errors.Is(err, errMockFailure) || !strings.Contains(err.Error(), "down") {
+		t.Errorf("expected the mock failure, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOllamaBaseUrl = "http://localhost:11434"
+	maxErrorBodyBytes    = 4096
+)
+
+type OllamaHandler struct {
+	generateUrl string
+	model       string
+}
+
+type ollamaGenerateRequestT struct {
+	Model  string   `json:"model"`
+	Prompt string   `json:"prompt"`
+	Stream bool     `json:"stream"`
+	Format any      `json:"format,omitempty"` // json schema for structured outputs
+	Images []string `json:"images,omitempty"` // base64-encoded, for multimodal models
+}
+
+type ollamaGenerateResponseT struct {
+	Response        string `json:"response"`
+	Error           string `json:"error"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+func newOllamaHandler(baseUrl string, model string) (*OllamaHandler, error) {
+	if model == "" {
+		return nil, errors.New("a model must be specified for Ollama")
+	}
+	if baseUrl == "" {
+		baseUrl = defaultOllamaBaseUrl
+	}
+	return &OllamaHandler{
+		generateUrl: strings.TrimSuffix(baseUrl, "/") + "/api/generate",
+		model:       model,
+	}, nil
+}
+
+func (h *OllamaHandler) GetResponseText(prompt PromptT, timeoutDuration time.Duration) (ResponseT, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
+	defer cancel()
+
+	reqBody := ollamaGenerateRequestT{
+		Model:  h.model,
+		Prompt: prompt.Text,
+		Stream: false,
+	}
+	for _, image := range prompt.Images {
+		reqBody.Images = append(reqBody.Images, base64.StdEncoding.EncodeToString(image.Data))
+	}
+	if len(prompt.ResponseFields) > 0 {
+		properties := make(map[string]any, len(prompt.ResponseFields))
+		for _, field := range prompt.ResponseFields {
+			properties[field] = map[string]string{"type": "string"}
+		}
+		reqBody.Format = map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   prompt.ResponseFields,
+		}
+	}
+
+	reqBodyJson, err := json.Marshal(reqBody)
+	if err != nil {
+		return ResponseT{}, fmt.Errorf("unable to parse reqBody - err: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", h.generateUrl, bytes.NewBuffer(reqBodyJson))
+	if err != nil {
+		return ResponseT{}, fmt.Errorf("unable to construct request for %v - err: %w", h.generateUrl, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ResponseT{}, fmt.Errorf("request for %v failed - err: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// error responses are not always json, e.g. from a proxy in front of ollama
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		var errResult ollamaGenerateResponseT
+		if err := json.Unmarshal(body, &errResult); err == nil && errResult.Error != "" {
+			return ResponseT{}, fmt.Errorf("ollama returned http status %v - err: %v", resp.Status, errResult.Error)
+		}
+		return ResponseT{}, fmt.Errorf("ollama returned http status %v - err: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result ollamaGenerateResponseT
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ResponseT{}, fmt.Errorf("unable to decode response from request to %v - err: %w", req.URL, err)
+	}
+	return ResponseT{
+		Text:  result.Response,
+		Usage: UsageT{InputTokens: result.PromptEvalCount, OutputTokens: result.EvalCount},
+	}, nil
+}
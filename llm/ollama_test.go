@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOllamaErrorResponses(t *testing.T) {
+	for _, tc := range []struct {
+		body     string
+		expected string
+	}{
+		{body: `{"error": "model \"missing\" not found"}`, expected: `model "missing" not found`},
+		// e.g. from a proxy in front of ollama
+		{body: "<html>Bad Gateway</html>", expected: "<html>Bad Gateway</html>"},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, tc.body)
+		}))
+		handler, err := newOllamaHandler(server.URL, "missing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = handler.GetResponseText(PromptT{Text: "hello"}, time.Second)
+		if err == nil || !strings.Contains(err.Error(), "502") || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("expected the status and %q in the error, got %v", tc.expected, err)
+		}
+		server.Close()
+	}
+}
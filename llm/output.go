@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/moderation"
+)
+
+const MaxOutputAttempts = 5
+
+var (
+	errRejectedByModeration = errors.New("response was rejected by moderation")
+
+	wrappingQuotePairs = [][2]string{{`"`, `"`}, {`'`, `'`}, {"“", "”"}, {"‘", "’"}, {"`", "`"}, {"«", "»"}}
+
+	markdownLinkRegex       = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownEmphasisRegex   = regexp.MustCompile("(\\*\\*|__|\\*|~~|`)")
+	markdownLinePrefixRegex = regexp.MustCompile(`(?m)^\s*(#{1,6}\s+|[-*+]\s+|>\s*)`)
+	preambleRegex           = regexp.MustCompile(`(?i)^\s*((sure|okay|ok|of course|certainly)[!,.]*\s*)?(here(’|')?s|here is|here are)\b[^:\n]*:\s*`)
+	newlinesRegex           = regexp.MustCompile(`\s*\n+\s*`)
+)
+
+// Everything needed to generate the value of a single AI-generated variable
+type GenerationRequestT struct {
+	Variable   config.LlmVariableT
+	Profiles   []config.LlmProviderProfileT
+	Prompt     string
+	Images     []ImageT
+	Timeout    time.Duration
+	Moderation config.ModerationConfigT
+}
+
+type GenerationResultT struct {
+	Value        string
+	ProviderName string
+}
+
+// Generates a value for an AI-generated variable, applying its output processing, validation, moderation
+// and repetition checks. If any of these fail, the LLM is re-prompted with the violation explained.
+// The value of a group is a JSON object containing each of its fields, and the value of tags is a comma-separated list.
+func GenerateVariableValue(ctx context.Context, req GenerationRequestT) (GenerationResultT, error) {
+	maxAttempts := min(max(req.Variable.Output.MaxAttempts, 1), MaxOutputAttempts)
+
+	basePrompt := BuildPrompt(req.Prompt, req.Variable)
+	attemptPrompt := basePrompt
+	var violation error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		response, providerName, err := GetResponseTextWithRetries(
+			ctx, req.Profiles, PromptT{Text: attemptPrompt, ResponseFields: req.Variable.Fields, Source: req.Variable.Name, Images: req.Images},
+			req.Timeout, req.Variable.FailurePolicy,
+		)
+		if err != nil {
+			return GenerationResultT{}, err
+		}
+
+		var value string
+		value, _, violation = CheckResponse(response, req)
+		if errors.Is(violation, errRejectedByModeration) {
+			return GenerationResultT{}, violation
+		}
+		if violation == nil {
+			return GenerationResultT{Value: value, ProviderName: providerName}, nil
+		}
+		config.Logger.LogInfof("response %q for %v failed validation (attempt %v of %v) - %v", value, req.Variable.Name, attempt, maxAttempts, violation)
+		attemptPrompt = fmt.Sprintf(
+			"%s\n\nYour previous response was:\n%s\nIt was rejected because %v. Respond again, fixing this.",
+			basePrompt, value, violation,
+		)
+	}
+	return GenerationResultT{}, fmt.Errorf("response failed validation after %v attempts - err: %w", maxAttempts, violation)
+}
+
+// Adds the variable's recent values and, for groups and tags, the output instructions to a rendered prompt
+func BuildPrompt(prompt string, variable config.LlmVariableT) string {
+	prompt = addHistoryToPrompt(prompt, variable)
+	if variable.IsGroup() {
+		prompt = addStructuredOutputInstructions(prompt, variable.Fields)
+	} else if variable.IsTags() {
+		prompt = addTagsInstructions(prompt)
+	}
+	return prompt
+}
+
+// Processes, moderates and validates a raw response, returning the resulting value, whether it matched any
+// moderation terms (even if they were masked), and a violation if it must be regenerated.
+// The violation wraps errRejectedByModeration if it must not be regenerated.
+func CheckResponse(response string, req GenerationRequestT) (string, bool, error) {
+	if req.Variable.IsTags() {
+		value, moderationHit, violation := checkText(response, req)
+		if violation != nil {
+			return value, moderationHit, violation
+		}
+		tags := ParseTags(value)
+		if len(tags) == 0 {
+			return value, moderationHit, errNoValidTags
+		}
+		value = EncodeTags(tags)
+		return value, moderationHit, checkSimilarityToHistory(value, req.Variable)
+	}
+	if !req.Variable.IsGroup() {
+		value, moderationHit, violation := checkText(response, req)
+		if violation != nil {
+			return value, moderationHit, violation
+		}
+		return value, moderationHit, checkSimilarityToHistory(value, req.Variable)
+	}
+
+	fieldValues, err := ParseStructuredResponse(response, req.Variable.Fields)
+	if err != nil {
+		return strings.TrimSpace(response), false, err
+	}
+	moderationHit := false
+	for _, field := range req.Variable.Fields {
+		fieldValue, fieldModerationHit, violation := checkText(fieldValues[field], req)
+		fieldValues[field] = fieldValue
+		moderationHit = moderationHit || fieldModerationHit
+		if violation != nil {
+			return EncodeFieldValues(fieldValues), moderationHit, fmt.Errorf("its %q field was invalid - %w", field, violation)
+		}
+	}
+	value := EncodeFieldValues(fieldValues)
+	return value, moderationHit, checkSimilarityToHistory(value, req.Variable)
+}
+
+// Processes, moderates and validates a single piece of generated text
+func checkText(text string, req GenerationRequestT) (string, bool, error) {
+	processed := ProcessResponse(text, req.Variable.Output)
+	moderationResult := moderation.Check(processed, req.Moderation)
+	moderationHit := len(moderationResult.Matches) > 0
+	switch moderationResult.Action {
+	case config.ModerationActionReject:
+		return processed, moderationHit, fmt.Errorf("%w - matched %q", errRejectedByModeration, moderationResult.MatchedTerms())
+	case config.ModerationActionRegenerate:
+		return processed, moderationHit, fmt.Errorf("it contained blocked terms (%s)", strings.Join(moderationResult.MatchedTerms(), ", "))
+	}
+	processed = moderationResult.Text
+	return processed, moderationHit, ValidateResponse(processed, req.Variable.Output)
+}
+
+// Cleans up a raw LLM response according to the output config
+func ProcessResponse(response string, outputConfig config.LlmOutputConfigT) string {
+	response = strings.TrimSpace(response)
+	if outputConfig.StripMarkdown {
+		response = markdownLinkRegex.ReplaceAllString(response, "$1")
+		response = markdownLinePrefixRegex.ReplaceAllString(response, "")
+		response = markdownEmphasisRegex.ReplaceAllString(response, "")
+		response = strings.TrimSpace(response)
+	}
+	if outputConfig.StripPreamble {
+		response = strings.TrimSpace(preambleRegex.ReplaceAllString(response, ""))
+	}
+	if outputConfig.CollapseNewlines {
+		response = newlinesRegex.ReplaceAllString(response, " ")
+	}
+	if outputConfig.StripWrappingQuotes {
+		response = stripWrappingQuotes(response)
+	}
+	return response
+}
+
+// Returns a description of why a processed response is not valid, or nil if it is
+func ValidateResponse(response string, outputConfig config.LlmOutputConfigT) error {
+	if response == "" {
+		return fmt.Errorf("the response was empty")
+	}
+	if numChars := utf8.RuneCountInString(response); outputConfig.MaxCharacters > 0 && numChars > outputConfig.MaxCharacters {
+		return fmt.Errorf("it was %v characters long, but must not exceed %v characters", numChars, outputConfig.MaxCharacters)
+	}
+	if outputConfig.ValidationRegex != "" {
+		r, err := regexp.Compile(outputConfig.ValidationRegex)
+		if err != nil {
+			return fmt.Errorf("the validation pattern %q is invalid - err: %w", outputConfig.ValidationRegex, err)
+		}
+		if !r.MatchString(response) {
+			return fmt.Errorf("it did not match the required pattern %q", outputConfig.ValidationRegex)
+		}
+	}
+	return nil
+}
+
+func stripWrappingQuotes(response string) string {
+	for stripped := true; stripped; {
+		stripped = false
+		for _, pair := range wrappingQuotePairs {
+			if len(response) >= len(pair[0])+len(pair[1]) &&
+				strings.HasPrefix(response, pair[0]) && strings.HasSuffix(response, pair[1]) {
+				response = strings.TrimSpace(response[len(pair[0]) : len(response)-len(pair[1])])
+				stripped = true
+			}
+		}
+	}
+	return response
+}
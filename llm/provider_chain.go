@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+// Returns the profiles to try (in order) for an AI-generated variable.
+// Variables without a provider chain use the default provider.
+func GetProviderChain(llmConfig config.LlmConfigT, variable config.LlmVariableT) ([]config.LlmProviderProfileT, error) {
+	if len(variable.ProviderChain) == 0 {
+		return []config.LlmProviderProfileT{llmConfig.DefaultProviderProfile()}, nil
+	}
+	profiles := make([]config.LlmProviderProfileT, 0, len(variable.ProviderChain))
+	for _, profileName := range variable.ProviderChain {
+		if profileName == config.DefaultProviderProfileName {
+			profiles = append(profiles, llmConfig.DefaultProviderProfile())
+			continue
+		}
+		profile, exists := llmConfig.GetProviderProfile(profileName)
+		if !exists {
+			return nil, fmt.Errorf("provider profile %q does not exist", profileName)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// Tries each profile in turn until one returns a response.
+// Returns the response along with the name of the profile that answered.
+// No requests are sent once the budget has been exceeded.
+func GetResponseTextFromChain(profiles []config.LlmProviderProfileT, prompt PromptT, timeoutDuration time.Duration) (string, string, error) {
+	if len(profiles) == 0 {
+		return "", "", errors.New("no provider profiles to send the prompt to")
+	}
+	if err := Usage.CheckBudget(config.SnapshotPreferences().LlmConfig.Budget, time.Now()); err != nil {
+		return "", "", err
+	}
+	var errs []error
+	for _, profile := range profiles {
+		handler, err := NewLlmHandler(profile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("profile %q: %w", profile.Name, err))
+			continue
+		}
+		response, err := handler.GetResponseText(prompt, timeoutDuration)
+		if err != nil {
+			config.Logger.LogInfof("provider profile %q failed, trying next in chain - err: %v", profile.Name, err)
+			errs = append(errs, fmt.Errorf("profile %q: %w", profile.Name, err))
+			continue
+		}
+		Usage.Record(prompt.Source, GetModelName(profile), response.Usage)
+		return response.Text, profile.Name, nil
+	}
+	return "", "", fmt.Errorf("all providers in chain failed - err: %w", errors.Join(errs...))
+}
@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+)
+
+var fieldNameRegex = regexp.MustCompile(`^\w+$`)
+
+// Checks the fields of an AI-generated variable group can be referenced unambiguously
+func ValidateGroupFields(fields []string) error {
+	seen := map[string]struct{}{}
+	for _, field := range fields {
+		if !fieldNameRegex.MatchString(field) {
+			return fmt.Errorf("field %q must only contain letters, numbers and underscores", field)
+		}
+		if _, exists := seen[field]; exists {
+			return fmt.Errorf("field %q is listed more than once", field)
+		}
+		seen[field] = struct{}{}
+	}
+	for _, field := range fields {
+		for _, other := range fields {
+			if field != other && strings.HasPrefix(other, field) {
+				return fmt.Errorf("field %q must not be the start of another field (%q)", field, other)
+			}
+		}
+	}
+	return nil
+}
+
+// Asks for a JSON object in the prompt itself. This is added for every provider - those with a native json mode
+// are also constrained to the fields, but the rest only have these instructions to go on.
+func addStructuredOutputInstructions(prompt string, fields []string) string {
+	return fmt.Sprintf(
+		"%s\n\nRespond only with a JSON object containing these string fields: %s",
+		prompt, strings.Join(fields, ", "),
+	)
+}
+
+// Parses a JSON object containing a value for each field, ignoring any text or code fences around it
+func ParseStructuredResponse(response string, fields []string) (map[string]string, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("it was not a JSON object with the fields %s", strings.Join(fields, ", "))
+	}
+	var rawValues map[string]any
+	if err := json.Unmarshal([]byte(response[start:end+1]), &rawValues); err != nil {
+		return nil, fmt.Errorf("it was not valid JSON (%v)", err)
+	}
+	fieldValues := make(map[string]string, len(fields))
+	for _, field := range fields {
+		rawValue, exists := rawValues[field]
+		if !exists || rawValue == nil {
+			return nil, fmt.Errorf("it was missing the %q field", field)
+		}
+		if str, isString := rawValue.(string); isString {
+			fieldValues[field] = str
+		} else {
+			fieldValues[field] = fmt.Sprint(rawValue)
+		}
+	}
+	return fieldValues, nil
+}
+
+// Encodes the field values of a group as the single value stored for the variable
+func EncodeFieldValues(fieldValues map[string]string) string {
+	encoded, err := json.Marshal(fieldValues)
+	if err != nil {
+		// unreachable - maps of strings always marshal
+		return ""
+	}
+	return string(encoded)
+}
+
+// Returns the placeholders of a variable mapped to their values - one per field for groups
+func GetPlaceholderValues(variable config.LlmVariableT, value string) (map[string]string, error) {
+	if !variable.IsGroup() {
+		return map[string]string{helpers.GenerateVarPlaceholderString(variable.Name): value}, nil
+	}
+	fieldValues, err := ParseStructuredResponse(value, variable.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("value of group %v is not valid - err: %w", variable.Name, err)
+	}
+	placeholderValues := make(map[string]string, len(fieldValues))
+	for field, fieldValue := range fieldValues {
+		placeholderValues[helpers.GenerateVarPlaceholderString(variable.Name+config.GroupFieldSeparator+field)] = fieldValue
+	}
+	return placeholderValues, nil
+}
+
+// Readable form of a value, used for generation history - groups list their field values in order
+func getHistoryText(variable config.LlmVariableT, value string) string {
+	if !variable.IsGroup() {
+		return value
+	}
+	fieldValues, err := ParseStructuredResponse(value, variable.Fields)
+	if err != nil {
+		return value
+	}
+	orderedValues := make([]string, 0, len(variable.Fields))
+	for _, field := range variable.Fields {
+		orderedValues = append(orderedValues, fieldValues[field])
+	}
+	return strings.Join(orderedValues, " | ")
+}
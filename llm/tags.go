@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/finahdinner/tidal/helpers"
+)
+
+var errNoValidTags = errors.New("it did not contain any valid tags")
+
+// Asks for a list of stream tags, following twitch's tag rules
+func addTagsInstructions(prompt string) string {
+	return fmt.Sprintf(
+		"%s\n\nRespond only with up to %v comma-separated stream tags. Each tag must be at most %v letters or numbers, with no spaces or special characters.",
+		prompt, helpers.MaxTags, helpers.MaxTagLength,
+	)
+}
+
+// Splits a response into tags on commas, newlines and hashes, normalised to twitch's tag rules
+func ParseTags(response string) []string {
+	items := strings.FieldsFunc(response, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '#'
+	})
+	return helpers.NormaliseTags(items)
+}
+
+// Encodes tags as the single value stored for the variable
+func EncodeTags(tags []string) string {
+	return strings.Join(tags, ", ")
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const usageDateLayout = "2006-01-02"
+
+var ErrBudgetExceeded = errors.New("LLM budget exceeded")
+
+// Tracks token usage and estimated cost, per source for the current session and per day on disk
+type UsageTrackerT struct {
+	mu      sync.Mutex
+	session map[string]config.UsageTotalsT // source (e.g. variable name) -> totals
+	history *config.UsageHistoryT          // loaded lazily
+}
+
+var Usage = NewUsageTracker()
+
+func NewUsageTracker() *UsageTrackerT {
+	return &UsageTrackerT{session: map[string]config.UsageTotalsT{}}
+}
+
+// Estimated cost in USD of some usage of a model, and whether the model has a known price
+func EstimateCost(model string, usage UsageT) (float64, bool) {
+	llmConfig := config.SnapshotPreferences().LlmConfig
+	price, exists := llmConfig.GetPrice(model)
+	if !exists {
+		return 0, false
+	}
+	cost := float64(usage.InputTokens)*price.InputPerMillionTokens/1_000_000 +
+		float64(usage.OutputTokens)*price.OutputPerMillionTokens/1_000_000
+	return cost, true
+}
+
+// Records the usage of a successful request, and saves the daily totals to disk
+func (u *UsageTrackerT) Record(source string, model string, usage UsageT) {
+	cost, priced := EstimateCost(model, usage)
+	if !priced {
+		config.Logger.LogDebugf("no price found for model %q - its usage will not count towards the budget", model)
+	}
+	totals := config.UsageTotalsT{
+		Calls:        1,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		CostUsd:      cost,
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	sessionTotals := u.session[source]
+	sessionTotals.Add(totals)
+	u.session[source] = sessionTotals
+
+	history := u.getHistory()
+	if history == nil {
+		config.Logger.LogErrorf("LLM usage from %v was not saved, as the saved usage could not be read", source)
+		return
+	}
+	day := time.Now().Format(usageDateLayout)
+	dayTotals := history.Days[day]
+	dayTotals.Add(totals)
+	history.Days[day] = dayTotals
+	if err := config.SaveUsageHistory(*history); err != nil {
+		config.Logger.LogErrorf("unable to save LLM usage - err: %v", err)
+	}
+}
+
+// Totals for each source since the session was last reset
+func (u *UsageTrackerT) SessionTotals() map[string]config.UsageTotalsT {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	totals := make(map[string]config.UsageTotalsT, len(u.session))
+	for source, t := range u.session {
+		totals[source] = t
+	}
+	return totals
+}
+
+func (u *UsageTrackerT) ResetSession() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.session = map[string]config.UsageTotalsT{}
+}
+
+func (u *UsageTrackerT) DayTotals(now time.Time) config.UsageTotalsT {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	history := u.getHistory()
+	if history == nil {
+		return config.UsageTotalsT{}
+	}
+	return history.Days[now.Format(usageDateLayout)]
+}
+
+func (u *UsageTrackerT) MonthTotals(now time.Time) config.UsageTotalsT {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	month := now.Format("2006-01")
+	totals := config.UsageTotalsT{}
+	history := u.getHistory()
+	if history == nil {
+		return totals
+	}
+	for day, t := range history.Days {
+		if len(day) >= len(month) && day[:len(month)] == month {
+			totals.Add(t)
+		}
+	}
+	return totals
+}
+
+// Returns an error wrapping ErrBudgetExceeded if today's or this month's spend has reached its limit
+func (u *UsageTrackerT) CheckBudget(budget config.LlmBudgetT, now time.Time) error {
+	if budget.DailyLimitUsd > 0 {
+		if spent := u.DayTotals(now).CostUsd; spent >= budget.DailyLimitUsd {
+			return fmt.Errorf("%w - spent $%.4f of the $%.2f daily limit", ErrBudgetExceeded, spent, budget.DailyLimitUsd)
+		}
+	}
+	if budget.MonthlyLimitUsd > 0 {
+		if spent := u.MonthTotals(now).CostUsd; spent >= budget.MonthlyLimitUsd {
+			return fmt.Errorf("%w - spent $%.4f of the $%.2f monthly limit", ErrBudgetExceeded, spent, budget.MonthlyLimitUsd)
+		}
+	}
+	return nil
+}
+
+// Returns nil if the saved usage cannot be read, so that it is not overwritten - it is read again next time.
+// Must be called with the lock held.
+func (u *UsageTrackerT) getHistory() *config.UsageHistoryT {
+	if u.history == nil {
+		history, err := config.GetUsageHistory()
+		if errors.Is(err, config.ErrUsageHistoryMovedAside) {
+			config.Logger.LogErrorf("unable to load LLM usage, starting afresh - err: %v", err)
+		} else if err != nil {
+			config.Logger.LogErrorf("unable to load LLM usage - err: %v", err)
+			return nil
+		}
+		u.history = &history
+	}
+	return u.history
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+func TestMain(m *testing.M) {
+	// start from fresh preferences and usage, removed once the tests finish
+	dir, err := os.MkdirTemp("", "tidal-llm-test-*")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := config.UseAppConfigDir(dir); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestRecordMovesUnreadableUsageAside(t *testing.T) {
+	usagePath := filepath.Join(config.AppConfigDir, "usage.json")
+	corrupt := []byte(`{"days": {"2026-01-01": {"cost_usd": 12.5`)
+	if err := os.WriteFile(usagePath, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Remove(usagePath)
+		movedPaths, _ := filepath.Glob(usagePath + ".*.corrupt")
+		for _, movedPath := range movedPaths {
+			os.Remove(movedPath)
+		}
+	})
+
+	tracker := NewUsageTracker()
+	tracker.Record("Joke", "mock", UsageT{InputTokens: 10, OutputTokens: 5})
+
+	movedPaths, err := filepath.Glob(usagePath + ".*.corrupt")
+	if err != nil || len(movedPaths) != 1 {
+		t.Fatalf("expected the unreadable usage to be moved aside, got %v", movedPaths)
+	}
+	moved, err := os.ReadFile(movedPaths[0])
+	if err != nil || string(moved) != string(corrupt) {
+		t.Errorf("expected the unreadable usage to be kept as it was, got %q", moved)
+	}
+	history, err := config.GetUsageHistory()
+	if err != nil {
+		t.Fatalf("unable to read the new usage - err: %v", err)
+	}
+	if totals := history.Days[time.Now().Format(usageDateLayout)]; totals.Calls != 1 || totals.InputTokens != 10 {
+		t.Errorf("expected the new usage to be saved, got %+v", totals)
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	tracker := NewUsageTracker()
+	tracker.history = &config.UsageHistoryT{Days: map[string]config.UsageTotalsT{
+		"2026-03-15": {CostUsd: 1},
+		"2026-03-01": {CostUsd: 4},
+		"2026-02-28": {CostUsd: 100},
+	}}
+
+	if err := tracker.CheckBudget(config.LlmBudgetT{DailyLimitUsd: 2, MonthlyLimitUsd: 10}, now); err != nil {
+		t.Errorf("expected to be within budget, got %v", err)
+	}
+	if err := tracker.CheckBudget(config.LlmBudgetT{DailyLimitUsd: 1}, now); err == nil {
+		t.Error("expected the daily limit to be exceeded")
+	}
+	if err := tracker.CheckBudget(config.LlmBudgetT{MonthlyLimitUsd: 5}, now); err == nil {
+		t.Error("expected the monthly limit to be exceeded")
+	}
+}
@@ -1,7 +1,16 @@
-package main
-
-import "github.com/finahdinner/tidal/gui"
-
-func main() {
-	gui.Gui.App.Run()
-}
+package main
+
+import (
+	"os"
+
+	"github.com/finahdinner/tidal/cli"
+	"github.com/finahdinner/tidal/gui"
+)
+
+func main() {
+	if cli.IsCommand(os.Args[1:]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+	gui.Init()
+	gui.Gui.App.Run()
+}
@@ -0,0 +1,267 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	SeverityNone   = ""
+	SeverityMild   = "mild"
+	SeveritySevere = "severe"
+
+	// at the start or end of a term, lets it match as part of a longer word (e.g. "scam*" matches "scammer")
+	Wildcard = "*"
+)
+
+var leetspeakReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "9", "g",
+	"@", "a", "$", "s", "!", "i", "|", "l", "+", "t",
+)
+
+type MatchT struct {
+	Term     string
+	Severity string
+}
+
+type ResultT struct {
+	Text    string // the checked text, masked if the action is ModerationActionMask
+	Matches []MatchT
+	Action  string // strictest action required by the matches, or "" if the text is clean
+}
+
+func (r ResultT) MatchedTerms() []string {
+	terms := make([]string, 0, len(r.Matches))
+	for _, m := range r.Matches {
+		terms = append(terms, m.Term)
+	}
+	return terms
+}
+
+type wordT struct {
+	original   string
+	normalised string
+	plain      string // normalised without undoing leetspeak, as symbols such as ! may just be punctuation
+	start      int    // byte offset of the word in the checked text
+	end        int
+}
+
+// A blocklist term, normalised the same way as the checked text
+type termT struct {
+	words       []string
+	matchBefore bool // the first word may have more letters before it
+	matchAfter  bool // the last word may have more letters after it
+}
+
+// Checks text against the blocklists, masking matched words if the resulting action is to mask
+func Check(text string, moderationConfig config.ModerationConfigT) ResultT {
+	result := ResultT{Text: text}
+	if !moderationConfig.Enabled {
+		return result
+	}
+
+	allowlist := map[string]struct{}{}
+	for _, term := range moderationConfig.Allowlist {
+		allowlist[normalise(term, moderationConfig.NormaliseLeetspeak)] = struct{}{}
+	}
+
+	words := splitWords(text, moderationConfig.NormaliseLeetspeak)
+
+	maskedWordIdxs := map[int]struct{}{}
+	for _, list := range []struct {
+		terms    []string
+		severity string
+	}{
+		{moderationConfig.SevereBlocklist, SeveritySevere},
+		{moderationConfig.MildBlocklist, SeverityMild},
+	} {
+		for _, term := range list.terms {
+			matchedIdxs := findTerm(words, parseTerm(term, moderationConfig.NormaliseLeetspeak), allowlist)
+			if len(matchedIdxs) == 0 {
+				continue
+			}
+			result.Matches = append(result.Matches, MatchT{Term: term, Severity: list.severity})
+			for _, idx := range matchedIdxs {
+				maskedWordIdxs[idx] = struct{}{}
+			}
+		}
+	}
+
+	result.Action = strictestAction(result.Matches, moderationConfig)
+	if result.Action == config.ModerationActionMask {
+		var sb strings.Builder
+		prevEnd := 0
+		for idx, w := range words {
+			if _, masked := maskedWordIdxs[idx]; !masked {
+				continue
+			}
+			sb.WriteString(text[prevEnd:w.start])
+			sb.WriteString(mask(w.original))
+			prevEnd = w.end
+		}
+		sb.WriteString(text[prevEnd:])
+		result.Text = sb.String()
+	}
+	return result
+}
+
+// Splits text into words like strings.Fields, keeping where each word is so it can be masked in place
+func splitWords(text string, normaliseLeetspeak bool) []wordT {
+	words := []wordT{}
+	start := -1
+	for idx, r := range text {
+		if !unicode.IsSpace(r) {
+			if start == -1 {
+				start = idx
+			}
+			continue
+		}
+		if start != -1 {
+			words = append(words, newWord(text, start, idx, normaliseLeetspeak))
+			start = -1
+		}
+	}
+	if start != -1 {
+		words = append(words, newWord(text, start, len(text), normaliseLeetspeak))
+	}
+	return words
+}
+
+func newWord(text string, start int, end int, normaliseLeetspeak bool) wordT {
+	original := text[start:end]
+	return wordT{
+		original:   original,
+		normalised: normalise(original, normaliseLeetspeak),
+		plain:      normalise(original, false),
+		start:      start,
+		end:        end,
+	}
+}
+
+func parseTerm(term string, normaliseLeetspeak bool) termT {
+	term = strings.TrimSpace(term)
+	parsed := termT{
+		matchBefore: strings.HasPrefix(term, Wildcard),
+		matchAfter:  strings.HasSuffix(term, Wildcard),
+	}
+	for _, word := range strings.Fields(strings.Trim(term, Wildcard)) {
+		if normalised := normalise(word, normaliseLeetspeak); normalised != "" {
+			parsed.words = append(parsed.words, normalised)
+		}
+	}
+	return parsed
+}
+
+// Returns the indices of all words which make up occurrences of the term.
+// Terms match whole words, so "ass" does not match "class", unless they start or end with a wildcard.
+func findTerm(words []wordT, term termT, allowlist map[string]struct{}) []int {
+	if len(term.words) == 0 {
+		return nil
+	}
+	matchedIdxs := []int{}
+	for start := 0; start+len(term.words) <= len(words); start++ {
+		window := words[start : start+len(term.words)]
+		matches := true
+		for idx, w := range window {
+			matchBefore := term.matchBefore && idx == 0
+			matchAfter := term.matchAfter && idx == len(window)-1
+			if !matchesTermWord(w.normalised, term.words[idx], matchBefore, matchAfter) &&
+				!matchesTermWord(w.plain, term.words[idx], matchBefore, matchAfter) {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		// allowed words are never blocked, even if a wildcard term matches part of them
+		if len(window) == 1 && (isAllowed(window[0].normalised, allowlist) || isAllowed(window[0].plain, allowlist)) {
+			continue
+		}
+		for idx := range window {
+			matchedIdxs = append(matchedIdxs, start+idx)
+		}
+	}
+	return matchedIdxs
+}
+
+func isAllowed(word string, allowlist map[string]struct{}) bool {
+	_, allowed := allowlist[word]
+	return allowed
+}
+
+func matchesTermWord(word string, termWord string, matchBefore bool, matchAfter bool) bool {
+	switch {
+	case matchBefore && matchAfter:
+		return strings.Contains(word, termWord)
+	case matchBefore:
+		return strings.HasSuffix(word, termWord)
+	case matchAfter:
+		return strings.HasPrefix(word, termWord)
+	default:
+		return word == termWord
+	}
+}
+
+func strictestAction(matches []MatchT, moderationConfig config.ModerationConfigT) string {
+	actions := map[string]struct{}{}
+	for _, m := range matches {
+		action := moderationConfig.MildAction
+		if m.Severity == SeveritySevere {
+			action = moderationConfig.SevereAction
+		}
+		if action == "" {
+			action = config.ModerationActionReject
+		}
+		actions[action] = struct{}{}
+	}
+	for _, action := range []string{config.ModerationActionReject, config.ModerationActionRegenerate, config.ModerationActionMask} {
+		if _, exists := actions[action]; exists {
+			return action
+		}
+	}
+	return ""
+}
+
+// Lowercases the text, optionally undoes leetspeak, then strips anything which isn't a letter
+func normalise(text string, normaliseLeetspeak bool) string {
+	text = strings.ToLower(text)
+	if normaliseLeetspeak {
+		text = leetspeakReplacer.Replace(text)
+		text = collapseRepeatedRunes(text)
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return r
+		}
+		return -1
+	}, text)
+}
+
+// Collapses runs of 3 or more of the same character (e.g. "helllllo" -> "helo")
+func collapseRepeatedRunes(text string) string {
+	runes := []rune(text)
+	collapsed := make([]rune, 0, len(runes))
+	for start := 0; start < len(runes); {
+		end := start
+		for end < len(runes) && runes[end] == runes[start] {
+			end++
+		}
+		runLength := end - start
+		if runLength >= 3 {
+			runLength = 1
+		}
+		for range runLength {
+			collapsed = append(collapsed, runes[start])
+		}
+		start = end
+	}
+	return string(collapsed)
+}
+
+func mask(word string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(word))
+}
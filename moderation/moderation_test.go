@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+func TestCheck(t *testing.T) {
+	moderationConfig := config.ModerationConfigT{
+		Enabled:            true,
+		SevereBlocklist:    []string{"scam*", "free money"},
+		MildBlocklist:      []string{"heck"},
+		Allowlist:          []string{"scampi"},
+		NormaliseLeetspeak: true,
+		SevereAction:       config.ModerationActionReject,
+		MildAction:         config.ModerationActionMask,
+	}
+
+	for _, tc := range []struct {
+		text          string
+		expectedTerms []string
+		action        string
+	}{
+		{text: "Cooking scampi tonight", action: ""},
+		{text: "Totally not a $c4m", expectedTerms: []string{"scam*"}, action: config.ModerationActionReject},
+		{text: "FREE money giveaway", expectedTerms: []string{"free money"}, action: config.ModerationActionReject},
+		{text: "Free stuff and money", action: ""},
+		{text: "What the heeeeck", expectedTerms: []string{"heck"}, action: config.ModerationActionMask},
+		{text: "scamming in heck", expectedTerms: []string{"scam*", "heck"}, action: config.ModerationActionReject},
+		// terms without a wildcard only match whole words
+		{text: "Checking the heckler", action: ""},
+	} {
+		result := Check(tc.text, moderationConfig)
+		if !slices.Equal(result.MatchedTerms(), tc.expectedTerms) {
+			t.Errorf("%q: expected matches %q, got %q", tc.text, tc.expectedTerms, result.MatchedTerms())
+		}
+		if result.Action != tc.action {
+			t.Errorf("%q: expected action %q, got %q", tc.text, tc.action, result.Action)
+		}
+	}
+}
+
+// Terms must not match innocent words which happen to contain them
+func TestCheckMatchesWholeWords(t *testing.T) {
+	moderationConfig := config.ModerationConfigT{
+		Enabled:            true,
+		SevereBlocklist:    []string{"ass", "hell", "*cum*"},
+		NormaliseLeetspeak: true,
+		SevereAction:       config.ModerationActionReject,
+	}
+
+	for _, tc := range []struct {
+		text          string
+		expectedTerms []string
+	}{
+		{text: "First class assets in Scunthorpe"},
+		{text: "Hello from the shell"},
+		{text: "Passing the assessment"},
+		{text: "You absolute ass!", expectedTerms: []string{"ass"}},
+		{text: "Welcome to h3ll", expectedTerms: []string{"hell"}},
+		// wildcards opt back in to matching inside words
+		{text: "Cucumber salad", expectedTerms: []string{"*cum*"}},
+	} {
+		if result := Check(tc.text, moderationConfig); !slices.Equal(result.MatchedTerms(), tc.expectedTerms) {
+			t.Errorf("%q: expected matches %q, got %q", tc.text, tc.expectedTerms, result.MatchedTerms())
+		}
+	}
+}
+
+func TestCheckMasksMatchedWords(t *testing.T) {
+	moderationConfig := config.ModerationConfigT{
+		Enabled:       true,
+		MildBlocklist: []string{"heck"},
+		MildAction:    config.ModerationActionMask,
+	}
+	result := Check("Oh heck, what the heck", moderationConfig)
+	// whole words are masked, including any punctuation attached to them
+	if result.Text != "Oh ***** what the ****" {
+		t.Errorf("unexpected masked text %q", result.Text)
+	}
+
+	moderationConfig.MildBlocklist = []string{"the heck"}
+	result = Check("Heck, the theme is the heck", moderationConfig)
+	// only the matched occurrences are masked, not earlier words containing the same text
+	if result.Text != "Heck, the theme is *** ****" {
+		t.Errorf("unexpected masked text %q", result.Text)
+	}
+}
+
+func TestCheckDisabled(t *testing.T) {
+	result := Check("scam", config.ModerationConfigT{SevereBlocklist: []string{"scam"}})
+	if len(result.Matches) != 0 || result.Action != "" || result.Text != "scam" {
+		t.Errorf("expected disabled moderation to leave the text alone, got %+v", result)
+	}
+}
@@ -1,172 +1,258 @@
-package twitch
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"net/http"
-	"net/url"
-
-	"github.com/finahdinner/tidal/config"
-)
-
-var Err401Unauthorised error = errors.New("unauthorised")
-
-func GetStreamInfo(ctx context.Context, prefs config.PreferencesFormat) (*streamInfoT, error) {
-	params := url.Values{}
-	params.Add("user_id", prefs.TwitchConfig.UserId)
-	queryUrl := fmt.Sprintf("%s?%s", twitchApiStreamsUrl, params.Encode())
-	config.Logger.LogInfof("queryUrl: %v", queryUrl)
-	streamsApiResponse, err := makeGetRequest[getStreamInfoApiResponseT](ctx, queryUrl, "application/json", prefs)
-	if err != nil {
-		return nil, err
-	}
-	switch len(streamsApiResponse.Data) {
-	case 0:
-		return nil, fmt.Errorf("api response returned no stream info for user_id %v", prefs.TwitchConfig.UserId)
-	case 1:
-		// valid
-	default:
-		return nil, fmt.Errorf("api response somehow returned more than one stream for user_id %v", prefs.TwitchConfig.UserId)
-	}
-	return &streamsApiResponse.Data[0], nil
-}
-
-func GetSubscribers(ctx context.Context, prefs config.PreferencesFormat) (*getChannelSubscribersResponseT, error) {
-	params := url.Values{}
-	params.Add("broadcaster_id", prefs.TwitchConfig.UserId)
-	queryUrl := fmt.Sprintf("%s?%s", twitchApiSubscriptionsUrl, params.Encode())
-	config.Logger.LogInfof("queryUrl: %v", queryUrl)
-	subscribersApiResponse, err := makeGetRequest[getChannelSubscribersResponseT](ctx, queryUrl, "application/json", prefs)
-	if err != nil {
-		return nil, err
-	}
-	return &subscribersApiResponse, nil
-}
-
-func GetFollowers(ctx context.Context, prefs config.PreferencesFormat) (*getChannelFollowersResponseT, error) {
-	params := url.Values{}
-	params.Add("broadcaster_id", prefs.TwitchConfig.UserId)
-	queryUrl := fmt.Sprintf("%s?%s", twitchApiFollowersUrl, params.Encode())
-	config.Logger.LogInfof("queryUrl: %v", queryUrl)
-	followersApiResponse, err := makeGetRequest[getChannelFollowersResponseT](ctx, queryUrl, "application/json", prefs)
-	if err != nil {
-		return &followersApiResponse, err
-	}
-	return &followersApiResponse, nil
-}
-
-// PATCH request to /channels endpoint
-func UpdateStreamTitle(ctx context.Context, prefs config.PreferencesFormat) error {
-	params := url.Values{}
-	params.Add("broadcaster_id", prefs.TwitchConfig.UserId)
-	queryUrl := fmt.Sprintf("%s?%s", twitchApiChannelsUrl, params.Encode())
-	config.Logger.LogInfof("queryUrl: %v", queryUrl)
-
-	reqBody := map[string]string{
-		"title": prefs.Title.Value,
-	}
-	reqBodyJson, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("unable to parse reqBody - err: %w", err)
-	}
-	config.Logger.LogInfof("reqBodyJson: %v", string(reqBodyJson))
-
-	// make a PATCH request
-	req, err := http.NewRequestWithContext(ctx, "PATCH", queryUrl, bytes.NewBuffer(reqBodyJson))
-	if err != nil {
-		return fmt.Errorf("unable to construct request using url %q and body %v", queryUrl, reqBodyJson)
-	}
-
-	req.Header.Set("Client-Id", prefs.TwitchConfig.ClientId)
-	req.Header.Set("Authorization", "Bearer "+prefs.TwitchConfig.Credentials.UserAccessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request for %v failed - err: %w", req.URL, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unable to update content - http status %v", resp.Status)
-	}
-	return nil
-}
-
-// POST request to /messages endpoint
-func SendChatMessage(ctx context.Context, prefs config.PreferencesFormat, message string) error {
-	reqBody := map[string]string{
-		"broadcaster_id": prefs.TwitchConfig.UserId,
-		"sender_id":      prefs.TwitchConfig.UserId,
-		"message":        message,
-	}
-	reqBodyJson, err := json.Marshal(reqBody)
-	if err != nil {
-		return fmt.Errorf("unable to parse reqBody - err: %w", err)
-	}
-	config.Logger.LogInfof("reqBodyJson: %v", string(reqBodyJson))
-
-	// make a POST request
-	req, err := http.NewRequestWithContext(ctx, "POST", twitchApiMessagesUrl, bytes.NewBuffer(reqBodyJson))
-	if err != nil {
-		return fmt.Errorf("unable to construct request using url %q and body %v", twitchApiMessagesUrl, reqBodyJson)
-	}
-
-	req.Header.Set("Client-Id", prefs.TwitchConfig.ClientId)
-	req.Header.Set("Authorization", "Bearer "+prefs.TwitchConfig.Credentials.UserAccessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request for %v failed - err: %w", req.URL, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unable to send message - http status %v", resp.Status)
-	}
-
-	var result postChatMessageResponseT
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("unable to decode response from request to %v - err: %w", req.URL, err)
-	} else if !result.Data[0].IsSent {
-		if result.Data[0].DropReason == nil { // malformed response
-			return errors.New("unable to send message in Twitch channel, and no drop reason received")
-		}
-		return fmt.Errorf("http status: %v - unable to send message in channel - code: %v, reason: %v", resp.StatusCode, result.Data[0].DropReason.Code, result.Data[0].DropReason.Message)
-	}
-
-	return nil
-}
-
-func makeGetRequest[T any](ctx context.Context, queryUrl string, mimeType string, prefs config.PreferencesFormat) (T, error) {
-	var result T
-
-	req, err := http.NewRequestWithContext(ctx, "GET", queryUrl, nil)
-	if err != nil {
-		return result, fmt.Errorf("unable to construct request for %v - err: %w", queryUrl, err)
-	}
-
-	req.Header.Set("Client-Id", prefs.TwitchConfig.ClientId)
-	req.Header.Set("Authorization", "Bearer "+prefs.TwitchConfig.Credentials.UserAccessToken)
-	req.Header.Set("Accept", mimeType)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return result, fmt.Errorf("request for %v failed - err: %w", req.URL, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		return result, Err401Unauthorised
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return result, fmt.Errorf("unable to decode response from request to %v - err: %w", req.URL, err)
-	}
-
-	return result, nil
-}
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+var (
+	ErrStreamNotLive error = errors.New("the stream is not live")
+	ErrMissingScope  error = errors.New("a required scope has not been granted - re-authenticate to grant it")
+)
+
+func GetStreamInfo(ctx context.Context, prefs config.PreferencesFormat) (*streamInfoT, error) {
+	params := url.Values{}
+	params.Add("user_id", prefs.TwitchConfig.UserId)
+	queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiStreamsPath), params.Encode())
+	config.Logger.LogInfof("queryUrl: %v", queryUrl)
+	streamsApiResponse, err := makeGetRequest[getStreamInfoApiResponseT](ctx, queryUrl, "application/json", prefs)
+	if err != nil {
+		return nil, err
+	}
+	switch len(streamsApiResponse.Data) {
+	case 0:
+		return nil, fmt.Errorf("api response returned no stream info for user_id %v", prefs.TwitchConfig.UserId)
+	case 1:
+		// valid
+	default:
+		return nil, fmt.Errorf("api response somehow returned more than one stream for user_id %v", prefs.TwitchConfig.UserId)
+	}
+	return &streamsApiResponse.Data[0], nil
+}
+
+func GetSubscribers(ctx context.Context, prefs config.PreferencesFormat) (*getChannelSubscribersResponseT, error) {
+	params := url.Values{}
+	params.Add("broadcaster_id", prefs.TwitchConfig.UserId)
+	queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiSubscriptionsPath), params.Encode())
+	config.Logger.LogInfof("queryUrl: %v", queryUrl)
+	subscribersApiResponse, err := makeGetRequest[getChannelSubscribersResponseT](ctx, queryUrl, "application/json", prefs)
+	if err != nil {
+		return nil, err
+	}
+	return &subscribersApiResponse, nil
+}
+
+func GetFollowers(ctx context.Context, prefs config.PreferencesFormat) (*getChannelFollowersResponseT, error) {
+	params := url.Values{}
+	params.Add("broadcaster_id", prefs.TwitchConfig.UserId)
+	queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiFollowersPath), params.Encode())
+	config.Logger.LogInfof("queryUrl: %v", queryUrl)
+	followersApiResponse, err := makeGetRequest[getChannelFollowersResponseT](ctx, queryUrl, "application/json", prefs)
+	if err != nil {
+		return &followersApiResponse, err
+	}
+	return &followersApiResponse, nil
+}
+
+// PATCH request to /channels endpoint
+func UpdateStreamTitle(ctx context.Context, prefs config.PreferencesFormat) error {
+	return UpdateChannel(ctx, prefs, ChannelUpdateT{Title: prefs.Title.Value})
+}
+
+// PATCH request to /channels endpoint, changing everything in the update at once
+func UpdateChannel(ctx context.Context, prefs config.PreferencesFormat, update ChannelUpdateT) error {
+	params := url.Values{}
+	params.Add("broadcaster_id", prefs.TwitchConfig.UserId)
+	queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiChannelsPath), params.Encode())
+	config.Logger.LogInfof("queryUrl: %v", queryUrl)
+
+	reqBody := updateChannelRequestT{
+		Title:               update.Title,
+		GameId:              update.GameId,
+		BroadcasterLanguage: update.Language,
+		Tags:                update.Tags,
+	}
+	if update.ContentLabels != nil {
+		reqBody.ContentClassificationLabels = getContentClassificationLabels(update.ContentLabels)
+	}
+	reqBodyJson, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("unable to parse reqBody - err: %w", err)
+	}
+	config.Logger.LogInfof("reqBodyJson: %v", string(reqBodyJson))
+
+	// make a PATCH request
+	resp, err := helixClient.do(ctx, helixRequestT{
+		method:      "PATCH",
+		url:         queryUrl,
+		body:        reqBodyJson,
+		contentType: "application/json",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unable to update content - http status %v", resp.Status)
+	}
+	return nil
+}
+
+// POST request to /messages endpoint
+func SendChatMessage(ctx context.Context, prefs config.PreferencesFormat, message string) error {
+	queryUrl := helixClient.apiUrl(twitchApiMessagesPath)
+	reqBody := map[string]string{
+		"broadcaster_id": prefs.TwitchConfig.UserId,
+		"sender_id":      prefs.TwitchConfig.UserId,
+		"message":        message,
+	}
+	reqBodyJson, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("unable to parse reqBody - err: %w", err)
+	}
+	config.Logger.LogInfof("reqBodyJson: %v", string(reqBodyJson))
+
+	// make a POST request
+	resp, err := helixClient.do(ctx, helixRequestT{
+		method:      "POST",
+		url:         queryUrl,
+		body:        reqBodyJson,
+		contentType: "application/json",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to send message - http status %v", resp.Status)
+	}
+
+	var result postChatMessageResponseT
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("unable to decode response from request to %v - err: %w", queryUrl, err)
+	} else if !result.Data[0].IsSent {
+		if result.Data[0].DropReason == nil { // malformed response
+			return errors.New("unable to send message in Twitch channel, and no drop reason received")
+		}
+		return fmt.Errorf("http status: %v - unable to send message in channel - code: %v, reason: %v", resp.StatusCode, result.Data[0].DropReason.Code, result.Data[0].DropReason.Message)
+	}
+
+	return nil
+}
+
+// POST request to /moderation/enforcements/status - returns whether AutoMod would permit the text
+func CheckAutoModStatus(ctx context.Context, prefs config.PreferencesFormat, text string) (bool, error) {
+	params := url.Values{}
+	params.Add("broadcaster_id", prefs.TwitchConfig.UserId)
+	queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiAutoModStatusPath), params.Encode())
+
+	reqBody := map[string][]map[string]string{
+		"data": {{"msg_id": "tidal-title", "msg_text": text}},
+	}
+	reqBodyJson, err := json.Marshal(reqBody)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse reqBody - err: %w", err)
+	}
+
+	// make a POST request
+	resp, err := helixClient.do(ctx, helixRequestT{
+		method:      "POST",
+		url:         queryUrl,
+		body:        reqBodyJson,
+		contentType: "application/json",
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unable to check automod status - http status %v", resp.Status)
+	}
+
+	var result checkAutoModStatusResponseT
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("unable to decode response from request to %v - err: %w", queryUrl, err)
+	}
+	if len(result.Data) == 0 {
+		return false, errors.New("automod returned no status for the message")
+	}
+	return result.Data[0].IsPermitted, nil
+}
+
+// POST request to /streams/markers - marks the current position in the stream's VOD
+func CreateStreamMarker(ctx context.Context, prefs config.PreferencesFormat, description string) (*StreamMarkerT, error) {
+	// twitch answers a missing scope with a 401, which would otherwise look like an expired token
+	if !slices.Contains(prefs.TwitchConfig.Credentials.UserAccessScope, ScopeChannelManageBroadcast) {
+		return nil, fmt.Errorf("%w (%s)", ErrMissingScope, ScopeChannelManageBroadcast)
+	}
+	queryUrl := helixClient.apiUrl(twitchApiMarkersPath)
+	if descriptionRunes := []rune(description); len(descriptionRunes) > MaxMarkerDescriptionLength {
+		description = string(descriptionRunes[:MaxMarkerDescriptionLength])
+	}
+	reqBody := map[string]string{
+		"user_id":     prefs.TwitchConfig.UserId,
+		"description": description,
+	}
+	reqBodyJson, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse reqBody - err: %w", err)
+	}
+
+	// make a POST request
+	resp, err := helixClient.do(ctx, helixRequestT{
+		method:      "POST",
+		url:         queryUrl,
+		body:        reqBodyJson,
+		contentType: "application/json",
+	})
+	if errors.Is(err, ErrNotFound) {
+		// also returned when the broadcaster has not enabled VODs
+		return nil, fmt.Errorf("%w - err: %w", ErrStreamNotLive, err)
+	} else if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to create stream marker - http status %v", resp.Status)
+	}
+
+	var result createStreamMarkerResponseT
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unable to decode response from request to %v - err: %w", queryUrl, err)
+	}
+	if len(result.Data) == 0 {
+		return nil, errors.New("twitch returned no stream marker")
+	}
+	return &result.Data[0], nil
+}
+
+func makeGetRequest[T any](ctx context.Context, queryUrl string, mimeType string, prefs config.PreferencesFormat) (T, error) {
+	var result T
+
+	resp, err := helixClient.do(ctx, helixRequestT{
+		method: "GET",
+		url:    queryUrl,
+		accept: mimeType,
+	})
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("unable to decode response from request to %v - err: %w", queryUrl, err)
+	}
+
+	return result, nil
+}
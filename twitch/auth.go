@@ -13,71 +13,40 @@ import (
 	"github.com/finahdinner/tidal/helpers"
 )
 
-type ctxServerKey struct{}
-
-func CreateAuthCodeListener(hostAndPort string, codeChan chan string, csrfToken string) error {
-
-	if hostAndPort == "" {
-		return fmt.Errorf("hostAndPort %q is not valid", hostAndPort)
-	}
-
-	mux := http.NewServeMux()
-	server := &http.Server{
-		Addr:    hostAndPort,
-		Handler: mux,
-	}
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// ctx to allow handler to shutdown the server
-		ctx := context.WithValue(r.Context(), ctxServerKey{}, server)
-		config.Logger.LogInfof("ctx val: %v", ctx.Value(ctxServerKey{}))
-		code, err := handleAuthCodeReceived(w, r.WithContext(ctx), csrfToken)
-		if err != nil {
-			config.Logger.LogInfof("not valid: %v", err)
-			return
-		}
-		fmt.Fprintln(w, "You may now close this browser.")
-		codeChan <- code
-	})
-
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			config.Logger.LogInfof("server error: %v", err)
-		}
-	}()
-
-	config.Logger.LogInfof("listener set up at %s", hostAndPort)
+func SendGetRequestForAuthCode(csrfToken string) {
+	fullAuthUrl := GetAuthCodeUrl(csrfToken)
+	config.Logger.LogInfof("fullAuthUrl: %v", fullAuthUrl)
 
-	return nil
+	helpers.OpenUrlInBrowser(fullAuthUrl)
+	config.Logger.LogInfof("Please complete the authentication in your browser.")
 }
 
-func SendGetRequestForAuthCode(csrfToken string) {
+// Returns the url the user visits to authorise Tidal, which redirects to the redirect URI with an auth code
+func GetAuthCodeUrl(csrfToken string) string {
+	twitchConfig := config.SnapshotPreferences().TwitchConfig
 	params := url.Values{}
-	params.Add("client_id", config.Preferences.TwitchConfig.ClientId)
+	params.Add("client_id", twitchConfig.ClientId)
 	params.Add("force_verify", "true") // re-authorise each time
-	params.Add("redirect_uri", config.Preferences.TwitchConfig.ClientRedirectUri)
+	params.Add("redirect_uri", twitchConfig.ClientRedirectUri)
 	params.Add("response_type", "code")
-	params.Add("scope", "channel:read:subscriptions channel:manage:broadcast user:write:chat") // add to the scopes if required
+	params.Add("scope", requestedScopes())
 	params.Add("state", csrfToken)
 
-	fullAuthUrl := fmt.Sprintf("%s?%s", twitchApiAuthoriseUrl, params.Encode())
-
-	config.Logger.LogInfof("fullAuthUrl: %v", fullAuthUrl)
-
-	helpers.OpenUrlInBrowser(fullAuthUrl)
-	config.Logger.LogInfof("Please complete the authentication in your browser.")
+	return fmt.Sprintf("%s?%s", helixClient.authUrl(twitchApiAuthorisePath), params.Encode())
 }
 
 func GetUserAccessTokenFromAuthCode(authCode string) (*userAccessTokenInfoT, error) {
 	userAccessTokenInfo := &userAccessTokenInfoT{}
+	twitchConfig := config.SnapshotPreferences().TwitchConfig
 
 	params := url.Values{}
-	params.Add("client_id", config.Preferences.TwitchConfig.ClientId)
-	params.Add("client_secret", config.Preferences.TwitchConfig.ClientSecret)
+	params.Add("client_id", twitchConfig.ClientId)
+	params.Add("client_secret", twitchConfig.ClientSecret)
 	params.Add("code", authCode)
 	params.Add("grant_type", "authorization_code")
-	params.Add("redirect_uri", config.Preferences.TwitchConfig.ClientRedirectUri)
+	params.Add("redirect_uri", twitchConfig.ClientRedirectUri)
 
-	resp, err := http.Post(twitchApiTokenUrl, "application/x-www-form-urlencoded", strings.NewReader(params.Encode()))
+	resp, err := helixClient.httpClient.Post(helixClient.authUrl(twitchApiTokenPath), "application/x-www-form-urlencoded", strings.NewReader(params.Encode()))
 	if err != nil {
 		return userAccessTokenInfo, fmt.Errorf("error requesting userAccess token: %v", err)
 	}
@@ -89,27 +58,48 @@ func GetUserAccessTokenFromAuthCode(authCode string) (*userAccessTokenInfoT, err
 	return userAccessTokenInfo, nil
 }
 
+// Looks up the user id of the access token's user, then saves the credentials and user id
+func SaveAuthentication(userAccessTokenInfo *userAccessTokenInfoT) error {
+	twitchUserId, err := GetTwitchUserId(userAccessTokenInfo.AccessToken)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve twitch user id - error: %v", err)
+	}
+	config.Logger.LogInfof("twitchUserId: %v", twitchUserId)
+
+	helixClient.refreshMu.Lock()
+	defer helixClient.refreshMu.Unlock()
+	if err := config.UpdatePreferences(func(prefs *config.PreferencesFormat) {
+		prefs.TwitchConfig.Credentials = config.CredentialsT{
+			UserAccessToken:        userAccessTokenInfo.AccessToken,
+			UserAccessRefreshToken: userAccessTokenInfo.RefreshToken,
+			UserAccessScope:        userAccessTokenInfo.Scope,
+			ExpiryUnixTimestamp:    time.Now().Unix() + int64(userAccessTokenInfo.ExpiresIn),
+		}
+		prefs.TwitchConfig.UserId = twitchUserId
+	}); err != nil {
+		return fmt.Errorf("unable to save preferences - error: %v", err)
+	}
+	config.Logger.LogInfo("successfully authenticated (got access token + twitch user id)")
+	return nil
+}
+
 func GetTwitchUserId(accessToken string) (string, error) {
-	if config.Preferences.TwitchConfig.UserName == "" {
+	userName := config.SnapshotPreferences().TwitchConfig.UserName
+	if userName == "" {
 		return "", fmt.Errorf("username must be populated")
 	}
 
 	params := url.Values{}
-	params.Add("login", config.Preferences.TwitchConfig.UserName)
-
-	queryUrl := fmt.Sprintf("%s?%s", twitchApiUsersUrl, params.Encode())
-
-	req, err := http.NewRequest("GET", queryUrl, nil)
-	if err != nil {
-		return "", fmt.Errorf("unable to construct request - err: %w", err)
-	}
+	params.Add("login", userName)
 
-	req.Header.Set("Client-Id", config.Preferences.TwitchConfig.ClientId)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Accept", "application/json")
+	queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiUsersPath), params.Encode())
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := helixClient.do(context.Background(), helixRequestT{
+		method:      "GET",
+		url:         queryUrl,
+		accept:      "application/json",
+		accessToken: accessToken,
+	})
 	if err != nil {
 		return "", fmt.Errorf("request failed - err: %w", err)
 	}
@@ -138,57 +128,41 @@ func getUserAccessTokenFromRefreshToken(ctx context.Context) (*userAccessTokenIn
 	}
 
 	userAccessTokenInfo := &userAccessTokenInfoT{}
+	twitchConfig := config.SnapshotPreferences().TwitchConfig
 
 	params := url.Values{}
-	params.Add("client_id", config.Preferences.TwitchConfig.ClientId)
-	params.Add("client_secret", config.Preferences.TwitchConfig.ClientSecret)
+	params.Add("client_id", twitchConfig.ClientId)
+	if twitchConfig.ClientSecret != "" {
+		// public clients, e.g. those authenticated with a device code, refresh without a secret
+		params.Add("client_secret", twitchConfig.ClientSecret)
+	}
 	params.Add("grant_type", "refresh_token")
-	params.Add("refresh_token", config.Preferences.TwitchConfig.Credentials.UserAccessRefreshToken)
+	params.Add("refresh_token", config.GetCredentials().UserAccessRefreshToken)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", twitchApiTokenUrl, strings.NewReader(params.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", helixClient.authUrl(twitchApiTokenPath), strings.NewReader(params.Encode()))
 	if err != nil {
 		return userAccessTokenInfo, fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := helixClient.httpClient.Do(req)
 	if err != nil {
 		return userAccessTokenInfo, fmt.Errorf("error requesting userAccess token: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if err := json.NewDecoder(resp.Body).Decode(userAccessTokenInfo); err != nil {
-		return userAccessTokenInfo, fmt.Errorf("error decoding response: %v", err)
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		// the refresh token is invalid, expired or revoked
+		errorResponse := twitchErrorResponseT{}
+		json.NewDecoder(resp.Body).Decode(&errorResponse)
+		return userAccessTokenInfo, &RefreshTokenRejectedError{StatusCode: resp.StatusCode, Message: errorResponse.Message}
 	}
-	return userAccessTokenInfo, nil
-}
-
-func handleAuthCodeReceived(_ http.ResponseWriter, r *http.Request, csrfToken string) (string, error) {
-	config.Logger.LogInfof("first request from: %v - shutting down in 2 seconds...", r.URL)
-
-	if server, ok := r.Context().Value(ctxServerKey{}).(*http.Server); ok {
-		go shutDownServerGracefully(server, 2*time.Second)
+	if resp.StatusCode != http.StatusOK {
+		return userAccessTokenInfo, fmt.Errorf("unable to refresh userAccess token - http status %v", resp.Status)
 	}
 
-	queryParams := r.URL.Query()
-	config.Logger.LogInfof("queryParams: %v", queryParams)
-	authCode := queryParams.Get("code")
-
-	if authCode == "" {
-		return "", fmt.Errorf("error %v - missing authorisation code", http.StatusBadRequest)
-	} else if state := queryParams.Get("state"); state != csrfToken {
-		return "", fmt.Errorf("error %v - invalid state received", http.StatusBadRequest)
-	} else {
-		return authCode, nil
-	}
-}
-
-func shutDownServerGracefully(server *http.Server, timeoutDuration time.Duration) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutDuration)
-	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		config.Logger.LogInfof("error during shutdown: %v", err)
-	} else {
-		config.Logger.LogInfo("server shut down gracefully")
+	if err := json.NewDecoder(resp.Body).Decode(userAccessTokenInfo); err != nil {
+		return userAccessTokenInfo, fmt.Errorf("error decoding response: %v", err)
 	}
+	return userAccessTokenInfo, nil
 }
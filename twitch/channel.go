@@ -0,0 +1,99 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+var ErrGameNotFound error = errors.New("no twitch category matches the name")
+
+// Twitch's ids for the content classification labels which broadcasters can set
+var contentLabelIds = map[string]string{
+	config.ContentLabelPolitics:  "DebatedSocialIssuesAndPolitics",
+	config.ContentLabelDrugs:     "DrugsIntoxication",
+	config.ContentLabelGambling:  "Gambling",
+	config.ContentLabelProfanity: "ProfanityVulgarity",
+	config.ContentLabelSexual:    "SexualThemes",
+	config.ContentLabelViolence:  "ViolentGraphic",
+}
+
+// Changes to make to the channel in a single PATCH request - empty fields are left unchanged
+type ChannelUpdateT struct {
+	Title         string
+	GameId        string
+	Language      string
+	Tags          []string // nil leaves the tags unchanged
+	ContentLabels []string // nil leaves the labels unchanged, otherwise every other label is removed
+}
+
+// Resolved games, keyed by lowercase name - categories rarely change, so they are only looked up once
+var gameCache = struct {
+	mu    sync.Mutex
+	games map[string]GameT
+}{games: map[string]GameT{}}
+
+// Finds the Twitch category with this name, falling back to the closest search result
+func ResolveGame(ctx context.Context, prefs config.PreferencesFormat, name string) (GameT, error) {
+	name = strings.TrimSpace(name)
+	cacheKey := strings.ToLower(name)
+	gameCache.mu.Lock()
+	game, exists := gameCache.games[cacheKey]
+	gameCache.mu.Unlock()
+	if exists {
+		return game, nil
+	}
+
+	params := url.Values{}
+	params.Add("name", name)
+	queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiGamesPath), params.Encode())
+	gamesResponse, err := makeGetRequest[getGamesResponseT](ctx, queryUrl, "application/json", prefs)
+	if err != nil {
+		return GameT{}, fmt.Errorf("unable to get game %q - err: %w", name, err)
+	}
+
+	if len(gamesResponse.Data) > 0 {
+		game = gamesResponse.Data[0]
+	} else {
+		// the games endpoint needs the exact name, so search for near matches
+		params := url.Values{}
+		params.Add("query", name)
+		queryUrl := fmt.Sprintf("%s?%s", helixClient.apiUrl(twitchApiSearchCategoriesPath), params.Encode())
+		searchResponse, err := makeGetRequest[getGamesResponseT](ctx, queryUrl, "application/json", prefs)
+		if err != nil {
+			return GameT{}, fmt.Errorf("unable to search categories for %q - err: %w", name, err)
+		}
+		if len(searchResponse.Data) == 0 {
+			return GameT{}, fmt.Errorf("%w %q", ErrGameNotFound, name)
+		}
+		game = searchResponse.Data[0]
+		for _, result := range searchResponse.Data {
+			if strings.EqualFold(result.Name, name) {
+				game = result
+				break
+			}
+		}
+	}
+
+	gameCache.mu.Lock()
+	gameCache.games[cacheKey] = game
+	gameCache.mu.Unlock()
+	return game, nil
+}
+
+func getContentClassificationLabels(enabledLabels []string) []contentClassificationLabelT {
+	labels := make([]contentClassificationLabelT, 0, len(config.ContentLabels))
+	for _, label := range config.ContentLabels {
+		labels = append(labels, contentClassificationLabelT{
+			Id:        contentLabelIds[label],
+			IsEnabled: slices.Contains(enabledLabels, label),
+		})
+	}
+	return labels
+}
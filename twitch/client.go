@@ -0,0 +1,326 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	helixRequestTimeout = 15 * time.Second
+	helixMaxAttempts    = 4
+	helixBaseBackoff    = 500 * time.Millisecond
+	helixMaxBackoff     = 30 * time.Second
+)
+
+// Classes of Helix API errors - use errors.Is to check which class a *HelixError belongs to
+var (
+	Err401Unauthorised error = errors.New("unauthorised")
+	ErrRateLimited     error = errors.New("rate limited")
+	ErrNotFound        error = errors.New("not found")
+	ErrServerError     error = errors.New("twitch server error")
+)
+
+// Returned for any unsuccessful Helix API response
+type HelixError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Message    string
+}
+
+func newHelixError(helixReq helixRequestT, resp *http.Response) *HelixError {
+	errorResponse := twitchErrorResponseT{}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&errorResponse)
+	return &HelixError{
+		Method:     helixReq.method,
+		Url:        helixReq.url,
+		StatusCode: resp.StatusCode,
+		Message:    errorResponse.Message,
+	}
+}
+
+func (e *HelixError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s returned http status %v", e.Method, e.Url, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s returned http status %v - %s", e.Method, e.Url, e.StatusCode, e.Message)
+}
+
+func (e *HelixError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return Err401Unauthorised
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode >= 500:
+		return ErrServerError
+	default:
+		return nil
+	}
+}
+
+// Returned when Twitch rejects the refresh token, so the user must authenticate again.
+// It wraps Err401Unauthorised, so it is also treated as any other auth failure.
+type RefreshTokenRejectedError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RefreshTokenRejectedError) Error() string {
+	return fmt.Sprintf("twitch rejected the refresh token (http status %v) - %s", e.StatusCode, e.Message)
+}
+
+func (e *RefreshTokenRejectedError) Unwrap() error {
+	return Err401Unauthorised
+}
+
+// Sends requests to the Helix API with the current credentials, keeping within Twitch's rate limit.
+// Rate limited (429) responses are retried with a jittered backoff, as are server errors (5xx) for idempotent requests.
+// If a request is unauthorised, the access token is refreshed once and the request is replayed.
+type helixClientT struct {
+	httpClient *http.Client
+	refreshMu  sync.Mutex // only one refresh at a time, so concurrent 401s share it
+	bucket     rateLimitBucketT
+
+	baseUrlsMu  sync.RWMutex
+	apiBaseUrl  string
+	authBaseUrl string
+}
+
+var helixClient = &helixClientT{httpClient: &http.Client{Timeout: helixRequestTimeout}}
+
+type helixRequestT struct {
+	method      string
+	url         string
+	body        []byte // kept so the request can be replayed
+	contentType string
+	accept      string
+	accessToken string // overrides the saved access token, in which case it is never refreshed
+}
+
+// Points the client at another Helix API and OAuth server, such as the Twitch CLI mock-api or an httptest server.
+// Empty URLs fall back to the preferences, and then to the real Twitch servers.
+func SetBaseUrls(apiBaseUrl string, authBaseUrl string) {
+	helixClient.baseUrlsMu.Lock()
+	defer helixClient.baseUrlsMu.Unlock()
+	helixClient.apiBaseUrl = apiBaseUrl
+	helixClient.authBaseUrl = authBaseUrl
+}
+
+func (c *helixClientT) apiUrl(path string) string {
+	c.baseUrlsMu.RLock()
+	defer c.baseUrlsMu.RUnlock()
+	return resolveBaseUrl(c.apiBaseUrl, config.SnapshotPreferences().TwitchConfig.ApiBaseUrl, DefaultApiBaseUrl) + path
+}
+
+func (c *helixClientT) authUrl(path string) string {
+	c.baseUrlsMu.RLock()
+	defer c.baseUrlsMu.RUnlock()
+	return resolveBaseUrl(c.authBaseUrl, config.SnapshotPreferences().TwitchConfig.AuthBaseUrl, DefaultAuthBaseUrl) + path
+}
+
+// Returns the first non-empty base URL, without a trailing slash
+func resolveBaseUrl(baseUrls ...string) string {
+	for _, baseUrl := range baseUrls {
+		if baseUrl = strings.TrimSpace(baseUrl); baseUrl != "" {
+			return strings.TrimRight(baseUrl, "/")
+		}
+	}
+	return ""
+}
+
+// Twitch's token bucket, as last reported by the Ratelimit-* response headers
+type rateLimitBucketT struct {
+	mu        sync.Mutex
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// Waits until the bucket has a point to spend, then spends it
+func (b *rateLimitBucketT) take(ctx context.Context) error {
+	b.mu.Lock()
+	var wait time.Duration
+	if b.known && b.remaining <= 0 {
+		wait = time.Until(b.reset)
+	}
+	b.remaining--
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	config.Logger.LogInfof("twitch rate limit reached - waiting %v for it to reset", wait.Round(time.Millisecond))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+func (b *rateLimitBucketT) update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+	resetUnix, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.known = true
+	b.remaining = remaining
+	b.reset = time.Unix(resetUnix, 0)
+}
+
+func (b *rateLimitBucketT) untilReset() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.known {
+		return 0
+	}
+	return time.Until(b.reset)
+}
+
+// Returns the response of a successful (2xx) request, which the caller must close.
+// Any other response is returned as a *HelixError.
+func (c *helixClientT) do(ctx context.Context, helixReq helixRequestT) (*http.Response, error) {
+	accessToken := helixReq.accessToken
+	if accessToken == "" {
+		accessToken = config.GetCredentials().UserAccessToken
+	}
+	resp, err := c.sendWithRetries(ctx, helixReq, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && helixReq.accessToken == "" {
+		resp.Body.Close()
+		config.Logger.LogInfof("request to %v was unauthorised - refreshing the access token and replaying it", helixReq.url)
+		if err := c.refreshCredentials(ctx, accessToken); err != nil {
+			return nil, err
+		}
+		resp, err = c.sendWithRetries(ctx, helixReq, config.GetCredentials().UserAccessToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, newHelixError(helixReq, resp)
+	}
+	return resp, nil
+}
+
+func (c *helixClientT) sendWithRetries(ctx context.Context, helixReq helixRequestT, accessToken string) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := c.bucket.take(ctx); err != nil {
+			return nil, fmt.Errorf("gave up waiting for the rate limit to reset - err: %w", err)
+		}
+		resp, err := c.send(ctx, helixReq, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		c.bucket.update(resp.Header)
+
+		if !isRetryable(helixReq.method, resp.StatusCode) || attempt == helixMaxAttempts {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		backoff := c.retryBackoff(resp.StatusCode, attempt)
+		config.Logger.LogInfof("request to %v returned http status %v - retrying in %v (attempt %v of %v)", helixReq.url, resp.StatusCode, backoff.Round(time.Millisecond), attempt+1, helixMaxAttempts)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up retrying request to %v - err: %w", helixReq.url, ctx.Err())
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Rate limited requests were never processed, so are always safe to retry. Server errors may happen after
+// the request was processed, so only requests which can be repeated safely are retried, e.g. not chat messages.
+func isRetryable(method string, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return statusCode >= 500 && (method == http.MethodGet || method == http.MethodPatch)
+}
+
+// Exponential backoff with jitter, so concurrent requests do not retry in lockstep.
+// Rate limited requests wait at least until the bucket resets.
+func (c *helixClientT) retryBackoff(statusCode int, attempt int) time.Duration {
+	backoff := min(helixBaseBackoff<<(attempt-1), helixMaxBackoff)
+	backoff = backoff/2 + rand.N(backoff/2+1)
+	if statusCode == http.StatusTooManyRequests {
+		backoff = max(backoff, min(c.bucket.untilReset(), helixMaxBackoff))
+	}
+	return backoff
+}
+
+func (c *helixClientT) send(ctx context.Context, helixReq helixRequestT, accessToken string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, helixReq.method, helixReq.url, bytes.NewReader(helixReq.body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to construct request for %v - err: %w", helixReq.url, err)
+	}
+	req.Header.Set("Client-Id", config.SnapshotPreferences().TwitchConfig.ClientId)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if helixReq.contentType != "" {
+		req.Header.Set("Content-Type", helixReq.contentType)
+	}
+	if helixReq.accept != "" {
+		req.Header.Set("Accept", helixReq.accept)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request for %v failed - err: %w", req.URL, err)
+	}
+	return resp, nil
+}
+
+// Refreshes the access token, unless it has already been refreshed since failedAccessToken was used
+func (c *helixClientT) refreshCredentials(ctx context.Context, failedAccessToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if config.GetCredentials().UserAccessToken != failedAccessToken {
+		return nil
+	}
+	return RefreshCredentials(ctx)
+}
+
+// Exchanges the refresh token for a new access token, and saves it
+func RefreshCredentials(ctx context.Context) error {
+	newUserAccessTokenInfo, err := getUserAccessTokenFromRefreshToken(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to refresh access code - err: %w", err)
+	}
+	if err := config.UpdatePreferences(func(prefs *config.PreferencesFormat) {
+		prefs.TwitchConfig.Credentials = config.CredentialsT{
+			UserAccessToken:        newUserAccessTokenInfo.AccessToken,
+			UserAccessRefreshToken: newUserAccessTokenInfo.RefreshToken,
+			UserAccessScope:        newUserAccessTokenInfo.Scope,
+			ExpiryUnixTimestamp:    time.Now().Unix() + int64(newUserAccessTokenInfo.ExpiresIn),
+		}
+	}); err != nil {
+		return fmt.Errorf("unable to save preferences - error: %v", err)
+	}
+	config.Logger.LogInfo("refreshed the twitch access token")
+	return nil
+}
@@ -1,116 +1,177 @@
-package twitch
-
-const (
-	twitchApiAuthoriseUrl = "https://id.twitch.tv/oauth2/authorize"
-	twitchApiTokenUrl     = "https://id.twitch.tv/oauth2/token"
-
-	MaxTitleLength = 140
-)
-
-type userAccessTokenInfoT struct {
-	AccessToken  string   `json:"access_token"`
-	ExpiresIn    int      `json:"expires_in"`
-	RefreshToken string   `json:"refresh_token"`
-	Scope        []string `json:"scope"`
-	TokenType    string   `json:"token_type"`
-}
-
-const (
-	twitchApiUsersUrl         = "https://api.twitch.tv/helix/users"
-	twitchApiStreamsUrl       = "https://api.twitch.tv/helix/streams"
-	twitchApiSubscriptionsUrl = "https://api.twitch.tv/helix/subscriptions"
-	twitchApiChannelsUrl      = "https://api.twitch.tv/helix/channels"
-	twitchApiFollowersUrl     = "https://api.twitch.tv/helix/channels/followers"
-	twitchApiMessagesUrl      = "https://api.twitch.tv/helix/chat/messages"
-)
-
-type getUsersApiResponseT struct {
-	Data []struct {
-		Id              string `json:"id"`
-		Login           string `json:"login"`
-		DisplayName     string `json:"display_name"`
-		UserType        string `json:"type"`
-		BroadcasterType string `json:"broadcaster_type"`
-		Description     string `json:"description"`
-		ProfileImageUrl string `json:"profile_image_url"`
-		OfflineImageUrl string `json:"offline_image_url"`
-		ViewCount       int    `json:"view_count"`
-		Email           string `json:"email"`
-		CreatedAt       string `json:"created_at"`
-	} `json:"data"`
-}
-
-type paginationApiResponse struct {
-	Cursor string `json:"cursor"`
-}
-
-type streamInfoT struct {
-	Id           string   `json:"id"`
-	UserId       string   `json:"user_id"`
-	UserLogin    string   `json:"user_login"`
-	UserName     string   `json:"user_name"`
-	GameId       string   `json:"game_id"`
-	GameName     string   `json:"game_name"`
-	StreamType   string   `json:"type"`
-	Title        string   `json:"title"`
-	Tags         []string `json:"tags"`
-	ViewerCount  int      `json:"viewer_count"`
-	StartedAt    string   `json:"started_at"` // RFC3339 format
-	Language     string   `json:"language"`
-	ThumbnailUrl string   `json:"thumbnail_url"`
-	TagIds       []string `json:"tag_ids"`
-	IsMature     bool     `json:"is_mature"`
-}
-
-type getStreamInfoApiResponseT struct {
-	Data       []streamInfoT         `json:"data"`
-	Pagination paginationApiResponse `json:"pagination"`
-}
-
-type getChannelSubscribersResponseT struct {
-	Data []struct {
-		BroadcasterId    string `json:"broadcaster_id"`
-		BroadcasterLogin string `json:"broadcaster_login"`
-		BroadcasterName  string `json:"broadcaster_name"`
-		GifterId         string `json:"gifter_id"`
-		GifterLogin      string `json:"gifter_login"`
-		GifterName       string `json:"gifter_name"`
-		IsGift           bool   `json:"is_gift"`
-		PlanName         string `json:"plan_name"`
-		Tier             string `json:"tier"`
-		UserId           string `json:"user_id"`
-		UserName         string `json:"user_name"`
-		UserLogin        string `json:"user_login"`
-	} `json:"data"`
-	Pagination paginationApiResponse `json:"pagination"`
-	Points     int                   `json:"points"`
-	Total      int                   `json:"total"`
-}
-
-type getChannelFollowersResponseT struct {
-	Data []struct {
-		FollowedAt string `json:"followed_at"`
-		UserID     string `json:"user_id"`
-		UserLogin  string `json:"user_login"`
-		UserName   string `json:"user_name"`
-	} `json:"data"`
-	Pagination paginationApiResponse `json:"pagination"`
-	Total      int                   `json:"total"`
-}
-
-type postChatMessageResponseT struct {
-	Data []struct {
-		MessageId  string `json:"message_id"`
-		IsSent     bool   `json:"is_sent"`
-		DropReason *struct {
-			Code    string `json:"code"`
-			Message string `json:"message"`
-		} `json:"drop_reason"`
-	} `json:"data"`
-}
-
-type RawApiResponses struct {
-	StreamInfo      *streamInfoT
-	SubscribersInfo *getChannelSubscribersResponseT
-	FollowersInfo   *getChannelFollowersResponseT
-}
+package twitch
+
+const (
+	DefaultAuthBaseUrl     = "https://id.twitch.tv/oauth2"
+	twitchApiAuthorisePath = "/authorize"
+	twitchApiTokenPath     = "/token"
+	twitchApiValidatePath  = "/validate"
+	twitchApiRevokePath    = "/revoke"
+	twitchApiDevicePath    = "/device"
+
+	MaxTitleLength             = 140
+	MaxMarkerDescriptionLength = 140
+)
+
+type userAccessTokenInfoT struct {
+	AccessToken  string   `json:"access_token"`
+	ExpiresIn    int      `json:"expires_in"`
+	RefreshToken string   `json:"refresh_token"`
+	Scope        []string `json:"scope"`
+	TokenType    string   `json:"token_type"`
+}
+
+const (
+	DefaultApiBaseUrl             = "https://api.twitch.tv/helix"
+	twitchApiUsersPath            = "/users"
+	twitchApiStreamsPath          = "/streams"
+	twitchApiMarkersPath          = "/streams/markers"
+	twitchApiSubscriptionsPath    = "/subscriptions"
+	twitchApiChannelsPath         = "/channels"
+	twitchApiFollowersPath        = "/channels/followers"
+	twitchApiMessagesPath         = "/chat/messages"
+	twitchApiAutoModStatusPath    = "/moderation/enforcements/status"
+	twitchApiGamesPath            = "/games"
+	twitchApiSearchCategoriesPath = "/search/categories"
+)
+
+const (
+	streamThumbnailWidth  = 1280
+	streamThumbnailHeight = 720
+)
+
+type twitchErrorResponseT struct {
+	Error   string `json:"error"`
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type getUsersApiResponseT struct {
+	Data []struct {
+		Id              string `json:"id"`
+		Login           string `json:"login"`
+		DisplayName     string `json:"display_name"`
+		UserType        string `json:"type"`
+		BroadcasterType string `json:"broadcaster_type"`
+		Description     string `json:"description"`
+		ProfileImageUrl string `json:"profile_image_url"`
+		OfflineImageUrl string `json:"offline_image_url"`
+		ViewCount       int    `json:"view_count"`
+		Email           string `json:"email"`
+		CreatedAt       string `json:"created_at"`
+	} `json:"data"`
+}
+
+type paginationApiResponse struct {
+	Cursor string `json:"cursor"`
+}
+
+type streamInfoT struct {
+	Id           string   `json:"id"`
+	UserId       string   `json:"user_id"`
+	UserLogin    string   `json:"user_login"`
+	UserName     string   `json:"user_name"`
+	GameId       string   `json:"game_id"`
+	GameName     string   `json:"game_name"`
+	StreamType   string   `json:"type"`
+	Title        string   `json:"title"`
+	Tags         []string `json:"tags"`
+	ViewerCount  int      `json:"viewer_count"`
+	StartedAt    string   `json:"started_at"` // RFC3339 format
+	Language     string   `json:"language"`
+	ThumbnailUrl string   `json:"thumbnail_url"`
+	TagIds       []string `json:"tag_ids"`
+	IsMature     bool     `json:"is_mature"`
+}
+
+type getStreamInfoApiResponseT struct {
+	Data       []streamInfoT         `json:"data"`
+	Pagination paginationApiResponse `json:"pagination"`
+}
+
+type getChannelSubscribersResponseT struct {
+	Data []struct {
+		BroadcasterId    string `json:"broadcaster_id"`
+		BroadcasterLogin string `json:"broadcaster_login"`
+		BroadcasterName  string `json:"broadcaster_name"`
+		GifterId         string `json:"gifter_id"`
+		GifterLogin      string `json:"gifter_login"`
+		GifterName       string `json:"gifter_name"`
+		IsGift           bool   `json:"is_gift"`
+		PlanName         string `json:"plan_name"`
+		Tier             string `json:"tier"`
+		UserId           string `json:"user_id"`
+		UserName         string `json:"user_name"`
+		UserLogin        string `json:"user_login"`
+	} `json:"data"`
+	Pagination paginationApiResponse `json:"pagination"`
+	Points     int                   `json:"points"`
+	Total      int                   `json:"total"`
+}
+
+type getChannelFollowersResponseT struct {
+	Data []struct {
+		FollowedAt string `json:"followed_at"`
+		UserID     string `json:"user_id"`
+		UserLogin  string `json:"user_login"`
+		UserName   string `json:"user_name"`
+	} `json:"data"`
+	Pagination paginationApiResponse `json:"pagination"`
+	Total      int                   `json:"total"`
+}
+
+type postChatMessageResponseT struct {
+	Data []struct {
+		MessageId  string `json:"message_id"`
+		IsSent     bool   `json:"is_sent"`
+		DropReason *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"drop_reason"`
+	} `json:"data"`
+}
+
+type checkAutoModStatusResponseT struct {
+	Data []struct {
+		MsgId       string `json:"msg_id"`
+		IsPermitted bool   `json:"is_permitted"`
+	} `json:"data"`
+}
+
+type updateChannelRequestT struct {
+	Title                       string                        `json:"title,omitempty"`
+	GameId                      string                        `json:"game_id,omitempty"`
+	BroadcasterLanguage         string                        `json:"broadcaster_language,omitempty"`
+	Tags                        []string                      `json:"tags,omitempty"`
+	ContentClassificationLabels []contentClassificationLabelT `json:"content_classification_labels,omitempty"`
+}
+
+type contentClassificationLabelT struct {
+	Id        string `json:"id"`
+	IsEnabled bool   `json:"is_enabled"`
+}
+
+type StreamMarkerT struct {
+	Id              string `json:"id"`
+	CreatedAt       string `json:"created_at"`
+	Description     string `json:"description"`
+	PositionSeconds int    `json:"position_seconds"`
+}
+
+type createStreamMarkerResponseT struct {
+	Data []StreamMarkerT `json:"data"`
+}
+
+type GameT struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type getGamesResponseT struct {
+	Data []GameT `json:"data"`
+}
+
+type RawApiResponses struct {
+	StreamInfo      *streamInfoT
+	SubscribersInfo *getChannelSubscribersResponseT
+	FollowersInfo   *getChannelFollowersResponseT
+}
@@ -0,0 +1,135 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	deviceCodeGrantType       = "urn:ietf:params:oauth:grant-type:device_code"
+	defaultDeviceCodeInterval = 5 // seconds
+)
+
+var ErrDeviceCodeExpired error = errors.New("the device code expired before it was authorised")
+var ErrDeviceCodeDenied error = errors.New("the device code was denied")
+
+// The code the user enters at VerificationUri, on any device, to authorise Tidal
+type DeviceCodeT struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationUri string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"` // seconds
+	Interval        int    `json:"interval"`   // seconds to wait between polls
+}
+
+// Starts the Device Code Grant flow, which needs neither a client secret nor a redirect listener
+func RequestDeviceCode(ctx context.Context) (*DeviceCodeT, error) {
+	params := url.Values{}
+	params.Add("client_id", config.SnapshotPreferences().TwitchConfig.ClientId)
+	params.Add("scopes", requestedScopes())
+
+	req, err := http.NewRequestWithContext(ctx, "POST", helixClient.authUrl(twitchApiDevicePath), strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("unable to construct request - err: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := helixClient.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request for %v failed - err: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorResponse := twitchErrorResponseT{}
+		json.NewDecoder(resp.Body).Decode(&errorResponse)
+		return nil, fmt.Errorf("unable to request a device code - http status %v - %s", resp.Status, errorResponse.Message)
+	}
+
+	deviceCode := &DeviceCodeT{}
+	if err := json.NewDecoder(resp.Body).Decode(deviceCode); err != nil {
+		return nil, fmt.Errorf("unable to decode response from request to %v - err: %w", req.URL, err)
+	}
+	return deviceCode, nil
+}
+
+// Polls the token endpoint until the user authorises the device code, it expires, or ctx is cancelled
+func PollDeviceCodeToken(ctx context.Context, deviceCode *DeviceCodeT) (*userAccessTokenInfoT, error) {
+	interval := time.Duration(deviceCode.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDeviceCodeInterval * time.Second
+	}
+	expiry := time.Now().Add(time.Duration(deviceCode.ExpiresIn) * time.Second)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		if deviceCode.ExpiresIn > 0 && time.Now().After(expiry) {
+			return nil, ErrDeviceCodeExpired
+		}
+
+		userAccessTokenInfo, pending, err := requestDeviceCodeToken(ctx, deviceCode)
+		if err != nil {
+			return nil, err
+		}
+		if pending == "slow_down" {
+			interval += defaultDeviceCodeInterval * time.Second
+		}
+		if pending == "" {
+			return userAccessTokenInfo, nil
+		}
+	}
+}
+
+// Returns the reason the token is still pending, or the token once the user has authorised it
+func requestDeviceCodeToken(ctx context.Context, deviceCode *DeviceCodeT) (*userAccessTokenInfoT, string, error) {
+	params := url.Values{}
+	params.Add("client_id", config.SnapshotPreferences().TwitchConfig.ClientId)
+	params.Add("scopes", requestedScopes())
+	params.Add("device_code", deviceCode.DeviceCode)
+	params.Add("grant_type", deviceCodeGrantType)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", helixClient.authUrl(twitchApiTokenPath), strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to construct request - err: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := helixClient.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("request for %v failed - err: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorResponse := twitchErrorResponseT{}
+		json.NewDecoder(resp.Body).Decode(&errorResponse)
+		switch strings.ToLower(errorResponse.Message) {
+		case "authorization_pending", "slow_down":
+			return nil, strings.ToLower(errorResponse.Message), nil
+		case "invalid device code", "expired_token":
+			return nil, "", ErrDeviceCodeExpired
+		case "access_denied":
+			return nil, "", ErrDeviceCodeDenied
+		default:
+			return nil, "", fmt.Errorf("unable to get a token for the device code - http status %v - %s", resp.Status, errorResponse.Message)
+		}
+	}
+
+	userAccessTokenInfo := &userAccessTokenInfoT{}
+	if err := json.NewDecoder(resp.Body).Decode(userAccessTokenInfo); err != nil {
+		return nil, "", fmt.Errorf("error decoding response: %v", err)
+	}
+	return userAccessTokenInfo, "", nil
+}
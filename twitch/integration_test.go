@@ -0,0 +1,607 @@
+package twitch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/helpers"
+	"github.com/finahdinner/tidal/twitch"
+	"github.com/finahdinner/tidal/twitch/twitchtest"
+)
+
+func TestMain(m *testing.M) {
+	// start from fresh preferences, removed once the tests finish
+	dir, err := os.MkdirTemp("", "tidal-twitch-test-*")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := config.UseAppConfigDir(dir); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestUpdateTwitchVariables(t *testing.T) {
+	twitchtest.New(t)
+
+	if err := twitch.UpdateTwitchVariables(context.Background()); err != nil {
+		t.Fatalf("UpdateTwitchVariables returned an error: %v", err)
+	}
+
+	variables := config.Preferences.TwitchVariables
+	if variables.NumViewers.Value != "42" {
+		t.Errorf("NumViewers = %q, want %q", variables.NumViewers.Value, "42")
+	}
+	if variables.StreamCategory.Value != "Just Chatting" {
+		t.Errorf("StreamCategory = %q, want %q", variables.StreamCategory.Value, "Just Chatting")
+	}
+	if variables.NumSubscribers.Value != "7" {
+		t.Errorf("NumSubscribers = %q, want %q", variables.NumSubscribers.Value, "7")
+	}
+	if variables.NumFollowers.Value != "99" {
+		t.Errorf("NumFollowers = %q, want %q", variables.NumFollowers.Value, "99")
+	}
+	uptime, err := strconv.Atoi(variables.StreamUptime.Value)
+	if err != nil || uptime < 3600 || uptime > 3660 {
+		t.Errorf("StreamUptime = %q, want roughly 3600", variables.StreamUptime.Value)
+	}
+}
+
+func TestUpdateTwitchVariablesRefreshesExpiringToken(t *testing.T) {
+	fake := twitchtest.New(t)
+	config.Preferences.TwitchConfig.Credentials.ExpiryUnixTimestamp = time.Now().Unix()
+
+	if err := twitch.UpdateTwitchVariables(context.Background()); err != nil {
+		t.Fatalf("UpdateTwitchVariables returned an error: %v", err)
+	}
+
+	credentials := config.Preferences.TwitchConfig.Credentials
+	if credentials.UserAccessToken != "access-1" || credentials.UserAccessRefreshToken != "refresh-1" {
+		t.Errorf("credentials were not refreshed - got access token %q and refresh token %q", credentials.UserAccessToken, credentials.UserAccessRefreshToken)
+	}
+	if credentials.ExpiryUnixTimestamp <= time.Now().Unix() {
+		t.Errorf("refreshed credentials have already expired")
+	}
+	if len(fake.TokenGrantsUsed) != 1 || fake.TokenGrantsUsed[0] != "refresh_token" {
+		t.Errorf("token grants used = %v, want a single refresh_token grant", fake.TokenGrantsUsed)
+	}
+	if config.Preferences.TwitchVariables.NumViewers.Value != "42" {
+		t.Errorf("NumViewers = %q after refreshing, want %q", config.Preferences.TwitchVariables.NumViewers.Value, "42")
+	}
+}
+
+func TestUpdateStreamTitle(t *testing.T) {
+	fake := twitchtest.New(t)
+	prefs := config.Preferences
+	prefs.Title.Value = "Testing Tidal against a fake Helix"
+
+	if err := twitch.UpdateStreamTitle(context.Background(), prefs); err != nil {
+		t.Fatalf("UpdateStreamTitle returned an error: %v", err)
+	}
+	if len(fake.Titles) != 1 || fake.Titles[0] != prefs.Title.Value {
+		t.Errorf("titles received = %q, want [%q]", fake.Titles, prefs.Title.Value)
+	}
+}
+
+func TestUpdateStreamTitleReplaysAfterRevokedToken(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.RevokeAccessToken()
+	prefs := config.Preferences
+	prefs.Title.Value = "Title after a revoked token"
+
+	if err := twitch.UpdateStreamTitle(context.Background(), prefs); err != nil {
+		t.Fatalf("UpdateStreamTitle returned an error: %v", err)
+	}
+	if len(fake.Titles) != 1 {
+		t.Errorf("titles received = %q, want the title once after refreshing", fake.Titles)
+	}
+	if config.Preferences.TwitchConfig.Credentials.UserAccessToken != "access-1" {
+		t.Errorf("access token = %q, want the refreshed token", config.Preferences.TwitchConfig.Credentials.UserAccessToken)
+	}
+}
+
+func TestUpdateStreamTitleRejectedRefreshToken(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.RevokeAccessToken()
+	fake.RevokeRefreshToken()
+	prefs := config.Preferences
+	prefs.Title.Value = "Title that is never sent"
+
+	err := twitch.UpdateStreamTitle(context.Background(), prefs)
+	var rejectedErr *twitch.RefreshTokenRejectedError
+	if !errors.As(err, &rejectedErr) {
+		t.Fatalf("UpdateStreamTitle error = %v, want a RefreshTokenRejectedError", err)
+	}
+	if !errors.Is(err, twitch.Err401Unauthorised) {
+		t.Errorf("a rejected refresh token should also count as unauthorised")
+	}
+	if len(fake.Titles) != 0 {
+		t.Errorf("titles received = %q, want none", fake.Titles)
+	}
+}
+
+func TestUpdateStreamTitleRetriesServerErrors(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.FailNextRequests(1)
+	prefs := config.Preferences
+	prefs.Title.Value = "Title after a server error"
+
+	if err := twitch.UpdateStreamTitle(context.Background(), prefs); err != nil {
+		t.Fatalf("UpdateStreamTitle returned an error: %v", err)
+	}
+	if fake.HelixRequests != 2 || len(fake.Titles) != 1 {
+		t.Errorf("got %v requests and titles %q, want 2 requests and the title once", fake.HelixRequests, fake.Titles)
+	}
+}
+
+func TestSendChatMessageIsNotRetriedAfterServerError(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.FailNextRequests(1)
+
+	// the message may have been sent before the server error, so it is not sent again
+	if err := twitch.SendChatMessage(context.Background(), config.Preferences, "hello chat"); !errors.Is(err, twitch.ErrServerError) {
+		t.Fatalf("SendChatMessage returned %v, want a server error", err)
+	}
+	if fake.HelixRequests != 1 {
+		t.Errorf("got %v requests, want 1", fake.HelixRequests)
+	}
+}
+
+func TestUpdateChannel(t *testing.T) {
+	fake := twitchtest.New(t)
+	prefs := config.Preferences
+
+	game, err := twitch.ResolveGame(context.Background(), prefs, "Fortnite")
+	if err != nil {
+		t.Fatalf("ResolveGame returned an error: %v", err)
+	}
+	update := twitch.ChannelUpdateT{
+		Title:         "Building things",
+		GameId:        game.Id,
+		Language:      "en",
+		Tags:          helpers.NormaliseTags([]string{"Battle Royale", "no-build!", "battleroyale", "", "A very long tag that goes on and on", "Español"}),
+		ContentLabels: []string{config.ContentLabelProfanity},
+	}
+	if err := twitch.UpdateChannel(context.Background(), prefs, update); err != nil {
+		t.Fatalf("UpdateChannel returned an error: %v", err)
+	}
+
+	if len(fake.ChannelUpdates) != 1 {
+		t.Fatalf("channel updates received = %v, want 1", len(fake.ChannelUpdates))
+	}
+	received := fake.ChannelUpdates[0]
+	if received.Title != update.Title || received.GameId != "33214" || received.BroadcasterLanguage != "en" {
+		t.Errorf("channel update = %+v, want title %q, game 33214 and language en", received, update.Title)
+	}
+	if strings.Join(received.Tags, ",") != "BattleRoyale,nobuild,Averylongtagthatgoesonand,Español" {
+		t.Errorf("tags = %q, want duplicates dropped, long tags truncated, accents kept and spaces removed", received.Tags)
+	}
+	if len(received.ContentClassificationLabels) != len(config.ContentLabels) {
+		t.Fatalf("content labels = %+v, want every label set", received.ContentClassificationLabels)
+	}
+	for _, label := range received.ContentClassificationLabels {
+		if label.IsEnabled != (label.Id == "ProfanityVulgarity") {
+			t.Errorf("content label %v enabled = %v, want only ProfanityVulgarity enabled", label.Id, label.IsEnabled)
+		}
+	}
+}
+
+func TestUpdateStreamTitleLeavesChannelSettingsUnchanged(t *testing.T) {
+	fake := twitchtest.New(t)
+	prefs := config.Preferences
+	prefs.Title.Value = "Only the title"
+
+	if err := twitch.UpdateStreamTitle(context.Background(), prefs); err != nil {
+		t.Fatalf("UpdateStreamTitle returned an error: %v", err)
+	}
+	received := fake.ChannelUpdates[0]
+	if received.GameId != "" || received.BroadcasterLanguage != "" || received.Tags != nil || received.ContentClassificationLabels != nil {
+		t.Errorf("channel update = %+v, want only the title", received)
+	}
+}
+
+func TestResolveGameFallsBackToSearch(t *testing.T) {
+	fake := twitchtest.New(t)
+
+	game, err := twitch.ResolveGame(context.Background(), config.Preferences, "minecraft")
+	if err != nil {
+		t.Fatalf("ResolveGame returned an error: %v", err)
+	}
+	if game.Id != "27471" || game.Name != "Minecraft" {
+		t.Errorf("game = %+v, want Minecraft (27471)", game)
+	}
+	if _, err := twitch.ResolveGame(context.Background(), config.Preferences, "Minecraft"); err != nil {
+		t.Fatalf("ResolveGame returned an error the second time: %v", err)
+	}
+	if strings.Join(fake.GameLookups, ",") != "games:minecraft,search:minecraft" {
+		t.Errorf("game lookups = %q, want an exact lookup then a search, then the cached game", fake.GameLookups)
+	}
+
+	_, err = twitch.ResolveGame(context.Background(), config.Preferences, "Not A Real Game")
+	if !errors.Is(err, twitch.ErrGameNotFound) {
+		t.Errorf("ResolveGame error = %v, want ErrGameNotFound", err)
+	}
+}
+
+func TestCreateStreamMarker(t *testing.T) {
+	fake := twitchtest.New(t)
+	description := strings.Repeat("a very long title ", 10)
+
+	marker, err := twitch.CreateStreamMarker(context.Background(), config.Preferences, description)
+	if err != nil {
+		t.Fatalf("CreateStreamMarker returned an error: %v", err)
+	}
+	if marker.Id != "marker-1" || marker.PositionSeconds != 3600 {
+		t.Errorf("marker = %+v, want marker-1 at 3600 seconds", marker)
+	}
+	if len(fake.Markers) != 1 || fake.Markers[0]["user_id"] != twitchtest.UserId {
+		t.Fatalf("markers received = %v, want one for user %v", fake.Markers, twitchtest.UserId)
+	}
+	if got := fake.Markers[0]["description"]; got != description[:twitch.MaxMarkerDescriptionLength] {
+		t.Errorf("description = %q, want the title truncated to %v characters", got, twitch.MaxMarkerDescriptionLength)
+	}
+}
+
+func TestCreateStreamMarkerWhileOffline(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.Offline = true
+
+	_, err := twitch.CreateStreamMarker(context.Background(), config.Preferences, "offline title")
+	if !errors.Is(err, twitch.ErrStreamNotLive) {
+		t.Errorf("CreateStreamMarker error = %v, want ErrStreamNotLive", err)
+	}
+}
+
+func TestCreateStreamMarkerWithoutScope(t *testing.T) {
+	fake := twitchtest.New(t)
+	prefs := config.Preferences
+	prefs.TwitchConfig.Credentials.UserAccessScope = []string{twitch.ScopeUserWriteChat}
+
+	_, err := twitch.CreateStreamMarker(context.Background(), prefs, "title without scope")
+	if !errors.Is(err, twitch.ErrMissingScope) {
+		t.Errorf("CreateStreamMarker error = %v, want ErrMissingScope", err)
+	}
+	if fake.HelixRequests != 0 || fake.TokenGrantsUsed != nil {
+		t.Errorf("got %v helix requests and token grants %v, want no requests", fake.HelixRequests, fake.TokenGrantsUsed)
+	}
+}
+
+func TestSendChatMessage(t *testing.T) {
+	fake := twitchtest.New(t)
+
+	if err := twitch.SendChatMessage(context.Background(), config.Preferences, "hello chat"); err != nil {
+		t.Fatalf("SendChatMessage returned an error: %v", err)
+	}
+	if len(fake.ChatMessages) != 1 {
+		t.Fatalf("chat messages received = %v, want 1", fake.ChatMessages)
+	}
+	message := fake.ChatMessages[0]
+	if message["message"] != "hello chat" || message["broadcaster_id"] != twitchtest.UserId || message["sender_id"] != twitchtest.UserId {
+		t.Errorf("chat message = %v, want %q sent by and to %v", message, "hello chat", twitchtest.UserId)
+	}
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	twitchtest.New(t)
+
+	redirectUri := freeRedirectUri(t, "/callback")
+	config.Preferences.TwitchConfig.ClientRedirectUri = redirectUri
+	config.Preferences.TwitchConfig.UserId = ""
+	config.Preferences.TwitchConfig.Credentials = config.CredentialsT{}
+
+	csrfToken := "fake-csrf-token"
+	resultChan, err := twitch.ListenForAuthCode(context.Background(), redirectUri, csrfToken)
+	if err != nil {
+		t.Fatalf("unable to create auth code listener: %v", err)
+	}
+
+	// requests to other paths, like a favicon fetch, must not end the flow
+	resp, err := http.Get(strings.Replace(redirectUri, "/callback", "/favicon.ico", 1))
+	if err != nil {
+		t.Fatalf("unable to request the favicon: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("favicon request returned %v, want 404", resp.StatusCode)
+	}
+
+	// stands in for the user authorising Tidal in their browser
+	resp, err = http.Get(twitch.GetAuthCodeUrl(csrfToken))
+	if err != nil {
+		t.Fatalf("unable to follow the auth code url: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(page), "connected to Twitch") {
+		t.Errorf("redirect returned %v %q, want the success page", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	result := waitForAuthCodeResult(t, resultChan)
+	if result.Err != nil || result.Code != "fake-auth-code" {
+		t.Fatalf("result = %+v, want the auth code %q", result, "fake-auth-code")
+	}
+	authCode := result.Code
+
+	userAccessTokenInfo, err := twitch.GetUserAccessTokenFromAuthCode(authCode)
+	if err != nil {
+		t.Fatalf("GetUserAccessTokenFromAuthCode returned an error: %v", err)
+	}
+	if userAccessTokenInfo.AccessToken != "access-1" || userAccessTokenInfo.RefreshToken != "refresh-1" {
+		t.Errorf("got access token %q and refresh token %q, want access-1 and refresh-1", userAccessTokenInfo.AccessToken, userAccessTokenInfo.RefreshToken)
+	}
+
+	userId, err := twitch.GetTwitchUserId(userAccessTokenInfo.AccessToken)
+	if err != nil {
+		t.Fatalf("GetTwitchUserId returned an error: %v", err)
+	}
+	if userId != twitchtest.UserId {
+		t.Errorf("user id = %q, want %q", userId, twitchtest.UserId)
+	}
+}
+
+func TestValidateCredentials(t *testing.T) {
+	twitchtest.New(t)
+	config.Preferences.TwitchConfig.Credentials.ExpiryUnixTimestamp = 0
+
+	if err := twitch.ValidateCredentials(context.Background()); err != nil {
+		t.Fatalf("ValidateCredentials returned an error: %v", err)
+	}
+
+	credentials := config.Preferences.TwitchConfig.Credentials
+	if len(credentials.UserAccessScope) != 2 || credentials.UserAccessScope[1] != "user:write:chat" {
+		t.Errorf("scopes = %v, want the scopes returned by validation", credentials.UserAccessScope)
+	}
+	if expiresIn := credentials.ExpiryUnixTimestamp - time.Now().Unix(); expiresIn < 4990 || expiresIn > 5000 {
+		t.Errorf("token expires in %v seconds, want roughly 5000", expiresIn)
+	}
+}
+
+func TestValidateCredentialsRefreshesInvalidToken(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.RevokeAccessToken()
+
+	if err := twitch.ValidateCredentials(context.Background()); err != nil {
+		t.Fatalf("ValidateCredentials returned an error: %v", err)
+	}
+	if config.Preferences.TwitchConfig.Credentials.UserAccessToken != "access-1" {
+		t.Errorf("access token = %q, want the refreshed token", config.Preferences.TwitchConfig.Credentials.UserAccessToken)
+	}
+
+	fake.RevokeAccessToken()
+	fake.RevokeRefreshToken()
+	var rejectedErr *twitch.RefreshTokenRejectedError
+	if err := twitch.ValidateCredentials(context.Background()); !errors.As(err, &rejectedErr) {
+		t.Errorf("ValidateCredentials error = %v, want a RefreshTokenRejectedError", err)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	fake := twitchtest.New(t)
+
+	if err := twitch.Disconnect(context.Background()); err != nil {
+		t.Fatalf("Disconnect returned an error: %v", err)
+	}
+	if len(fake.RevokedTokens) != 1 || fake.RevokedTokens[0] != "access-0" {
+		t.Errorf("revoked tokens = %v, want [access-0]", fake.RevokedTokens)
+	}
+	if credentials := config.Preferences.TwitchConfig.Credentials; credentials.UserAccessToken != "" || credentials.UserAccessRefreshToken != "" {
+		t.Errorf("credentials were not wiped - got %+v", credentials)
+	}
+	if !errors.Is(twitch.ValidateCredentials(context.Background()), twitch.ErrNotAuthenticated) {
+		t.Errorf("ValidateCredentials should report that there are no credentials after disconnecting")
+	}
+}
+
+func TestDisconnectAlreadyRevokedToken(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.RevokeAccessToken()
+
+	if err := twitch.Disconnect(context.Background()); err != nil {
+		t.Fatalf("Disconnect returned an error for an already revoked token: %v", err)
+	}
+	if config.Preferences.TwitchConfig.Credentials.UserAccessToken != "" {
+		t.Errorf("credentials were not wiped")
+	}
+}
+
+func TestDeviceCodeFlow(t *testing.T) {
+	fake := twitchtest.New(t)
+	fake.PendingPolls = 1
+	config.Preferences.TwitchConfig.ClientSecret = ""
+	config.Preferences.TwitchConfig.ClientRedirectUri = ""
+	config.Preferences.TwitchConfig.UserId = ""
+	config.Preferences.TwitchConfig.Credentials = config.CredentialsT{}
+
+	deviceCode, err := twitch.RequestDeviceCode(context.Background())
+	if err != nil {
+		t.Fatalf("RequestDeviceCode returned an error: %v", err)
+	}
+	if deviceCode.UserCode != "ABCDEFGH" || deviceCode.VerificationUri == "" {
+		t.Errorf("device code = %+v, want the user code and verification uri", deviceCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	userAccessTokenInfo, err := twitch.PollDeviceCodeToken(ctx, deviceCode)
+	if err != nil {
+		t.Fatalf("PollDeviceCodeToken returned an error: %v", err)
+	}
+	if err := twitch.SaveAuthentication(userAccessTokenInfo); err != nil {
+		t.Fatalf("SaveAuthentication returned an error: %v", err)
+	}
+	if config.Preferences.TwitchConfig.UserId != twitchtest.UserId || config.Preferences.TwitchConfig.Credentials.UserAccessRefreshToken != "refresh-1" {
+		t.Errorf("authentication was not saved - got %+v", config.Preferences.TwitchConfig)
+	}
+
+	// public clients refresh without a client secret
+	fake.RevokeAccessToken()
+	if err := twitch.RefreshCredentials(context.Background()); err != nil {
+		t.Fatalf("RefreshCredentials returned an error for a public client: %v", err)
+	}
+	for _, clientSecret := range fake.ClientSecrets {
+		if clientSecret != "" {
+			t.Errorf("a public client sent the client secret %q", clientSecret)
+		}
+	}
+}
+
+func TestDeviceCodeFlowExpired(t *testing.T) {
+	twitchtest.New(t)
+	deviceCode := &twitch.DeviceCodeT{DeviceCode: "unknown-device-code", Interval: 1, ExpiresIn: 1800}
+
+	_, err := twitch.PollDeviceCodeToken(context.Background(), deviceCode)
+	if !errors.Is(err, twitch.ErrDeviceCodeExpired) {
+		t.Errorf("PollDeviceCodeToken error = %v, want ErrDeviceCodeExpired", err)
+	}
+}
+
+func TestScopesFollowEnabledFeatures(t *testing.T) {
+	twitchtest.New(t)
+	config.Preferences.Title.SendChatMessagePerTitleUpdate = false
+	config.Preferences.Moderation.UseTwitchAutoMod = false
+	config.Preferences.TwitchConfig.Credentials.UserAccessScope = []string{twitch.ScopeChannelManageBroadcast, twitch.ScopeChannelReadSubscriptions}
+
+	if missing := twitch.GetMissingScopes(config.Preferences); len(missing) != 0 {
+		t.Errorf("missing scopes = %+v, want none", missing)
+	}
+
+	config.Preferences.Title.SendChatMessagePerTitleUpdate = true
+	missing := twitch.GetMissingScopes(config.Preferences)
+	if len(missing) != 1 || len(missing[0].Scopes) != 1 || missing[0].Scopes[0] != twitch.ScopeUserWriteChat {
+		t.Errorf("missing scopes = %+v, want only %v", missing, twitch.ScopeUserWriteChat)
+	}
+
+	// re-authenticating requests exactly the scopes of the enabled features
+	authUrl, err := url.Parse(twitch.GetAuthCodeUrl("csrf"))
+	if err != nil {
+		t.Fatalf("unable to parse the auth code url: %v", err)
+	}
+	want := "channel:manage:broadcast channel:read:subscriptions user:write:chat"
+	if scope := authUrl.Query().Get("scope"); scope != want {
+		t.Errorf("requested scope = %q, want %q", scope, want)
+	}
+}
+
+// Returns a redirect uri on a free local port, with the given callback path
+func freeRedirectUri(t *testing.T, callbackPath string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("unable to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return "http://" + listener.Addr().String() + callbackPath
+}
+
+func waitForAuthCodeResult(t *testing.T, resultChan <-chan twitch.AuthCodeResultT) twitch.AuthCodeResultT {
+	t.Helper()
+	select {
+	case result := <-resultChan:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("the listener never reported a result")
+		return twitch.AuthCodeResultT{}
+	}
+}
+
+func TestAuthCodeListenerReportsTwitchErrors(t *testing.T) {
+	redirectUri := freeRedirectUri(t, "/")
+	resultChan, err := twitch.ListenForAuthCode(context.Background(), redirectUri, "csrf")
+	if err != nil {
+		t.Fatalf("unable to create auth code listener: %v", err)
+	}
+
+	resp, err := http.Get(redirectUri + "?error=access_denied&error_description=The+user+denied+you+access&state=csrf")
+	if err != nil {
+		t.Fatalf("unable to request the redirect uri: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(page), "The user denied you access") {
+		t.Errorf("redirect returned %v with page %q, want a 400 error page with the description", resp.StatusCode, page)
+	}
+
+	result := waitForAuthCodeResult(t, resultChan)
+	var authorisationErr *twitch.AuthorisationError
+	if !errors.As(result.Err, &authorisationErr) || authorisationErr.Code != "access_denied" {
+		t.Errorf("result error = %v, want an access_denied AuthorisationError", result.Err)
+	}
+}
+
+func TestAuthCodeListenerRejectsWrongState(t *testing.T) {
+	redirectUri := freeRedirectUri(t, "/")
+	resultChan, err := twitch.ListenForAuthCode(context.Background(), redirectUri, "csrf")
+	if err != nil {
+		t.Fatalf("unable to create auth code listener: %v", err)
+	}
+
+	// redirects without the right state are refused, without ending the flow
+	for _, query := range []string{"?code=stolen&state=not-the-csrf-token", "?code=stolen", "?error=access_denied"} {
+		resp, err := http.Get(redirectUri + query)
+		if err != nil {
+			t.Fatalf("unable to request the redirect uri: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("redirect %q returned %v, want 400", query, resp.StatusCode)
+		}
+	}
+	select {
+	case result := <-resultChan:
+		t.Fatalf("the listener finished with %+v after a redirect with the wrong state", result)
+	default:
+	}
+
+	resp, err := http.Get(redirectUri + "?code=fake-auth-code&state=csrf")
+	if err != nil {
+		t.Fatalf("unable to request the redirect uri: %v", err)
+	}
+	resp.Body.Close()
+	if result := waitForAuthCodeResult(t, resultChan); result.Err != nil || result.Code != "fake-auth-code" {
+		t.Errorf("result = %+v, want the auth code from the redirect with the right state", result)
+	}
+}
+
+func TestAuthCodeListenerReleasesPortWhenCancelled(t *testing.T) {
+	redirectUri := freeRedirectUri(t, "/")
+	ctx, cancel := context.WithCancel(context.Background())
+	resultChan, err := twitch.ListenForAuthCode(ctx, redirectUri, "csrf")
+	if err != nil {
+		t.Fatalf("unable to create auth code listener: %v", err)
+	}
+
+	cancel()
+	if result := waitForAuthCodeResult(t, resultChan); !errors.Is(result.Err, context.Canceled) {
+		t.Errorf("result error = %v, want context.Canceled", result.Err)
+	}
+
+	// the port is free again, so authentication can be retried
+	retryCtx, retryCancel := context.WithCancel(context.Background())
+	defer retryCancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = twitch.ListenForAuthCode(retryCtx, redirectUri, "csrf")
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the port was never released: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,184 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+// How long the redirect listener waits for the user to finish authorising in their browser
+const AuthCodeListenerTimeout = 5 * time.Minute
+
+var (
+	ErrAuthCodeTimeout       error = errors.New("timed out waiting for twitch to redirect back to tidal")
+	ErrAuthCodeStateMismatch error = errors.New("the redirect's state did not match - it may not have come from this authentication")
+	ErrAuthCodeMissing       error = errors.New("the redirect did not include an authorisation code")
+)
+
+// Returned when Twitch redirects back with an error, e.g. because the user clicked Cancel
+type AuthorisationError struct {
+	Code        string // twitch's error parameter, e.g. access_denied
+	Description string
+}
+
+func (e *AuthorisationError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("twitch did not authorise tidal - %s", e.Code)
+	}
+	return fmt.Sprintf("twitch did not authorise tidal - %s: %s", e.Code, e.Description)
+}
+
+// The outcome of the browser flow - either an auth code, or why there is none
+type AuthCodeResultT struct {
+	Code string
+	Err  error
+}
+
+var redirectPageTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Tidal - {{.Title}}</title>
+<style>
+body { font-family: sans-serif; background: #18181b; color: #efeff1; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; }
+main { max-width: 32em; text-align: center; }
+h1 { color: {{if .Success}}#00c8af{{else}}#eb0400{{end}}; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</main>
+</body>
+</html>
+`))
+
+type redirectPageT struct {
+	Success bool
+	Title   string
+	Message string
+}
+
+// Listens on the redirect URI for Twitch's redirect after the user authorises Tidal.
+// Only the redirect URI's path is handled - other requests, like a favicon fetch, get a 404.
+// Redirects with a missing or wrong state get a 400, and the listener keeps waiting for the right one.
+// A single result is sent on the returned channel, after which the listener shuts down.
+// It also shuts down after AuthCodeListenerTimeout, or when ctx is cancelled.
+func ListenForAuthCode(ctx context.Context, redirectUri string, csrfToken string) (<-chan AuthCodeResultT, error) {
+	parsedUri, err := url.Parse(redirectUri)
+	if err != nil || parsedUri.Host == "" {
+		return nil, fmt.Errorf("redirect uri %q is not valid", redirectUri)
+	}
+	callbackPath := parsedUri.Path
+	if callbackPath == "" {
+		callbackPath = "/"
+	}
+
+	// bind first, so a port which is already in use is reported straight away
+	listener, err := net.Listen("tcp", parsedUri.Host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %v - err: %w", parsedUri.Host, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, AuthCodeListenerTimeout)
+	resultChan := make(chan AuthCodeResultT, 1)
+	var once sync.Once
+	finish := func(result AuthCodeResultT) {
+		once.Do(func() {
+			resultChan <- result
+			cancel()
+		})
+	}
+
+	mux := http.NewServeMux()
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != callbackPath || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		result := handleAuthCodeRedirect(r, csrfToken)
+		page := redirectPageT{
+			Success: true,
+			Title:   "Tidal is connected to Twitch",
+			Message: "You may now close this tab and return to Tidal.",
+		}
+		statusCode := http.StatusOK
+		if result.Err != nil {
+			config.Logger.LogInfof("auth code redirect was not valid - err: %v", result.Err)
+			page = redirectPageT{
+				Title:   "Tidal could not connect to Twitch",
+				Message: fmt.Sprintf("Return to Tidal to try again. (%v)", result.Err),
+			}
+			if errors.Is(result.Err, ErrAuthCodeStateMismatch) {
+				page.Message = fmt.Sprintf("Tidal is still waiting - authorise it from the page Tidal opened. (%v)", result.Err)
+			}
+			statusCode = http.StatusBadRequest
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(statusCode)
+		if err := redirectPageTemplate.Execute(w, page); err != nil {
+			config.Logger.LogErrorf("unable to write redirect page - err: %v", err)
+		}
+		// a redirect without the right state did not come from this authentication, so cannot end it
+		if errors.Is(result.Err, ErrAuthCodeStateMismatch) {
+			return
+		}
+		finish(result)
+	})
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			config.Logger.LogErrorf("auth code listener error: %v", err)
+			finish(AuthCodeResultT{Err: fmt.Errorf("auth code listener stopped - err: %w", err)})
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			finish(AuthCodeResultT{Err: ErrAuthCodeTimeout})
+		default:
+			finish(AuthCodeResultT{Err: ctx.Err()})
+		}
+		// let the final page finish sending before closing
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer shutdownCancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			config.Logger.LogInfof("error during shutdown: %v", err)
+		} else {
+			config.Logger.LogInfo("auth code listener shut down gracefully")
+		}
+	}()
+
+	config.Logger.LogInfof("listener set up at %s%s", parsedUri.Host, callbackPath)
+	return resultChan, nil
+}
+
+func handleAuthCodeRedirect(r *http.Request, csrfToken string) AuthCodeResultT {
+	queryParams := r.URL.Query()
+	if state := queryParams.Get("state"); state != csrfToken {
+		return AuthCodeResultT{Err: ErrAuthCodeStateMismatch}
+	}
+	if errorCode := queryParams.Get("error"); errorCode != "" {
+		return AuthCodeResultT{Err: &AuthorisationError{Code: errorCode, Description: queryParams.Get("error_description")}}
+	}
+	authCode := queryParams.Get("code")
+	if authCode == "" {
+		return AuthCodeResultT{Err: ErrAuthCodeMissing}
+	}
+	return AuthCodeResultT{Code: authCode}
+}
@@ -0,0 +1,107 @@
+package twitch
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+const (
+	ScopeChannelManageBroadcast   = "channel:manage:broadcast"
+	ScopeChannelReadSubscriptions = "channel:read:subscriptions"
+	ScopeUserWriteChat            = "user:write:chat"
+	ScopeModerationRead           = "moderation:read"
+)
+
+// A Tidal feature, and the OAuth scopes it needs while it is enabled
+type FeatureT struct {
+	Name    string
+	Scopes  []string
+	Enabled func(prefs config.PreferencesFormat) bool
+}
+
+// Every feature which calls the Twitch API - add to these when a feature needs new scopes
+var Features = []FeatureT{
+	{
+		Name:    "Update stream title",
+		Scopes:  []string{ScopeChannelManageBroadcast},
+		Enabled: func(_ config.PreferencesFormat) bool { return true },
+	},
+	{
+		Name:   "Channel profiles",
+		Scopes: []string{ScopeChannelManageBroadcast},
+		Enabled: func(prefs config.PreferencesFormat) bool {
+			return slices.ContainsFunc(prefs.ChannelProfiles, func(p config.ChannelProfileT) bool { return p.Enabled })
+		},
+	},
+	{
+		Name:    "AI-generated tags",
+		Scopes:  []string{ScopeChannelManageBroadcast},
+		Enabled: func(prefs config.PreferencesFormat) bool { return prefs.Tags.Enabled },
+	},
+	{
+		Name:    "Subscriber count variable",
+		Scopes:  []string{ScopeChannelReadSubscriptions},
+		Enabled: func(_ config.PreferencesFormat) bool { return true },
+	},
+	{
+		Name:    "Chat message per title update",
+		Scopes:  []string{ScopeUserWriteChat},
+		Enabled: func(prefs config.PreferencesFormat) bool { return prefs.Title.SendChatMessagePerTitleUpdate },
+	},
+	{
+		Name:    "Stream marker per title update",
+		Scopes:  []string{ScopeChannelManageBroadcast},
+		Enabled: func(prefs config.PreferencesFormat) bool { return prefs.Title.CreateMarkerPerTitleUpdate },
+	},
+	{
+		Name:    "Twitch AutoMod title check",
+		Scopes:  []string{ScopeModerationRead},
+		Enabled: func(prefs config.PreferencesFormat) bool { return prefs.Moderation.UseTwitchAutoMod },
+	},
+}
+
+// An enabled feature whose scopes have not all been granted
+type MissingScopesT struct {
+	Feature string
+	Scopes  []string
+}
+
+// Returns the union of the scopes needed by every enabled feature, sorted
+func RequiredScopes(prefs config.PreferencesFormat) []string {
+	scopes := []string{}
+	for _, feature := range Features {
+		if feature.Enabled(prefs) {
+			scopes = append(scopes, feature.Scopes...)
+		}
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+// Returns the enabled features which need scopes the saved credentials were not granted
+func GetMissingScopes(prefs config.PreferencesFormat) []MissingScopesT {
+	granted := prefs.TwitchConfig.Credentials.UserAccessScope
+	missingScopes := []MissingScopesT{}
+	for _, feature := range Features {
+		if !feature.Enabled(prefs) {
+			continue
+		}
+		missing := []string{}
+		for _, scope := range feature.Scopes {
+			if !slices.Contains(granted, scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			missingScopes = append(missingScopes, MissingScopesT{Feature: feature.Name, Scopes: missing})
+		}
+	}
+	return missingScopes
+}
+
+// The scopes to request when authenticating, space-separated as Twitch expects
+func requestedScopes() string {
+	return strings.Join(RequiredScopes(config.SnapshotPreferences()), " ")
+}
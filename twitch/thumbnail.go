@@ -0,0 +1,64 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxThumbnailBytes = 10 * 1024 * 1024
+
+var ErrNoStreamThumbnail error = errors.New("no stream thumbnail available - the stream may be offline")
+
+var (
+	streamThumbnailUrl   string // template containing {width} and {height}, from the latest stream info
+	streamThumbnailUrlMu sync.Mutex
+)
+
+func setStreamThumbnailUrl(thumbnailUrl string) {
+	streamThumbnailUrlMu.Lock()
+	defer streamThumbnailUrlMu.Unlock()
+	streamThumbnailUrl = thumbnailUrl
+}
+
+// Downloads the current thumbnail of the live stream
+func GetStreamThumbnail(ctx context.Context) ([]byte, error) {
+	streamThumbnailUrlMu.Lock()
+	thumbnailUrl := streamThumbnailUrl
+	streamThumbnailUrlMu.Unlock()
+	if thumbnailUrl == "" {
+		return nil, ErrNoStreamThumbnail
+	}
+
+	thumbnailUrl = strings.NewReplacer(
+		"{width}", strconv.Itoa(streamThumbnailWidth),
+		"{height}", strconv.Itoa(streamThumbnailHeight),
+	).Replace(thumbnailUrl)
+	// thumbnails are cached by twitch's cdn, so ask for a fresh one
+	thumbnailUrl = fmt.Sprintf("%s?t=%d", thumbnailUrl, time.Now().Unix())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", thumbnailUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to construct request for %v - err: %w", thumbnailUrl, err)
+	}
+	resp, err := helixClient.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request for %v failed - err: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request for %v returned status %v", req.URL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxThumbnailBytes))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read thumbnail from %v - err: %w", req.URL, err)
+	}
+	return data, nil
+}
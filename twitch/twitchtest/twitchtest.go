@@ -0,0 +1,403 @@
+package twitchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+	"github.com/finahdinner/tidal/twitch"
+)
+
+// The details of the Twitch app and user the fake knows about
+const (
+	ClientId     = "fake-client-id"
+	ClientSecret = "fake-client-secret"
+	UserName     = "tidaltester"
+	UserId       = "1234"
+)
+
+// A local stand-in for the Helix API (under /helix) and Twitch's OAuth server (under /oauth2)
+type FakeTwitchT struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	accessToken  string // the only access token the fake accepts
+	refreshToken string // the only refresh token the fake accepts
+	authCode     string
+	serverErrors int // the next n helix requests fail with 503
+
+	// set these before making requests
+	Offline      bool // the stream is not live, so markers cannot be created
+	PendingPolls int  // device code polls still to answer with authorization_pending
+
+	// what the fake has received, to check once requests have finished
+	TokensIssued    int
+	Titles          []string
+	ChannelUpdates  []ChannelUpdateT
+	GameLookups     []string // the endpoint and name of each game lookup
+	Markers         []map[string]string
+	ChatMessages    []map[string]string
+	HelixRequests   int
+	TokenGrantsUsed []string
+	RevokedTokens   []string
+	ClientSecrets   []string
+}
+
+// The body of a PATCH request to /helix/channels
+type ChannelUpdateT struct {
+	Title                       string   `json:"title"`
+	GameId                      string   `json:"game_id"`
+	BroadcasterLanguage         string   `json:"broadcaster_language"`
+	Tags                        []string `json:"tags"`
+	ContentClassificationLabels []struct {
+		Id        string `json:"id"`
+		IsEnabled bool   `json:"is_enabled"`
+	} `json:"content_classification_labels"`
+}
+
+// Categories known to the fake, by id
+var knownGames = map[string]string{
+	"509658": "Just Chatting",
+	"33214":  "Fortnite",
+	"27471":  "Minecraft",
+}
+
+// Starts a fake Twitch which the twitch package sends its requests to until the test ends.
+// The preferences are given credentials the fake accepts, and are restored when the test ends.
+func New(t testing.TB) *FakeTwitchT {
+	t.Helper()
+	fake := &FakeTwitchT{
+		accessToken:  "access-0",
+		refreshToken: "refresh-0",
+		authCode:     "fake-auth-code",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /oauth2/authorize", fake.handleAuthorise)
+	mux.HandleFunc("POST /oauth2/token", fake.handleToken)
+	mux.HandleFunc("GET /oauth2/validate", fake.handleValidate)
+	mux.HandleFunc("POST /oauth2/revoke", fake.handleRevoke)
+	mux.HandleFunc("POST /oauth2/device", fake.handleDevice)
+	mux.HandleFunc("GET /helix/users", fake.helix(fake.handleUsers))
+	mux.HandleFunc("GET /helix/streams", fake.helix(fake.handleStreams))
+	mux.HandleFunc("GET /helix/subscriptions", fake.helix(fake.handleSubscriptions))
+	mux.HandleFunc("GET /helix/channels/followers", fake.helix(fake.handleFollowers))
+	mux.HandleFunc("PATCH /helix/channels", fake.helix(fake.handleUpdateChannel))
+	mux.HandleFunc("POST /helix/chat/messages", fake.helix(fake.handleChatMessage))
+	mux.HandleFunc("POST /helix/streams/markers", fake.helix(fake.handleCreateMarker))
+	mux.HandleFunc("GET /helix/games", fake.helix(fake.handleGames))
+	mux.HandleFunc("GET /helix/search/categories", fake.helix(fake.handleSearchCategories))
+	fake.server = httptest.NewServer(mux)
+
+	twitch.SetBaseUrls(fake.server.URL+"/helix", fake.server.URL+"/oauth2")
+
+	prevPreferences := config.Preferences
+	config.Preferences.TwitchConfig = config.TwitchConfigT{
+		UserName:          UserName,
+		UserId:            UserId,
+		ClientId:          ClientId,
+		ClientSecret:      ClientSecret,
+		ClientRedirectUri: "http://localhost:17563",
+		Credentials: config.CredentialsT{
+			UserAccessToken:        fake.accessToken,
+			UserAccessRefreshToken: fake.refreshToken,
+			UserAccessScope:        []string{twitch.ScopeChannelManageBroadcast},
+			ExpiryUnixTimestamp:    time.Now().Add(time.Hour).Unix(),
+		},
+	}
+
+	t.Cleanup(func() {
+		fake.server.Close()
+		twitch.SetBaseUrls("", "")
+		config.Preferences = prevPreferences
+	})
+	return fake
+}
+
+// Revokes the current access token, as Twitch does when a user changes their password
+func (f *FakeTwitchT) RevokeAccessToken() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accessToken = "revoked"
+}
+
+func (f *FakeTwitchT) RevokeRefreshToken() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshToken = "revoked"
+}
+
+func (f *FakeTwitchT) FailNextRequests(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.serverErrors = n
+}
+
+func (f *FakeTwitchT) issueTokens() map[string]any {
+	f.TokensIssued++
+	f.accessToken = fmt.Sprintf("access-%d", f.TokensIssued)
+	f.refreshToken = fmt.Sprintf("refresh-%d", f.TokensIssued)
+	return map[string]any{
+		"access_token":  f.accessToken,
+		"refresh_token": f.refreshToken,
+		"expires_in":    14400,
+		"scope":         []string{"channel:manage:broadcast"},
+		"token_type":    "bearer",
+	}
+}
+
+func (f *FakeTwitchT) handleAuthorise(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != ClientId || query.Get("response_type") != "code" {
+		http.Error(w, "bad authorise request", http.StatusBadRequest)
+		return
+	}
+	redirectUrl := fmt.Sprintf("%s?code=%s&state=%s", query.Get("redirect_uri"), f.authCode, url.QueryEscape(query.Get("state")))
+	http.Redirect(w, r, redirectUrl, http.StatusFound)
+}
+
+func (f *FakeTwitchT) handleToken(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r.ParseForm()
+	grantType := r.PostForm.Get("grant_type")
+	f.TokenGrantsUsed = append(f.TokenGrantsUsed, grantType)
+	f.ClientSecrets = append(f.ClientSecrets, r.PostForm.Get("client_secret"))
+	publicClient := !r.PostForm.Has("client_secret") // public clients have no secret to send
+	if r.PostForm.Get("client_id") != ClientId || (!publicClient && r.PostForm.Get("client_secret") != ClientSecret) {
+		writeJson(w, http.StatusForbidden, map[string]any{"status": 403, "message": "invalid client secret"})
+		return
+	}
+
+	switch {
+	case grantType == "authorization_code" && r.PostForm.Get("code") == f.authCode:
+		writeJson(w, http.StatusOK, f.issueTokens())
+	case grantType == "refresh_token" && r.PostForm.Get("refresh_token") == f.refreshToken:
+		writeJson(w, http.StatusOK, f.issueTokens())
+	case grantType == "urn:ietf:params:oauth:grant-type:device_code" && r.PostForm.Get("device_code") == "fake-device-code":
+		if f.PendingPolls > 0 {
+			f.PendingPolls--
+			writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "authorization_pending"})
+			return
+		}
+		writeJson(w, http.StatusOK, f.issueTokens())
+	case grantType == "urn:ietf:params:oauth:grant-type:device_code":
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "invalid device code"})
+	case grantType == "refresh_token":
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Invalid refresh token"})
+	default:
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Invalid authorization code"})
+	}
+}
+
+func (f *FakeTwitchT) handleValidate(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "OAuth "+f.accessToken {
+		writeJson(w, http.StatusUnauthorized, map[string]any{"status": 401, "message": "invalid access token"})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{
+		"client_id":  ClientId,
+		"login":      UserName,
+		"scopes":     []string{"channel:manage:broadcast", "user:write:chat"},
+		"user_id":    UserId,
+		"expires_in": 5000,
+	})
+}
+
+func (f *FakeTwitchT) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r.ParseForm()
+	if r.PostForm.Get("client_id") != ClientId || r.PostForm.Get("token") != f.accessToken {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Invalid token"})
+		return
+	}
+	f.RevokedTokens = append(f.RevokedTokens, f.accessToken)
+	f.accessToken = "revoked"
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *FakeTwitchT) handleDevice(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if r.PostForm.Get("client_id") != ClientId || r.PostForm.Get("scopes") == "" {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "invalid client"})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{
+		"device_code":      "fake-device-code",
+		"user_code":        "ABCDEFGH",
+		"verification_uri": f.server.URL + "/activate",
+		"expires_in":       1800,
+		"interval":         1,
+	})
+}
+
+// Checks the credentials of a Helix request before passing it on
+func (f *FakeTwitchT) helix(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.HelixRequests++
+		if f.serverErrors > 0 {
+			f.serverErrors--
+			f.mu.Unlock()
+			writeJson(w, http.StatusServiceUnavailable, map[string]any{"status": 503, "message": "service unavailable"})
+			return
+		}
+		authorised := r.Header.Get("Authorization") == "Bearer "+f.accessToken && r.Header.Get("Client-Id") == ClientId
+		f.mu.Unlock()
+
+		w.Header().Set("Ratelimit-Limit", "800")
+		w.Header().Set("Ratelimit-Remaining", "799")
+		w.Header().Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		if !authorised {
+			writeJson(w, http.StatusUnauthorized, map[string]any{"status": 401, "message": "Invalid OAuth token"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (f *FakeTwitchT) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("login") != UserName {
+		writeJson(w, http.StatusOK, map[string]any{"data": []any{}})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{
+		"data": []map[string]any{{"id": UserId, "login": UserName, "display_name": "TidalTester"}},
+	})
+}
+
+func (f *FakeTwitchT) handleStreams(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("user_id") != UserId {
+		writeJson(w, http.StatusOK, map[string]any{"data": []any{}})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{
+		"data": []map[string]any{{
+			"id":            "stream-1",
+			"user_id":       UserId,
+			"game_name":     "Just Chatting",
+			"type":          "live",
+			"title":         "old title",
+			"viewer_count":  42,
+			"started_at":    time.Now().Add(-time.Hour).Format(time.RFC3339),
+			"thumbnail_url": f.server.URL + "/thumbnail-{width}x{height}.jpg",
+		}},
+	})
+}
+
+func (f *FakeTwitchT) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]any{"data": []any{}, "total": 7, "points": 7})
+}
+
+func (f *FakeTwitchT) handleFollowers(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]any{"data": []any{}, "total": 99})
+}
+
+func (f *FakeTwitchT) handleUpdateChannel(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("broadcaster_id") != UserId {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Missing broadcaster_id"})
+		return
+	}
+	var reqBody ChannelUpdateT
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Invalid body"})
+		return
+	}
+	if len(reqBody.Tags) > 10 {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Too many tags"})
+		return
+	}
+	f.mu.Lock()
+	f.Titles = append(f.Titles, reqBody.Title)
+	f.ChannelUpdates = append(f.ChannelUpdates, reqBody)
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *FakeTwitchT) handleCreateMarker(w http.ResponseWriter, r *http.Request) {
+	reqBody := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Invalid body"})
+		return
+	}
+	if len([]rune(reqBody["description"])) > 140 {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Description too long"})
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Offline {
+		writeJson(w, http.StatusNotFound, map[string]any{"status": 404, "message": "user is not live"})
+		return
+	}
+	f.Markers = append(f.Markers, reqBody)
+	writeJson(w, http.StatusOK, map[string]any{
+		"data": []map[string]any{{
+			"id":               fmt.Sprintf("marker-%v", len(f.Markers)),
+			"created_at":       time.Now().Format(time.RFC3339),
+			"description":      reqBody["description"],
+			"position_seconds": 3600,
+		}},
+	})
+}
+
+// Only exact names match, as on Twitch
+func (f *FakeTwitchT) handleGames(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	f.mu.Lock()
+	f.GameLookups = append(f.GameLookups, "games:"+name)
+	f.mu.Unlock()
+	games := []map[string]string{}
+	for id, gameName := range knownGames {
+		if gameName == name {
+			games = append(games, map[string]string{"id": id, "name": gameName})
+		}
+	}
+	writeJson(w, http.StatusOK, map[string]any{"data": games})
+}
+
+func (f *FakeTwitchT) handleSearchCategories(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	f.mu.Lock()
+	f.GameLookups = append(f.GameLookups, "search:"+query)
+	f.mu.Unlock()
+	games := []map[string]string{}
+	for id, gameName := range knownGames {
+		if strings.Contains(strings.ToLower(gameName), strings.ToLower(query)) {
+			games = append(games, map[string]string{"id": id, "name": gameName})
+		}
+	}
+	writeJson(w, http.StatusOK, map[string]any{"data": games})
+}
+
+func (f *FakeTwitchT) handleChatMessage(w http.ResponseWriter, r *http.Request) {
+	reqBody := map[string]string{}
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]any{"status": 400, "message": "Invalid body"})
+		return
+	}
+	f.mu.Lock()
+	f.ChatMessages = append(f.ChatMessages, reqBody)
+	f.mu.Unlock()
+	writeJson(w, http.StatusOK, map[string]any{
+		"data": []map[string]any{{"message_id": "message-1", "is_sent": true}},
+	})
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,167 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/finahdinner/tidal/config"
+)
+
+// Twitch requires apps to validate their tokens on start and hourly afterwards
+const TokenValidationInterval = time.Hour
+
+var ErrNotAuthenticated error = errors.New("not authenticated with twitch")
+
+type tokenValidationResponseT struct {
+	ClientId  string   `json:"client_id"`
+	Login     string   `json:"login"`
+	Scopes    []string `json:"scopes"`
+	UserId    string   `json:"user_id"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// Validates the saved access token, refreshing it if Twitch no longer accepts it.
+// The saved scopes and expiry are updated from Twitch's response.
+func ValidateCredentials(ctx context.Context) error {
+	accessToken := config.GetCredentials().UserAccessToken
+	if accessToken == "" {
+		return ErrNotAuthenticated
+	}
+
+	validation, err := validateToken(ctx, accessToken)
+	if errors.Is(err, Err401Unauthorised) {
+		config.Logger.LogInfo("twitch access token is no longer valid - refreshing it")
+		if err := helixClient.refreshCredentials(ctx, accessToken); err != nil {
+			return err
+		}
+		accessToken = config.GetCredentials().UserAccessToken
+		validation, err = validateToken(ctx, accessToken)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to validate access token - err: %w", err)
+	}
+
+	helixClient.refreshMu.Lock()
+	defer helixClient.refreshMu.Unlock()
+	if config.GetCredentials().UserAccessToken != accessToken {
+		return nil // refreshed in the meantime, so this validation is out of date
+	}
+	if err := config.UpdatePreferences(func(prefs *config.PreferencesFormat) {
+		prefs.TwitchConfig.Credentials.UserAccessScope = validation.Scopes
+		prefs.TwitchConfig.Credentials.ExpiryUnixTimestamp = time.Now().Unix() + int64(validation.ExpiresIn)
+	}); err != nil {
+		return fmt.Errorf("unable to save preferences - error: %v", err)
+	}
+	config.Logger.LogInfof("validated twitch access token for %v - expires in %v seconds", validation.Login, validation.ExpiresIn)
+	return nil
+}
+
+func validateToken(ctx context.Context, accessToken string) (*tokenValidationResponseT, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", helixClient.authUrl(twitchApiValidatePath), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to construct request - err: %w", err)
+	}
+	req.Header.Set("Authorization", "OAuth "+accessToken)
+
+	resp, err := helixClient.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request for %v failed - err: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, Err401Unauthorised
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to validate token - http status %v", resp.Status)
+	}
+
+	validation := &tokenValidationResponseT{}
+	if err := json.NewDecoder(resp.Body).Decode(validation); err != nil {
+		return nil, fmt.Errorf("unable to decode response from request to %v - err: %w", req.URL, err)
+	}
+	return validation, nil
+}
+
+// Validates the credentials now, then every TokenValidationInterval until ctx is cancelled.
+// onFailure is called whenever validation fails, other than when there are no credentials to validate.
+func StartTokenValidator(ctx context.Context, onFailure func(error)) {
+	go func() {
+		ticker := time.NewTicker(TokenValidationInterval)
+		defer ticker.Stop()
+		for {
+			if err := ValidateCredentials(ctx); err != nil && !errors.Is(err, ErrNotAuthenticated) {
+				config.Logger.LogErrorf("twitch token validation failed - err: %v", err)
+				if onFailure != nil {
+					onFailure(err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Revokes the saved access token, then wipes the saved credentials.
+// A token which Twitch no longer recognises is treated as already revoked.
+func Disconnect(ctx context.Context) error {
+	accessToken := config.GetCredentials().UserAccessToken
+	if accessToken != "" {
+		if err := revokeToken(ctx, accessToken); err != nil {
+			return err
+		}
+	}
+
+	helixClient.refreshMu.Lock()
+	defer helixClient.refreshMu.Unlock()
+	if err := config.UpdatePreferences(func(prefs *config.PreferencesFormat) {
+		prefs.TwitchConfig.Credentials = config.CredentialsT{}
+	}); err != nil {
+		return fmt.Errorf("unable to save preferences - error: %v", err)
+	}
+	config.Logger.LogInfo("disconnected from twitch")
+	return nil
+}
+
+func revokeToken(ctx context.Context, accessToken string) error {
+	params := url.Values{}
+	params.Add("client_id", config.SnapshotPreferences().TwitchConfig.ClientId)
+	params.Add("token", accessToken)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", helixClient.authUrl(twitchApiRevokePath), strings.NewReader(params.Encode()))
+	if err != nil {
+		return fmt.Errorf("unable to construct request - err: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := helixClient.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request for %v failed - err: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadRequest:
+		// twitch returns 400 "Invalid token" for tokens which have expired or were already revoked
+		errorResponse := twitchErrorResponseT{}
+		json.NewDecoder(resp.Body).Decode(&errorResponse)
+		if strings.EqualFold(errorResponse.Message, "invalid token") {
+			config.Logger.LogInfo("twitch access token was already invalid - nothing to revoke")
+			return nil
+		}
+		return fmt.Errorf("unable to revoke token - http status %v - %s", resp.Status, errorResponse.Message)
+	default:
+		return fmt.Errorf("unable to revoke token - http status %v", resp.Status)
+	}
+}